@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// runLocal builds and runs the pipeline in dir on the local machine, printing the output as it's written.
+// an error is returned if the run didn't complete.
+func runLocal(ctx context.Context, filename, dir string, tasks []string) error {
+	cfg, err := config.NewLocalConfig(filename)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	// the local machine is the orchestrator as well as the worker.
+	if err := cfg.ApplyLocalEnv(); err != nil {
+		return err
+	}
+	run, err := runner.NewLocalRun(*cfg, dir, tasks)
+	if err != nil {
+		return err
+	}
+
+	manager := runner.NewManager(1, 1, nil)
+	manager.Start()
+	_, lines, unsubscribe := run.SubscribeLogs()
+	defer unsubscribe()
+	if err := manager.Enqueue(run); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			run.Cancel()
+		case <-finished:
+		}
+	}()
+	for l := range lines {
+		printLogLine(l)
+	}
+	manager.Close()
+	close(finished)
+
+	rec, err := manager.GetRecord(run.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	for _, build := range rec.Builds {
+		if build.Error != "" {
+			fmt.Printf("Build failed: %s\n", build.Error)
+		}
+	}
+	for _, task := range rec.Tasks {
+		fmt.Printf("Task %s: %s\n", task.Name, task.State)
+	}
+	fmt.Printf("Local run finished: %s\n", rec.State)
+	if rec.State != runner.CompletedRun.String() {
+		return fmt.Errorf("local run did not complete")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/google/uuid"
+)
+
+func logsCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "follow the output of the run until it finished")
+	task := fs.String("task", "", "only show the output of the task, use \"build\" for the build output")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: conflowctl logs [-f] [-task name] <run id>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	id, err := parseRunID(fs)
+	if err != nil {
+		return err
+	}
+
+	if !*follow {
+		return client.GetRunLogs(ctx, id, os.Stdout)
+	}
+	return followLogs(ctx, client, id, *task)
+}
+
+// followLogs prints the output of a run until it finished, an error is returned if the run didn't complete.
+func followLogs(ctx context.Context, client *cli.Client, id uuid.UUID, task string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	state, err := client.FollowLogs(ctx, id, task, printLogLine)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Run %s finished: %s\n", id, state)
+	if state != runner.CompletedRun.String() {
+		return fmt.Errorf("run %s did not complete", id)
+	}
+	return nil
+}
+
+// parseRunID parses the run ID given as the only argument of fs.
+func parseRunID(fs *flag.FlagSet) (uuid.UUID, error) {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	id, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid run id %q", fs.Arg(0))
+	}
+	return id, nil
+}
+
+func printLogLine(l api.LogLine) {
+	fmt.Printf("[%s@%s] %s\n", l.Source, l.Worker, l.Line)
+}
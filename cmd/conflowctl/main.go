@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+)
+
+// command is a conflowctl subcommand, args are the arguments following the command name.
+type command struct {
+	usage string
+	run   func(ctx context.Context, client *cli.Client, args []string) error
+}
+
+var commands = map[string]command{
+	"validate":  {usage: "validate a config file", run: validateCommand},
+	"run":       {usage: "start a pipeline run", run: runCommand},
+	"plan":      {usage: "show the commands a run would execute and their hosts, without running them", run: planCommand},
+	"logs":      {usage: "show the output of a run, -f follows it until the run finished", run: logsCommand},
+	"runs":      {usage: "list runs: runs ls", run: runsCommand},
+	"cancel":    {usage: "cancel a run", run: cancelCommand},
+	"workers":   {usage: "list workers and if they're reachable: workers ls", run: workersCommand},
+	"provision": {usage: "install and start the worker on the config's hosts over ssh", run: provisionCommand},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: conflowctl [-server address] [-token token] <command> [flags]\n\nCommands:\n")
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	server := os.Getenv(cli.ServerEnv)
+	if server == "" {
+		server = cli.DefaultServer
+	}
+	flag.StringVar(&server, "server", server, "address of the orchestrator API, defaults to $"+cli.ServerEnv)
+	token := flag.String("token", os.Getenv(cli.TokenEnv), "token of the orchestrator API, defaults to $"+cli.TokenEnv)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(context.Background(), cli.NewClient(server, *token), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func planCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	var flags runFlags
+	flags.define(fs)
+	fs.Parse(args)
+	req, err := flags.request()
+	if err != nil {
+		return err
+	}
+
+	var plan api.Plan
+	if flags.local {
+		cfg, err := config.NewLocalConfig(flags.filename)
+		if err != nil {
+			return fmt.Errorf("invalid config %s: %w", flags.filename, err)
+		}
+		run, err := runner.NewLocalRun(*cfg, flags.dir, req.Tasks)
+		if err != nil {
+			return err
+		}
+		plan = run.Plan(ctx)
+	} else if plan, err = client.Plan(ctx, req); err != nil {
+		return err
+	}
+	printPlan(plan)
+	return nil
+}
+
+func printPlan(plan api.Plan) {
+	fmt.Printf("Build %s on %s:\n", plan.Build.Name, strings.Join(plan.Build.Hosts, ", "))
+	for _, step := range plan.Build.Steps {
+		fmt.Printf("  $ %s\n", step)
+	}
+	for _, task := range plan.Tasks {
+		fmt.Println()
+		deps := ""
+		if len(task.DependsOn) > 0 {
+			deps = ", after " + strings.Join(task.DependsOn, ", ")
+		}
+		if !task.Run {
+			fmt.Printf("Task %s: skipped%s, %s\n", task.Name, deps, task.Reason)
+			continue
+		}
+		fmt.Printf("Task %s on %s%s:\n", task.Name, strings.Join(task.Hosts, ", "), deps)
+		if task.Error != "" {
+			fmt.Printf("  error resolving files: %s\n", task.Error)
+			continue
+		}
+		if len(task.Commands) == 0 {
+			fmt.Printf("  no commands, no files matched\n")
+		}
+		for _, cmd := range task.Commands {
+			fmt.Printf("  $ %s\n", cmd)
+		}
+	}
+	fmt.Println()
+	fmt.Println("The commands of a task are distributed across its hosts as they become free.")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/internal/provision"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	grpcUtil "github.com/ImTheCurse/ConflowCI/pkg/grpc"
+)
+
+// provisionCommand installs and starts the worker on the config's hosts over ssh,
+// the hosts to provision can be passed as arguments, by default every host is provisioned.
+func provisionCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("provision", flag.ExitOnError)
+	filename := fs.String("config", "conflow-ci.yaml", "filename for config file.")
+	opts := provision.Options{}
+	fs.StringVar(&opts.WorkerBinary, "worker", "worker", "path to the worker binary, built for the hosts' platform")
+	fs.BoolVar(&opts.Systemd, "systemd", false, "install the worker as a systemd unit instead of starting it under nohup")
+	fs.IntVar(&opts.Concurrency, "concurrency", 0, "number of commands each worker runs at once, 0 for the worker's default")
+	fs.DurationVar(&opts.VerifyTimeout, "timeout", 30*time.Second, "how long to wait for the workers to answer")
+	// the client certificate is uploaded to the workers and used to verify them.
+	fs.StringVar(&grpcUtil.CAPath, "ca", grpcUtil.CAPath, "path to the root CA certificate")
+	fs.StringVar(&grpcUtil.ClientCertificatePath, "client-cert", grpcUtil.ClientCertificatePath, "path to the client certificate")
+	fs.StringVar(&grpcUtil.ClientKeyPath, "client-key", grpcUtil.ClientKeyPath, "path to the client private key")
+	fs.StringVar(&opts.TLS.ServerCert, "server-cert", grpcUtil.ServerCertificatePath, "path to the workers' server certificate")
+	fs.StringVar(&opts.TLS.ServerKey, "server-key", grpcUtil.ServerKeyPath, "path to the workers' server private key")
+	grpcUtil.TlsFlag = fs.Bool("tls", true, "connect to the workers over TLS when verifying them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: conflowctl provision [flags] [host...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	opts.TLS.CA = grpcUtil.CAPath
+	opts.TLS.ClientCert = grpcUtil.ClientCertificatePath
+	opts.TLS.ClientKey = grpcUtil.ClientKeyPath
+
+	cfg, err := config.NewConfig(*filename)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", *filename, err)
+	}
+	results, err := provision.ProvisionAll(ctx, *cfg, fs.Args(), opts)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tSTATUS")
+	for _, res := range results {
+		status := "provisioned"
+		if res.Err != nil {
+			status = "failed: " + res.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", res.Host, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts failed to provision", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+)
+
+// stringList is a flag that can be repeated, or given a comma separated list.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+// runFlags are the flags selecting what a run builds and executes, shared by run and plan.
+type runFlags struct {
+	req      api.RunRequest
+	tasks    stringList
+	local    bool
+	filename string
+	dir      string
+}
+
+func (f *runFlags) define(fs *flag.FlagSet) {
+	fs.StringVar(&f.req.Branch, "branch", "", "branch to build, defaults to the branch configured in the provider")
+	fs.StringVar(&f.req.Ref, "ref", "", "branch or tag ref to build, e.g. refs/heads/main or refs/tags/v1.0.0")
+	fs.StringVar(&f.req.SHA, "sha", "", "full SHA of the commit to build")
+	fs.Var(&f.tasks, "task", "task to run, can be repeated or comma separated. defaults to every task")
+	fs.BoolVar(&f.local, "local", false, "build and run the pipeline in a local checkout, without the orchestrator, workers or a message queue")
+	fs.StringVar(&f.filename, "config", "conflow-ci.yaml", "filename for config file, used with -local")
+	fs.StringVar(&f.dir, "dir", ".", "checkout to build and run the pipeline in, used with -local")
+}
+
+// request returns the run request of the flags, after the flags are parsed.
+func (f *runFlags) request() (api.RunRequest, error) {
+	req := f.req
+	req.Tasks = f.tasks
+	if f.local && (req.Branch != "" || req.Ref != "" || req.SHA != "") {
+		return req, fmt.Errorf("-branch, -ref and -sha can't be used with -local, the checkout is built as it is")
+	}
+	return req, nil
+}
+
+func runCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var flags runFlags
+	flags.define(fs)
+	follow := fs.Bool("follow", false, "follow the output of the run until it finished")
+	fs.Parse(args)
+	req, err := flags.request()
+	if err != nil {
+		return err
+	}
+
+	if flags.local {
+		return runLocal(ctx, flags.filename, flags.dir, req.Tasks)
+	}
+
+	res, err := client.CreateRun(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started run %s\n", res.RunID)
+	if *follow {
+		return followLogs(ctx, client, res.RunID, "")
+	}
+	return nil
+}
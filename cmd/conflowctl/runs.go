@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+)
+
+func runsCommand(ctx context.Context, client *cli.Client, args []string) error {
+	if len(args) == 0 || (args[0] != "ls" && args[0] != "list") {
+		fmt.Fprintf(os.Stderr, "Usage: conflowctl runs ls [flags]\n")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("runs ls", flag.ExitOnError)
+	var filter store.RunFilter
+	fs.IntVar(&filter.PRNumber, "pr", 0, "only list runs of the pull request")
+	fs.StringVar(&filter.Branch, "branch", "", "only list runs of the branch")
+	fs.StringVar(&filter.State, "state", "", "only list runs in the state, e.g. Running or Failed")
+	fs.IntVar(&filter.Limit, "limit", 20, "maximum number of runs listed")
+	fs.Parse(args[1:])
+
+	runs, err := client.ListRuns(ctx, filter)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tEVENT\tBRANCH\tSHA\tCREATED\tDURATION")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.ID, run.State, run.Event, run.Branch, shortSHA(run.SHA),
+			run.CreatedAt.Local().Format(time.DateTime), duration(run))
+	}
+	return w.Flush()
+}
+
+func cancelCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: conflowctl cancel <run id>\n")
+	}
+	fs.Parse(args)
+	id, err := parseRunID(fs)
+	if err != nil {
+		return err
+	}
+	if err := client.CancelRun(ctx, id); err != nil {
+		return err
+	}
+	fmt.Printf("Cancelling run %s\n", id)
+	return nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// duration returns how long a run took, or has been running for.
+func duration(run store.RunRecord) string {
+	switch {
+	case run.StartedAt.IsZero():
+		return "-"
+	case run.FinishedAt.IsZero():
+		return time.Since(run.StartedAt).Round(time.Second).String()
+	default:
+		return run.FinishedAt.Sub(run.StartedAt).Round(time.Second).String()
+	}
+}
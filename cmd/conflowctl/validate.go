@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func validateCommand(ctx context.Context, client *cli.Client, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	filename := fs.String("config", "conflow-ci.yaml", "filename for config file.")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*filename = fs.Arg(0)
+	}
+
+	cfg, err := config.NewConfig(*filename)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", *filename, err)
+	}
+	fmt.Printf("Config %s is valid: %d hosts, %d tasks\n", *filename, len(cfg.Endpoints), len(cfg.Pipeline.Tasks))
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ImTheCurse/ConflowCI/internal/cli"
+)
+
+func workersCommand(ctx context.Context, client *cli.Client, args []string) error {
+	if len(args) == 0 || (args[0] != "ls" && args[0] != "list") {
+		fmt.Fprintf(os.Stderr, "Usage: conflowctl workers ls\n")
+		os.Exit(2)
+	}
+	workers, err := client.ListWorkers(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tADDRESS\tSTATUS")
+	for _, worker := range workers {
+		status := "online"
+		if !worker.Online {
+			status = "offline: " + worker.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", worker.Name, worker.Address, status)
+	}
+	return w.Flush()
+}
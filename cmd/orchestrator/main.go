@@ -2,23 +2,58 @@ package main
 
 import (
 	"flag"
+	"log"
+	"os"
+	"time"
 
 	router "github.com/ImTheCurse/ConflowCI/internal/orchestrator/routes"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	grpcUtil "github.com/ImTheCurse/ConflowCI/pkg/grpc"
 	"github.com/gofiber/fiber/v2"
 )
 
+var logger = log.New(os.Stdout, "[Orchestrator Main]: ", log.Lshortfile|log.LstdFlags)
+
 func main() {
 	grpcUtil.DefineFlags()
 	configFilename := flag.String("config", "conflow-ci.yaml", "filename for config file.")
+	maxRuns := flag.Int("max-runs", 2, "maximum number of pipeline runs executed concurrently.")
+	queueSize := flag.Int("queue-size", 100, "maximum number of runs waiting to be executed.")
+	dbPath := flag.String("db", "conflow-ci.db", "filename for the run history database.")
+	retention := flag.Duration("retention", 30*24*time.Hour, "how long finished runs are kept in the run history, 0 keeps them forever.")
 	flag.Parse()
 
-	config.GetConfig(*configFilename)
+	cfg, err := config.GetConfig(*configFilename)
+	if err != nil {
+		logger.Fatalf("Failed to load config: %v", err)
+	}
+	// local variables apply to everything the orchestrator runs, they aren't sent to the workers.
+	if err := cfg.ApplyLocalEnv(); err != nil {
+		logger.Fatalf("Failed to set local environment variables: %v", err)
+	}
+
+	st, err := store.Open(*dbPath, *retention)
+	if err != nil {
+		logger.Fatalf("Failed to open run history database: %v", err)
+	}
+	defer st.Close()
+
+	manager := runner.NewManager(*maxRuns, *queueSize, st)
+	// runs that didn't finish before the orchestrator stopped are resumed, or fail without a durable message queue.
+	if err := manager.Resume(*cfg); err != nil {
+		logger.Printf("Failed to resume unfinished runs: %v", err)
+	}
+	manager.Start()
 
 	app := fiber.New()
 	githubRouter := app.Group("/github")
-	router.TaskRouter(githubRouter, *configFilename)
+	router.TaskRouter(githubRouter, *configFilename, manager)
+	runsRouter := app.Group("/runs")
+	router.RunRouter(runsRouter, *configFilename, manager)
+	workersRouter := app.Group("/workers")
+	router.WorkerRouter(workersRouter, *configFilename)
 
 	app.Listen(":7777")
 
@@ -24,6 +24,8 @@ var logger = log.New(os.Stdout, "[Worker Main]: ", log.Lshortfile|log.LstdFlags)
 var (
 	port = flag.Int("port", 8918, "port to listen on")
 	host = flag.String("addr", "", "address to connect to")
+	// concurrency is the number of commands the worker runs at once, across all runs and tasks.
+	concurrency = flag.Int("concurrency", 1, "number of commands to run concurrently")
 )
 
 func main() {
@@ -60,7 +62,7 @@ func main() {
 	client := providerPB.NewRepositoryProviderClient(conn)
 	wbs := sync.NewWorkerBuilderServer(client)
 	syncPB.RegisterWorkerBuilderServer(server, wbs)
-	mqpb.RegisterConsumerServicerServer(server, &mq.ConsumerServer{})
+	mqpb.RegisterConsumerServicerServer(server, mq.NewConsumerServer(*concurrency))
 	syncPB.RegisterFileExtractorServer(server, &sync.TaskExecutorServer{})
 
 	logger.Printf("gRPC server Listening on port %d", *port)
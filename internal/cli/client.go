@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/google/uuid"
+)
+
+// NewClient creates a client of the orchestrator's API at server, authenticated with token.
+func NewClient(server, token string) *Client {
+	return &Client{
+		Server:     strings.TrimSuffix(server, "/"),
+		Token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// CreateRun starts a pipeline run, and returns the ID of the run.
+func (c *Client) CreateRun(ctx context.Context, req api.RunRequest) (api.RunResponse, error) {
+	var res api.RunResponse
+	err := c.do(ctx, http.MethodPost, "/runs", req, &res)
+	return res, err
+}
+
+// Plan returns what a run of the request would execute, without starting the run.
+func (c *Client) Plan(ctx context.Context, req api.RunRequest) (api.Plan, error) {
+	var plan api.Plan
+	err := c.do(ctx, http.MethodPost, "/runs/plan", req, &plan)
+	return plan, err
+}
+
+// ListRuns returns the runs matching filter, newest first.
+func (c *Client) ListRuns(ctx context.Context, filter store.RunFilter) ([]store.RunRecord, error) {
+	q := url.Values{}
+	if filter.PRNumber != 0 {
+		q.Set("pr", strconv.Itoa(filter.PRNumber))
+	}
+	if filter.Branch != "" {
+		q.Set("branch", filter.Branch)
+	}
+	if filter.State != "" {
+		q.Set("state", filter.State)
+	}
+	if !filter.Since.IsZero() {
+		q.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	path := "/runs"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var runs []store.RunRecord
+	err := c.do(ctx, http.MethodGet, path, nil, &runs)
+	return runs, err
+}
+
+// GetRunLogs writes the logs of a run as plain text to w.
+func (c *Client) GetRunLogs(ctx context.Context, id uuid.UUID, w io.Writer) error {
+	return c.do(ctx, http.MethodGet, "/runs/"+id.String()+"/logs", nil, w)
+}
+
+// FollowLogs streams the output of a run, calling onLine for every line until the run finished.
+// if task isn't empty only the lines of the task are streamed. the final state of the run is returned.
+func (c *Client) FollowLogs(ctx context.Context, id uuid.UUID, task string, onLine func(api.LogLine)) (string, error) {
+	path := "/runs/" + id.String() + "/logs/stream"
+	if task != "" {
+		path += "?task=" + url.QueryEscape(task)
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "log":
+				var l api.LogLine
+				if err := json.Unmarshal(data, &l); err != nil {
+					return "", err
+				}
+				onLine(l)
+			case "end":
+				var end api.LogEnd
+				if err := json.Unmarshal(data, &end); err != nil {
+					return "", err
+				}
+				return end.State, nil
+			}
+		case line == "":
+			event = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrStreamClosed
+}
+
+// CancelRun cancels an unfinished run.
+func (c *Client) CancelRun(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/runs/"+id.String()+"/cancel", nil, nil)
+}
+
+// ListWorkers returns the workers of the orchestrator's config, and if they're reachable.
+func (c *Client) ListWorkers(ctx context.Context) ([]api.Worker, error) {
+	var workers []api.Worker
+	err := c.do(ctx, http.MethodGet, "/workers", nil, &workers)
+	return workers, err
+}
+
+// do sends a request to the API with body encoded as JSON if it's not nil, and decodes the
+// JSON response into out if it's not nil. if out is an io.Writer the response is copied to it.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if w, ok := out.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request to the API with body encoded as JSON if it's not nil, a response
+// with a non 2xx status is returned as an APIError. the caller must close the response's body.
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Server+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, APIError{StatusCode: resp.StatusCode, Message: string(b)}
+	}
+	return resp, nil
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/google/uuid"
+)
+
+func TestCreateRun(t *testing.T) {
+	id := uuid.New()
+	var got api.RunRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/runs" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected the request to be authenticated with the token, got %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(api.RunResponse{RunID: id})
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL+"/", "secret")
+	res, err := client.CreateRun(context.Background(), api.RunRequest{Branch: "feature", Tasks: []string{"test"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.RunID != id {
+		t.Errorf("Expected run ID %s, got %s", id, res.RunID)
+	}
+	if got.Branch != "feature" || len(got.Tasks) != 1 || got.Tasks[0] != "test" {
+		t.Errorf("Unexpected request: %+v", got)
+	}
+}
+
+func TestCreateRunAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unknown task missing", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, "").CreateRun(context.Background(), api.RunRequest{Tasks: []string{"missing"}})
+	apiErr, ok := err.(APIError)
+	if !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected API error with status %d, got: %v", http.StatusBadRequest, err)
+	}
+	if apiErr.Error() != "orchestrator API error: status 400: Unknown task missing" {
+		t.Errorf("Unexpected error message: %s", apiErr.Error())
+	}
+}
+
+func TestListRuns(t *testing.T) {
+	id := uuid.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/runs" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if q := r.URL.Query().Encode(); q != "branch=main&limit=5&pr=42" {
+			t.Errorf("Unexpected query: %s", q)
+		}
+		json.NewEncoder(w).Encode([]store.RunRecord{{ID: id, State: "Running"}})
+	}))
+	defer srv.Close()
+
+	runs, err := NewClient(srv.URL, "").ListRuns(context.Background(), store.RunFilter{PRNumber: 42, Branch: "main", Limit: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != id || runs[0].State != "Running" {
+		t.Errorf("Unexpected runs: %+v", runs)
+	}
+}
+
+func TestListWorkers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/workers" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected the request to be authenticated with the token, got %q", auth)
+		}
+		json.NewEncoder(w).Encode([]api.Worker{{Name: "node-1", Address: "10.0.0.1:8871", Online: true}})
+	}))
+	defer srv.Close()
+
+	workers, err := NewClient(srv.URL, "secret").ListWorkers(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(workers) != 1 || workers[0].Name != "node-1" || !workers[0].Online {
+		t.Errorf("Unexpected workers: %+v", workers)
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		stream string
+		lines  []string
+		state  string
+		err    error
+	}{
+		{
+			name: "finished run",
+			stream: "event: log\ndata: {\"source\":\"build\",\"worker\":\"w1\",\"line\":\"building\"}\n\n" +
+				": keep-alive\n\n" +
+				"event: log\ndata: {\"source\":\"test\",\"worker\":\"w1\",\"line\":\"ok\"}\n\n" +
+				"event: end\ndata: {\"state\":\"Completed\"}\n\n",
+			lines: []string{"building", "ok"},
+			state: "Completed",
+		},
+		{
+			name:   "closed stream",
+			stream: "event: log\ndata: {\"source\":\"build\",\"worker\":\"w1\",\"line\":\"building\"}\n\n",
+			lines:  []string{"building"},
+			err:    ErrStreamClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/runs/"+id.String()+"/logs/stream" || r.URL.Query().Get("task") != "test" {
+					t.Errorf("Unexpected request: %s", r.URL)
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.stream)
+			}))
+			defer srv.Close()
+
+			var lines []string
+			state, err := NewClient(srv.URL, "").FollowLogs(context.Background(), id, "test", func(l api.LogLine) {
+				lines = append(lines, l.Line)
+			})
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if state != tt.state {
+				t.Errorf("Expected state %q, got %q", tt.state, state)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tt.lines) {
+				t.Errorf("Expected lines %v, got %v", tt.lines, lines)
+			}
+		})
+	}
+}
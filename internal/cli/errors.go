@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrStreamClosed = errors.New("log stream closed before the run finished")
+
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e APIError) Error() string {
+	msg := strings.TrimSpace(e.Message)
+	if msg == "" {
+		return fmt.Sprintf("orchestrator API error: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("orchestrator API error: status %d: %s", e.StatusCode, msg)
+}
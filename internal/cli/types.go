@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultServer is the address of the orchestrator's API used when none is configured.
+const DefaultServer = "http://localhost:7777"
+
+// requestTimeout is how long a request to the API may take, log streams aren't limited.
+const requestTimeout = 30 * time.Second
+
+// ServerEnv is the environment variable holding the address of the orchestrator's API.
+const ServerEnv = "CONFLOW_SERVER"
+
+// TokenEnv is the environment variable holding the token the API is authenticated with.
+const TokenEnv = "CONFLOW_TOKEN"
+
+// Client is a client of the orchestrator's REST API.
+type Client struct {
+	Server     string // e.g. http://localhost:7777
+	Token      string // sent as a bearer token, see config.API
+	httpClient *http.Client
+}
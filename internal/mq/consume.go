@@ -3,11 +3,14 @@ package mq
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"sync"
+	"time"
 
 	pb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // NewConsumer sets up a consumer bound to an exchange and queue
@@ -26,20 +29,7 @@ func NewConsumer(amqpURL string, exchangeName string, params ConsumerParams, tag
 		return nil, ChannelError{msg: err.Error()}
 	}
 	// Declare exchange
-	err = ch.ExchangeDeclare(
-		exchangeName,
-		// direct exchange - binds routing key directly to a queue. you can read more here:
-		// https://www.rabbitmq.com/tutorials/tutorial-four-go#direct-exchange
-		"direct",
-		// non-durable, we want to fail our test-pipeline if the message queue is down. we dont save the jobs.
-		// we expect the pipeline the restart in entirety.
-		// TODO: maybe in the future we could support long-term lived jobs and pipeline continuation.
-		false,
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // args
-	)
+	err = declareExchange(ch, exchangeName)
 
 	if err != nil {
 		ch.Close()
@@ -47,20 +37,46 @@ func NewConsumer(amqpURL string, exchangeName string, params ConsumerParams, tag
 		return nil, ExchangeError{msg: err.Error()}
 	}
 
+	if err := declareQueues(ch, exchangeName, params); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	// we create a publisher in order to send the output / error back to the message queue.
+	p, err := NewPublisher(amqpURL, exchangeName)
+	if err != nil {
+		return nil, err
+	}
+	logger.Println("Created mq.Consumer Instance.")
+	return &Consumer{
+		conn:         conn,
+		channel:      ch,
+		publisher:    p,
+		exchangeName: exchangeName,
+		tag:          tag,
+	}, nil
+}
+
+// declareQueues declares the queues of params and binds them to the exchange with their routing keys,
+// queues that already exist are reused.
+func declareQueues(ch *amqp.Channel, exchangeName string, params ConsumerParams) error {
+	var args amqp.Table
+	if params.Expires > 0 {
+		args = amqp.Table{"x-expires": params.Expires.Milliseconds()}
+	}
 	for queueName, routingKey := range params.QueueRoutingInfo {
 		// Declare queue
 		q, err := ch.QueueDeclare(
 			queueName,
+			isDurable(exchangeName), // queues of the durable exchange survive restarts of the broker
 			false,
 			false,
 			false,
-			false,
-			nil,
+			args,
 		)
 		if err != nil {
-			ch.Close()
-			conn.Close()
-			return nil, QueueError{msg: err.Error()}
+			return QueueError{msg: err.Error()}
 		}
 		// Bind queue to exchange
 		err = ch.QueueBind(
@@ -71,83 +87,178 @@ func NewConsumer(amqpURL string, exchangeName string, params ConsumerParams, tag
 			nil,
 		)
 		if err != nil {
-			ch.Close()
-			conn.Close()
-			return nil, BindingError{msg: err.Error()}
+			return BindingError{msg: err.Error()}
 		}
 	}
+	return nil
+}
 
-	// we create a publisher in order to send the output / error back to the message queue.
-	p, err := NewPublisher(amqpURL, exchangeName)
-	if err != nil {
-		return nil, err
-	}
-	logger.Println("Created mq.Consumer Instance.")
-	return &Consumer{
-		conn:         conn,
-		channel:      ch,
-		publisher:    p,
-		exchangeName: exchangeName,
-		tag:          tag,
-	}, nil
+// ConsumeCommand starts consuming from the command queue of params, the commands run on the local machine
+// and their output is streamed to stream.
+func (c *Consumer) ConsumeCommand(ctx context.Context, params ConsumerParams,
+	stream pb.ConsumerServicer_StartConsumerServer) error {
+	return c.ConsumeCommandWith(ctx, params, func(ctx context.Context, cmd []byte, env map[string]string,
+		onLine process.LineFunc) (string, error) {
+		return RunCommand(ctx, "", cmd, env, onLine)
+	}, stream.Send)
 }
 
-// ConsumeCommand starts consuming from the command queue.
-func (c *Consumer) ConsumeCommand(ctx context.Context, stream pb.ConsumerServicer_StartConsumerServer) error {
-	logger.Println("Consuming command queue.")
-	msgs, err := c.channel.Consume(
-		QueueNameCmd,
-		c.tag,
-		false, // manual ack
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
+// ConsumeCommandWith starts consuming command envelopes from the command queue of params, running every command
+// with run and passing its output lines and its result to send, like a worker streams them to the orchestrator.
+// the result envelope of every command is published to the output or error queue of params, tagged with the
+// consumer's tag as host. a command exceeding the timeout of its envelope is killed, and its result is marked as timed out.
+// the queues of params are declared if they don't exist, a consumer can consume the queues of several tasks at once.
+// commands run concurrently, up to the consumer's slots - one at a time if it has none. the queue is polled
+// for a command whenever a slot is free, rather than the broker pushing commands the consumer can't run yet.
+func (c *Consumer) ConsumeCommandWith(ctx context.Context, params ConsumerParams, run CommandRunner,
+	send func(*pb.ConsumerCommandResponse) error) error {
+	if err := declareQueues(c.channel, c.exchangeName, params); err != nil {
 		return err
 	}
+	slots := c.slots
+	if slots == nil {
+		slots = newSlots(1)
+	}
+	cmdQueue := orDefault(params.CmdQueue, QueueNameCmd)
+	logger.Printf("Consuming command queue %s.", cmdQueue)
+	keys := resultKeys{
+		output: orDefault(params.OutputRoutingKey, RoutingKeyOutputQueue),
+		error:  orDefault(params.ErrorRoutingKey, RoutingKeyErrorOutputQueue),
+	}
 
+	// commands run concurrently, but the stream is written by one at a time.
+	var sendMu sync.Mutex
+	lockedSend := func(msg *pb.ConsumerCommandResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return send(msg)
+	}
+	// wait for the running commands to be killed before returning.
+	var running sync.WaitGroup
+	defer running.Wait()
 	for {
+		// a command is only fetched once a slot is free, the slots are shared by the consumers of the worker,
+		// so commands it can't run yet are left in the queue for other consumers instead of waiting here.
 		select {
-		case d, ok := <-msgs:
-			logger.Println("Got message from command queue, checking if message is ok.")
-			if !ok {
-				return fmt.Errorf("failed to consume messages")
-			}
-			logger.Println("Message is ok, running command.")
-			o, err := runCommand(d.Body) // error here is a cmd error
-			if err != nil {
-				err = c.publisher.Publish(ctx, RoutingKeyErrorOutputQueue, []byte(o)) // send message to error queue if the cmd failed.
-				if err != nil {
-					logger.Printf("failed to publish error message: %v, with error: %v", o, err)
-				}
-			} else {
-				err = c.publisher.Publish(ctx, RoutingKeyOutputQueue, []byte(o)) // send output with no error to output queue.
-				if err != nil {
-					logger.Printf("failed to publish message: %v, with error: %v", o, err)
-				}
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		d, ok, err := c.channel.Get(cmdQueue, false) // manual ack
+		if err != nil {
+			<-slots
+			return fmt.Errorf("failed to consume messages: %w", err)
+		}
+		if !ok {
+			<-slots
+			select {
+			case <-time.After(CommandPollInterval):
+				continue
+			case <-ctx.Done():
+				return nil
 			}
+		}
+		logger.Println("Got message from command queue, checking if message is ok.")
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer func() { <-slots }()
+			c.handleCommand(ctx, d, run, lockedSend, keys)
+		}()
+	}
+}
 
-			//Signal finished command.
-			isFinished := true
-			m := fmt.Sprintf("Command consumed and executed sucessfully with output: %s", o)
-
-			var pbErr *pb.ConsumerError
-			if err != nil {
-				pbErr = &pb.ConsumerError{Reason: err.Error()}
-			}
-			stream.Send(&pb.ConsumerCommandResponse{FinishedCommand: &isFinished, Output: m, Error: pbErr})
-			_ = d.Ack(false)
+// resultKeys are the routing keys results of successful and failed commands are published with.
+type resultKeys struct {
+	output string
+	error  string
+}
 
-		case <-ctx.Done():
-			return nil
+// handleCommand runs the command of a delivery from the command queue and publishes its result.
+func (c *Consumer) handleCommand(ctx context.Context, d amqp.Delivery, run CommandRunner,
+	send func(*pb.ConsumerCommandResponse) error, keys resultKeys) {
+	msg := &pb.CommandMessage{}
+	if err := proto.Unmarshal(d.Body, msg); err != nil {
+		logger.Printf("Dropping malformed command message: %v", err)
+		_ = d.Reject(false)
+		return
+	}
+	logger.Println("Message is ok, running command.")
+	// acknowledge the command to the orchestrator, which tracks which consumer runs it.
+	send(&pb.ConsumerCommandResponse{CommandId: msg.CommandId, Command: msg.Command, Started: true})
+	res := c.runCommandMessage(ctx, run, msg, func(line string) {
+		send(&pb.ConsumerCommandResponse{Line: line, Command: msg.Command, CommandId: msg.CommandId})
+	})
+	if ctx.Err() != nil {
+		// the consumer was stopped while the command was running and the command was killed,
+		// it's returned to the queue for the broker to redeliver it to another consumer.
+		logger.Printf("Consumer stopped, requeueing killed command: %s", msg.Command)
+		_ = d.Nack(false, true)
+		return
+	}
+	var err error
+	if res.Error != "" {
+		err = c.publisher.PublishResult(ctx, keys.error, res) // send message to error queue if the cmd failed.
+		if err != nil {
+			logger.Printf("failed to publish error message: %v, with error: %v", res.Output, err)
+		}
+	} else {
+		err = c.publisher.PublishResult(ctx, keys.output, res) // send output with no error to output queue.
+		if err != nil {
+			logger.Printf("failed to publish message: %v, with error: %v", res.Output, err)
 		}
 	}
+
+	// the command is acknowledged before it's reported finished, so a finished command is never
+	// redelivered by the broker, only requeued by the orchestrator if its result is lost.
+	_ = d.Ack(false)
+
+	//Signal finished command.
+	isFinished := true
+	m := fmt.Sprintf("Command consumed and executed sucessfully with output: %s", res.Output)
+
+	var pbErr *pb.ConsumerError
+	if err != nil {
+		pbErr = &pb.ConsumerError{Reason: err.Error()}
+	}
+	send(&pb.ConsumerCommandResponse{FinishedCommand: &isFinished, Output: m, Error: pbErr, TimedOut: res.TimedOut,
+		CommandId: msg.CommandId, Command: msg.Command})
+}
+
+// runCommandMessage runs the command of msg and returns its result envelope.
+func (c *Consumer) runCommandMessage(ctx context.Context, run CommandRunner, msg *pb.CommandMessage,
+	onLine process.LineFunc) *pb.CommandResult {
+	res := &pb.CommandResult{
+		RunId:     msg.RunId,
+		TaskName:  msg.TaskName,
+		CommandId: msg.CommandId,
+		Command:   msg.Command,
+		Host:      c.tag,
+		StartedAt: timestamppb.Now(),
+	}
+	timeout := time.Duration(msg.TimeoutMs) * time.Millisecond
+	cmdCtx, cancel := process.WithTimeout(ctx, timeout)
+	defer cancel()
+	// stream the output of the command while it runs.
+	o, err := run(cmdCtx, []byte(msg.Command), msg.Env, onLine) // error here is a cmd error
+	res.FinishedAt = timestamppb.Now()
+	res.Output = o
+	res.ExitCode = int32(process.ExitCode(err))
+	if ctx.Err() == nil && cmdCtx.Err() == context.DeadlineExceeded {
+		logger.Printf("Command timed out after %s: %s", timeout, msg.Command)
+		res.Output += TimedOutOutput(timeout)
+		res.TimedOut = true
+		err = ErrCommandTimedOut{Timeout: timeout}
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
 }
 
-// ConsumeQueueContents consumes messages from a queue and appends them to a buffer.
-func (c *Consumer) ConsumeQueueContents(wg *sync.WaitGroup, done chan struct{}, queueName string, buf *[]string, e *error) {
+// ConsumeResults consumes command result envelopes from a queue until ctx is done, passing every result to accept.
+// results accept returns false for, e.g. results of commands of another task or duplicates, are dropped.
+func (c *Consumer) ConsumeResults(ctx context.Context, queueName string, accept func(*pb.CommandResult) bool) error {
 	msgs, err := c.channel.Consume(
 		queueName,
 		c.tag,
@@ -158,21 +269,26 @@ func (c *Consumer) ConsumeQueueContents(wg *sync.WaitGroup, done chan struct{},
 		nil,
 	)
 	if err != nil {
-		*e = err
-		return
+		return err
 	}
 
 	for {
 		select {
 		case d, ok := <-msgs:
-			if ok {
-				*buf = append(*buf, string(d.Body))
-				logger.Println("wg done in @Consumer.ConsumeQueueContents")
-				wg.Done()
-				_ = d.Ack(false)
+			if !ok {
+				return fmt.Errorf("failed to consume results from %s", queueName)
+			}
+			_ = d.Ack(false)
+			res := &pb.CommandResult{}
+			if err := proto.Unmarshal(d.Body, res); err != nil {
+				logger.Printf("Dropping malformed result message from %s: %v", queueName, err)
+				continue
+			}
+			if !accept(res) {
+				logger.Printf("Dropping result of unknown command %s of run %s task %s", res.CommandId, res.RunId, res.TaskName)
 			}
-		case <-done:
-			return
+		case <-ctx.Done():
+			return nil
 		}
 	}
 }
@@ -187,12 +303,21 @@ func (c *Consumer) Close() {
 	c.publisher.Close()
 }
 
-// runCommand executes a command on the endpoint's machine.
-func runCommand(c []byte) (string, error) {
-	cmd := exec.Command("/bin/sh", "-c", string(c))
+// RunCommand executes a command on the endpoint's machine, the command is killed when ctx is done.
+// the command runs in dir, or in the current directory if dir is empty, with env added to the environment.
+// onLine is called with every line the command outputs.
+func RunCommand(ctx context.Context, dir string, c []byte, env map[string]string, onLine process.LineFunc) (
+	string, error) {
+	cmd := process.Command(ctx, "/bin/sh", "-c", string(c))
+	cmd.Dir = dir
+	cmd.Env = process.Environ(env)
 
-	b, err := cmd.CombinedOutput()
-	output := string(b)
+	output, err := process.Run(cmd, onLine)
 	logger.Printf("Executed command: %s. got output: %s", string(cmd.String()), output)
 	return output, err
 }
+
+// TimedOutOutput is appended to the output of a command that was killed by its timeout.
+func TimedOutOutput(timeout time.Duration) string {
+	return fmt.Sprintf("\ncommand timed out after %s and was killed", timeout)
+}
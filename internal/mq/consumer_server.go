@@ -6,32 +6,36 @@ import (
 	pb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
 )
 
-var ConsumerInstance *Consumer = nil
+// NewConsumerServer creates a consumer server running up to concurrency commands at once,
+// across all the runs and tasks it consumes. concurrency less than 1 is treated as 1.
+func NewConsumerServer(concurrency int) *ConsumerServer {
+	return &ConsumerServer{slots: newSlots(concurrency)}
+}
 
-func getConsumer(amqpURL string, exchangeName string, params ConsumerParams, tag string) (*Consumer, error) {
-	if ConsumerInstance == nil {
-		c, err := NewConsumer(amqpURL, exchangeName, params, tag)
-		if err != nil {
-			return nil, err
-		}
-		ConsumerInstance = c
+// newSlots creates the concurrency slots of n commands, at least one.
+func newSlots(n int) chan struct{} {
+	if n < 1 {
+		n = 1
 	}
-	return ConsumerInstance, nil
+	return make(chan struct{}, n)
 }
 
+// StartConsumer consumes the command queue of the request until the stream is closed, every request
+// gets its own consumer, so the worker can consume the queues of several runs and tasks at once.
 func (s *ConsumerServer) StartConsumer(req *pb.ConsumerCommandRequest,
 	stream pb.ConsumerServicer_StartConsumerServer) error {
 
-	params := ConsumerParams{
-		QueueRoutingInfo: req.Params,
-	}
-	consumer, err := getConsumer(req.MqUrl, req.Exchange, params, req.Tag)
+	params := paramsFromRequest(req)
+	consumer, err := NewConsumer(req.MqUrl, req.Exchange, params, req.Tag)
 	if err != nil {
 		e := fmt.Sprintf("Failed to get message queue by consumer, got err : %s", err.Error())
 		stream.Send(&pb.ConsumerCommandResponse{Error: &pb.ConsumerError{Reason: e}})
 		return fmt.Errorf("%s", e)
 	}
-	err = consumer.ConsumeCommand(stream.Context(), stream)
+	defer consumer.Close()
+	consumer.slots = s.slots // a consumer without slots runs one command at a time
+
+	err = consumer.ConsumeCommand(stream.Context(), params, stream)
 	if err != nil {
 		e := fmt.Sprintf("Failed to consume commands, got: %s", err)
 		stream.Send(&pb.ConsumerCommandResponse{Error: &pb.ConsumerError{Reason: e}})
@@ -2,6 +2,7 @@ package mq
 
 import (
 	"fmt"
+	"time"
 )
 
 type ConnectionError struct {
@@ -43,3 +44,11 @@ type BindingError struct {
 func (e BindingError) Error() string {
 	return fmt.Sprintf("Message queue: binding error: %s", e.msg)
 }
+
+type ErrCommandTimedOut struct {
+	Timeout time.Duration
+}
+
+func (e ErrCommandTimedOut) Error() string {
+	return fmt.Sprintf("Command timed out after %s", e.Timeout)
+}
@@ -10,6 +10,7 @@ import (
 	pb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
 )
 
 func TestMessageQueue(t *testing.T) {
@@ -109,7 +110,7 @@ func TestMessageQueue(t *testing.T) {
 		}
 	}()
 
-	if err := p.Publish(ctx, RoutingKeyCmdQueue, []byte(m)); err != nil {
+	if err := p.PublishCommand(ctx, RoutingKeyCmdQueue, &pb.CommandMessage{CommandId: "1", Command: m}); err != nil {
 		t.Errorf("Failed to publish message: %v", err)
 	}
 	logger.Printf("Published message")
@@ -236,3 +237,131 @@ func TestNewPublisherErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestRunCommandMessage(t *testing.T) {
+	run := func(ctx context.Context, cmd []byte, env map[string]string, onLine process.LineFunc) (string, error) {
+		return RunCommand(ctx, "", cmd, env, onLine)
+	}
+	tests := []struct {
+		name     string
+		msg      *pb.CommandMessage
+		output   string
+		exitCode int32
+		timedOut bool
+		failed   bool
+	}{
+		{
+			name:   "env",
+			msg:    &pb.CommandMessage{Command: "echo $GREETING", Env: map[string]string{"GREETING": "hello"}},
+			output: "hello\n",
+		},
+		{
+			name:     "exit code",
+			msg:      &pb.CommandMessage{Command: "echo failing; exit 3"},
+			output:   "failing\n",
+			exitCode: 3,
+			failed:   true,
+		},
+		{
+			name:     "timeout",
+			msg:      &pb.CommandMessage{Command: "sleep 10", TimeoutMs: 100},
+			output:   TimedOutOutput(100 * time.Millisecond),
+			exitCode: -1,
+			timedOut: true,
+			failed:   true,
+		},
+	}
+	c := &Consumer{tag: "test-host"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.RunId, tt.msg.TaskName, tt.msg.CommandId = "run", "task", "cmd-1"
+			res := c.runCommandMessage(context.Background(), run, tt.msg, nil)
+			if res.RunId != "run" || res.TaskName != "task" || res.CommandId != "cmd-1" || res.Command != tt.msg.Command {
+				t.Errorf("Expected the result to identify its command, got %v", res)
+			}
+			if res.Host != "test-host" {
+				t.Errorf("Expected host test-host, got %s", res.Host)
+			}
+			if res.Output != tt.output || res.ExitCode != tt.exitCode || res.TimedOut != tt.timedOut || (res.Error != "") != tt.failed {
+				t.Errorf("Unexpected result: %v", res)
+			}
+			if res.FinishedAt.AsTime().Before(res.StartedAt.AsTime()) {
+				t.Errorf("Expected the command to finish after it started, got %v", res)
+			}
+		})
+	}
+}
+
+func TestTaskQueues(t *testing.T) {
+	a := TaskQueues("run-1", "task-1")
+	b := TaskQueues("run-2", "task-1")
+	if len(a.QueueRoutingInfo) != 3 {
+		t.Fatalf("Expected 3 queues, got %v", a.QueueRoutingInfo)
+	}
+	for queue, key := range a.QueueRoutingInfo {
+		if _, ok := b.QueueRoutingInfo[queue]; ok {
+			t.Errorf("Expected queue %s to belong to a single run", queue)
+		}
+		if queue == QueueNameCmd || queue == QueueNameOutput || queue == QueueNameError || key == RoutingKeyCmdQueue {
+			t.Errorf("Expected task queues to not use the global queues, got %s: %s", queue, key)
+		}
+	}
+	if a.QueueRoutingInfo[a.CmdQueue] != a.CmdRoutingKey || a.QueueRoutingInfo[a.OutputQueue] != a.OutputRoutingKey ||
+		a.QueueRoutingInfo[a.ErrorQueue] != a.ErrorRoutingKey {
+		t.Errorf("Expected the queues to be bound with their routing keys, got %v", a.QueueRoutingInfo)
+	}
+
+	// the worker consumes the queues the orchestrator requested.
+	got := paramsFromRequest(a.Request("amqp://localhost", ExchangeName, "worker-1"))
+	if got.CmdQueue != a.CmdQueue || got.OutputRoutingKey != a.OutputRoutingKey || got.ErrorRoutingKey != a.ErrorRoutingKey ||
+		got.Expires != TaskQueueExpiry || len(got.QueueRoutingInfo) != 3 {
+		t.Errorf("Expected the request to carry the task queues, got %+v", got)
+	}
+}
+
+func TestNewConsumerServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		expected    int
+	}{
+		{name: "single slot", concurrency: 1, expected: 1},
+		{name: "many slots", concurrency: 32, expected: 32},
+		{name: "no concurrency runs one command", concurrency: 0, expected: 1},
+		{name: "negative concurrency runs one command", concurrency: -4, expected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewConsumerServer(tt.concurrency)
+			if cap(s.slots) != tt.expected {
+				t.Errorf("Expected %d slots, got %d", tt.expected, cap(s.slots))
+			}
+		})
+	}
+}
+
+func TestDurableTaskQueues(t *testing.T) {
+	tests := []struct {
+		durable          bool
+		expectedExchange string
+	}{
+		{durable: false, expectedExchange: ExchangeName},
+		{durable: true, expectedExchange: DurableExchangeName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expectedExchange, func(t *testing.T) {
+			exchange := Exchange(tt.durable)
+			if exchange != tt.expectedExchange || isDurable(exchange) != tt.durable {
+				t.Errorf("Expected exchange %s with durable %v, got %s", tt.expectedExchange, tt.durable, exchange)
+			}
+		})
+	}
+
+	params := DurableTaskQueues("run-1", "task-1")
+	if params.Expires != DurableTaskQueueExpiry {
+		t.Errorf("Expected durable queues to expire after %s, got %s", DurableTaskQueueExpiry, params.Expires)
+	}
+	if params.CmdQueue != TaskQueues("run-1", "task-1").CmdQueue {
+		t.Errorf("Expected durable queues to be named like the task queues, got %s", params.CmdQueue)
+	}
+}
@@ -9,6 +9,7 @@ package pb
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,13 +23,18 @@ const (
 )
 
 type ConsumerCommandRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	MqUrl         string                 `protobuf:"bytes,1,opt,name=mq_url,json=mqUrl,proto3" json:"mq_url,omitempty"`
-	Exchange      string                 `protobuf:"bytes,2,opt,name=exchange,proto3" json:"exchange,omitempty"`
-	Params        map[string]string      `protobuf:"bytes,3,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Tag           string                 `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	MqUrl    string                 `protobuf:"bytes,1,opt,name=mq_url,json=mqUrl,proto3" json:"mq_url,omitempty"`
+	Exchange string                 `protobuf:"bytes,2,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Params   map[string]string      `protobuf:"bytes,3,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Tag      string                 `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
+	// queue commands are consumed from and routing keys of their results, the global queues are used if empty.
+	CmdQueue         string `protobuf:"bytes,5,opt,name=cmd_queue,json=cmdQueue,proto3" json:"cmd_queue,omitempty"`
+	OutputRoutingKey string `protobuf:"bytes,6,opt,name=output_routing_key,json=outputRoutingKey,proto3" json:"output_routing_key,omitempty"`
+	ErrorRoutingKey  string `protobuf:"bytes,7,opt,name=error_routing_key,json=errorRoutingKey,proto3" json:"error_routing_key,omitempty"`
+	QueueExpiresMs   int64  `protobuf:"varint,8,opt,name=queue_expires_ms,json=queueExpiresMs,proto3" json:"queue_expires_ms,omitempty"` // the queues are deleted by the broker after being unused for the duration, 0 to keep them
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *ConsumerCommandRequest) Reset() {
@@ -89,11 +95,44 @@ func (x *ConsumerCommandRequest) GetTag() string {
 	return ""
 }
 
+func (x *ConsumerCommandRequest) GetCmdQueue() string {
+	if x != nil {
+		return x.CmdQueue
+	}
+	return ""
+}
+
+func (x *ConsumerCommandRequest) GetOutputRoutingKey() string {
+	if x != nil {
+		return x.OutputRoutingKey
+	}
+	return ""
+}
+
+func (x *ConsumerCommandRequest) GetErrorRoutingKey() string {
+	if x != nil {
+		return x.ErrorRoutingKey
+	}
+	return ""
+}
+
+func (x *ConsumerCommandRequest) GetQueueExpiresMs() int64 {
+	if x != nil {
+		return x.QueueExpiresMs
+	}
+	return 0
+}
+
 type ConsumerCommandResponse struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	Output          string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
 	FinishedCommand *bool                  `protobuf:"varint,2,opt,name=finished_command,json=finishedCommand,proto3,oneof" json:"finished_command,omitempty"`
 	Error           *ConsumerError         `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Line            string                 `protobuf:"bytes,4,opt,name=line,proto3" json:"line,omitempty"`                            // a single line of output of a running command
+	Command         string                 `protobuf:"bytes,5,opt,name=command,proto3" json:"command,omitempty"`                      // the command that outputs line
+	TimedOut        bool                   `protobuf:"varint,6,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`   // the command was killed since it exceeded the command timeout
+	CommandId       string                 `protobuf:"bytes,7,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"` // the ID of the command the message is about, see CommandMessage
+	Started         bool                   `protobuf:"varint,8,opt,name=started,proto3" json:"started,omitempty"`                     // acknowledges the consumer started running the command
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -149,6 +188,41 @@ func (x *ConsumerCommandResponse) GetError() *ConsumerError {
 	return nil
 }
 
+func (x *ConsumerCommandResponse) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+func (x *ConsumerCommandResponse) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ConsumerCommandResponse) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *ConsumerCommandResponse) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *ConsumerCommandResponse) GetStarted() bool {
+	if x != nil {
+		return x.Started
+	}
+	return false
+}
+
 type ConsumerError struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
@@ -193,26 +267,275 @@ func (x *ConsumerError) GetReason() string {
 	return ""
 }
 
+// CommandMessage is the envelope of a command published to the command queue.
+type CommandMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	TaskName      string                 `protobuf:"bytes,2,opt,name=task_name,json=taskName,proto3" json:"task_name,omitempty"`
+	CommandId     string                 `protobuf:"bytes,3,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"` // unique per published command, the result of the command carries it
+	Command       string                 `protobuf:"bytes,4,opt,name=command,proto3" json:"command,omitempty"`
+	Env           map[string]string      `protobuf:"bytes,5,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // environment variables of the command
+	TimeoutMs     int64                  `protobuf:"varint,6,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`                                             // the command is killed after the timeout, 0 for no limit
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandMessage) Reset() {
+	*x = CommandMessage{}
+	mi := &file_proto_mq_consume_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandMessage) ProtoMessage() {}
+
+func (x *CommandMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mq_consume_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandMessage.ProtoReflect.Descriptor instead.
+func (*CommandMessage) Descriptor() ([]byte, []int) {
+	return file_proto_mq_consume_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CommandMessage) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *CommandMessage) GetTaskName() string {
+	if x != nil {
+		return x.TaskName
+	}
+	return ""
+}
+
+func (x *CommandMessage) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *CommandMessage) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *CommandMessage) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *CommandMessage) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+// CommandResult is the envelope of the result of a command, published to the output queue
+// if the command succeeded and to the error queue otherwise.
+type CommandResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	TaskName      string                 `protobuf:"bytes,2,opt,name=task_name,json=taskName,proto3" json:"task_name,omitempty"`
+	CommandId     string                 `protobuf:"bytes,3,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	Command       string                 `protobuf:"bytes,4,opt,name=command,proto3" json:"command,omitempty"`
+	Host          string                 `protobuf:"bytes,5,opt,name=host,proto3" json:"host,omitempty"`                          // tag of the consumer that ran the command
+	ExitCode      int32                  `protobuf:"varint,6,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"` // -1 if the command didn't exit normally, e.g. it was killed
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt    *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	Output        string                 `protobuf:"bytes,9,opt,name=output,proto3" json:"output,omitempty"`
+	TimedOut      bool                   `protobuf:"varint,10,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	Error         string                 `protobuf:"bytes,11,opt,name=error,proto3" json:"error,omitempty"` // why the command failed, empty if it succeeded
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandResult) Reset() {
+	*x = CommandResult{}
+	mi := &file_proto_mq_consume_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandResult) ProtoMessage() {}
+
+func (x *CommandResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mq_consume_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandResult.ProtoReflect.Descriptor instead.
+func (*CommandResult) Descriptor() ([]byte, []int) {
+	return file_proto_mq_consume_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CommandResult) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *CommandResult) GetTaskName() string {
+	if x != nil {
+		return x.TaskName
+	}
+	return ""
+}
+
+func (x *CommandResult) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *CommandResult) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *CommandResult) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *CommandResult) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *CommandResult) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *CommandResult) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+func (x *CommandResult) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *CommandResult) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *CommandResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_proto_mq_consume_proto protoreflect.FileDescriptor
 
 const file_proto_mq_consume_proto_rawDesc = "" +
 	"\n" +
-	"\x16proto/mq/consume.proto\x12\x02mq\"\xd8\x01\n" +
+	"\x16proto/mq/consume.proto\x12\x02mq\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf9\x02\n" +
 	"\x16ConsumerCommandRequest\x12\x15\n" +
 	"\x06mq_url\x18\x01 \x01(\tR\x05mqUrl\x12\x1a\n" +
 	"\bexchange\x18\x02 \x01(\tR\bexchange\x12>\n" +
 	"\x06params\x18\x03 \x03(\v2&.mq.ConsumerCommandRequest.ParamsEntryR\x06params\x12\x10\n" +
-	"\x03tag\x18\x04 \x01(\tR\x03tag\x1a9\n" +
+	"\x03tag\x18\x04 \x01(\tR\x03tag\x12\x1b\n" +
+	"\tcmd_queue\x18\x05 \x01(\tR\bcmdQueue\x12,\n" +
+	"\x12output_routing_key\x18\x06 \x01(\tR\x10outputRoutingKey\x12*\n" +
+	"\x11error_routing_key\x18\a \x01(\tR\x0ferrorRoutingKey\x12(\n" +
+	"\x10queue_expires_ms\x18\b \x01(\x03R\x0equeueExpiresMs\x1a9\n" +
 	"\vParamsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9f\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa3\x02\n" +
 	"\x17ConsumerCommandResponse\x12\x16\n" +
 	"\x06output\x18\x01 \x01(\tR\x06output\x12.\n" +
 	"\x10finished_command\x18\x02 \x01(\bH\x00R\x0ffinishedCommand\x88\x01\x01\x12'\n" +
-	"\x05error\x18\x03 \x01(\v2\x11.mq.ConsumerErrorR\x05errorB\x13\n" +
+	"\x05error\x18\x03 \x01(\v2\x11.mq.ConsumerErrorR\x05error\x12\x12\n" +
+	"\x04line\x18\x04 \x01(\tR\x04line\x12\x18\n" +
+	"\acommand\x18\x05 \x01(\tR\acommand\x12\x1b\n" +
+	"\ttimed_out\x18\x06 \x01(\bR\btimedOut\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\a \x01(\tR\tcommandId\x12\x18\n" +
+	"\astarted\x18\b \x01(\bR\astartedB\x13\n" +
 	"\x11_finished_command\"'\n" +
 	"\rConsumerError\x12\x16\n" +
-	"\x06reason\x18\x01 \x01(\tR\x06reason2^\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"\x83\x02\n" +
+	"\x0eCommandMessage\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1b\n" +
+	"\ttask_name\x18\x02 \x01(\tR\btaskName\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x03 \x01(\tR\tcommandId\x12\x18\n" +
+	"\acommand\x18\x04 \x01(\tR\acommand\x12-\n" +
+	"\x03env\x18\x05 \x03(\v2\x1b.mq.CommandMessage.EnvEntryR\x03env\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x06 \x01(\x03R\ttimeoutMs\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf0\x02\n" +
+	"\rCommandResult\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1b\n" +
+	"\ttask_name\x18\x02 \x01(\tR\btaskName\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x03 \x01(\tR\tcommandId\x12\x18\n" +
+	"\acommand\x18\x04 \x01(\tR\acommand\x12\x12\n" +
+	"\x04host\x18\x05 \x01(\tR\x04host\x12\x1b\n" +
+	"\texit_code\x18\x06 \x01(\x05R\bexitCode\x129\n" +
+	"\n" +
+	"started_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12;\n" +
+	"\vfinished_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"finishedAt\x12\x16\n" +
+	"\x06output\x18\t \x01(\tR\x06output\x12\x1b\n" +
+	"\ttimed_out\x18\n" +
+	" \x01(\bR\btimedOut\x12\x14\n" +
+	"\x05error\x18\v \x01(\tR\x05error2^\n" +
 	"\x10ConsumerServicer\x12J\n" +
 	"\rStartConsumer\x12\x1a.mq.ConsumerCommandRequest\x1a\x1b.mq.ConsumerCommandResponse0\x01B0Z.github.com/ImTheCurse/ConflowCI/internal/mq/pbb\x06proto3"
 
@@ -228,23 +551,30 @@ func file_proto_mq_consume_proto_rawDescGZIP() []byte {
 	return file_proto_mq_consume_proto_rawDescData
 }
 
-var file_proto_mq_consume_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_mq_consume_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_proto_mq_consume_proto_goTypes = []any{
 	(*ConsumerCommandRequest)(nil),  // 0: mq.ConsumerCommandRequest
 	(*ConsumerCommandResponse)(nil), // 1: mq.ConsumerCommandResponse
 	(*ConsumerError)(nil),           // 2: mq.ConsumerError
-	nil,                             // 3: mq.ConsumerCommandRequest.ParamsEntry
+	(*CommandMessage)(nil),          // 3: mq.CommandMessage
+	(*CommandResult)(nil),           // 4: mq.CommandResult
+	nil,                             // 5: mq.ConsumerCommandRequest.ParamsEntry
+	nil,                             // 6: mq.CommandMessage.EnvEntry
+	(*timestamppb.Timestamp)(nil),   // 7: google.protobuf.Timestamp
 }
 var file_proto_mq_consume_proto_depIdxs = []int32{
-	3, // 0: mq.ConsumerCommandRequest.params:type_name -> mq.ConsumerCommandRequest.ParamsEntry
+	5, // 0: mq.ConsumerCommandRequest.params:type_name -> mq.ConsumerCommandRequest.ParamsEntry
 	2, // 1: mq.ConsumerCommandResponse.error:type_name -> mq.ConsumerError
-	0, // 2: mq.ConsumerServicer.StartConsumer:input_type -> mq.ConsumerCommandRequest
-	1, // 3: mq.ConsumerServicer.StartConsumer:output_type -> mq.ConsumerCommandResponse
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	6, // 2: mq.CommandMessage.env:type_name -> mq.CommandMessage.EnvEntry
+	7, // 3: mq.CommandResult.started_at:type_name -> google.protobuf.Timestamp
+	7, // 4: mq.CommandResult.finished_at:type_name -> google.protobuf.Timestamp
+	0, // 5: mq.ConsumerServicer.StartConsumer:input_type -> mq.ConsumerCommandRequest
+	1, // 6: mq.ConsumerServicer.StartConsumer:output_type -> mq.ConsumerCommandResponse
+	6, // [6:7] is the sub-list for method output_type
+	5, // [5:6] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_proto_mq_consume_proto_init() }
@@ -259,7 +589,7 @@ func file_proto_mq_consume_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_mq_consume_proto_rawDesc), len(file_proto_mq_consume_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	pb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
 )
 
 // NewPublisher creates a publisher that publishes to an exchange
@@ -24,26 +26,16 @@ func NewPublisher(amqpURL, exchangeName string) (*Publisher, error) {
 	}
 
 	// Declare exchange
-	err = ch.ExchangeDeclare(
-		exchangeName,
-		// direct exchange - binds routing key directly to a queue. you can read more here:
-		// https://www.rabbitmq.com/tutorials/tutorial-four-go#direct-exchange
-		"direct",
-		// non-durable, we want to fail our test-pipeline if the message queue is down. we dont save the jobs.
-		// we expect the pipeline the restart in entirety.
-		// TODO: maybe in the future we could support long-term lived jobs and pipeline continuation.
-		false,
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // args
-	)
+	err = declareExchange(ch, exchangeName)
 	if err != nil {
 		ch.Close()
 		conn.Close()
 		return nil, ExchangeError{msg: err.Error()}
 	}
-	return &Publisher{conn: conn, channel: ch, exchangeName: exchangeName}, nil
+	// returned messages are received on a single channel for the lifetime of the publisher, the
+	// channel library blocks once a registered channel is full.
+	returns := ch.NotifyReturn(make(chan amqp.Return, 100))
+	return &Publisher{conn: conn, channel: ch, exchangeName: exchangeName, returns: returns}, nil
 }
 
 // Publish sends a message to the exchange with the routing key
@@ -52,13 +44,37 @@ func (p *Publisher) Publish(ctx context.Context, routingKey string, body []byte)
 
 }
 
+// PublishCommand sends the envelope of a command to the exchange with the routing key.
+func (p *Publisher) PublishCommand(ctx context.Context, routingKey string, msg *pb.CommandMessage) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return p.publish(ctx, routingKey, body, ProtobufContentType)
+}
+
+// PublishResult sends the envelope of a command result to the exchange with the routing key.
+func (p *Publisher) PublishResult(ctx context.Context, routingKey string, res *pb.CommandResult) error {
+	body, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return p.publish(ctx, routingKey, body, ProtobufContentType)
+}
+
 // publishWithRetry handles retries for unroutable messages
 func (p *Publisher) PublishWithRetry(ctx context.Context, routingKey string, body []byte) error {
+	return p.publish(ctx, routingKey, body, TextContentType)
+}
+
+func (p *Publisher) publish(ctx context.Context, routingKey string, body []byte, contentType string) error {
 	const maxRetries = 10
 	const initialBackoff = 500 * time.Millisecond
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	// Channel to receive returned messages
-	returns := p.channel.NotifyReturn(make(chan amqp.Return, 100))
+	// messages returned after their publish stopped waiting aren't matched to this publish.
+	p.drainReturns()
 
 	var lastErr error
 	backoff := initialBackoff
@@ -72,7 +88,7 @@ func (p *Publisher) PublishWithRetry(ctx context.Context, routingKey string, bod
 			true,
 			false,
 			amqp.Publishing{
-				ContentType:  "text/plain",
+				ContentType:  contentType,
 				DeliveryMode: amqp.Persistent,
 				Body:         body,
 				Timestamp:    time.Now(),
@@ -87,7 +103,7 @@ func (p *Publisher) PublishWithRetry(ctx context.Context, routingKey string, bod
 
 		// Check if the message was returned as unroutable
 		select {
-		case ret := <-returns:
+		case ret := <-p.returns:
 			logger.Printf("Message unroutable, retrying: routingKey=%s, body=%s. requeueing...", routingKey, string(ret.Body))
 			lastErr = fmt.Errorf("message unroutable")
 			time.Sleep(backoff)
@@ -101,6 +117,18 @@ func (p *Publisher) PublishWithRetry(ctx context.Context, routingKey string, bod
 	return fmt.Errorf("failed to publish message after %d attempts: last error: %v", maxRetries, lastErr)
 }
 
+// drainReturns discards the messages that were returned without a publish waiting for them.
+func (p *Publisher) drainReturns() {
+	for {
+		select {
+		case ret := <-p.returns:
+			logger.Printf("Discarding late returned message: routingKey=%s", ret.RoutingKey)
+		default:
+			return
+		}
+	}
+}
+
 // Close cleans up resources
 func (p *Publisher) Close() {
 	if p.channel != nil {
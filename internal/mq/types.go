@@ -1,9 +1,13 @@
 package mq
 
 import (
+	"context"
 	"log"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -19,12 +23,30 @@ var QueueNameError string = "error-queue"
 
 var ExchangeName string = "x-conflow"
 
+// DurableExchangeName is the exchange of the durable message queue, the exchange and the queues bound to it
+// survive restarts of the broker. it's separate from ExchangeName, since an exchange can't be redeclared
+// with a different durability.
+var DurableExchangeName string = "x-conflow-durable"
+
+// ProtobufContentType is the content type of command and result envelopes, which are encoded as
+// pb.CommandMessage and pb.CommandResult.
+const ProtobufContentType = "application/x-protobuf"
+
+// TextContentType is the content type of messages sent as bare strings with Publish.
+const TextContentType = "text/plain"
+
+// CommandPollInterval is how often a consumer with a free slot polls its empty command queue.
+const CommandPollInterval = 500 * time.Millisecond
+
 type Publisher struct {
 	conn    *amqp.Connection // Connection to RabbitMQ server
 	channel *amqp.Channel    // Channel for publishing messages
 
 	queueName    string
 	exchangeName string
+
+	mu      sync.Mutex       // serializes publishing, returned messages are matched to the publish waiting for them
+	returns chan amqp.Return // messages returned as unroutable, registered once by NewPublisher
 }
 
 type Consumer struct {
@@ -33,11 +55,31 @@ type Consumer struct {
 	publisher *Publisher
 
 	exchangeName string
-	tag          string // Consumer tag for message acknowledgment
+	tag          string        // Consumer tag for message acknowledgment
+	slots        chan struct{} // limits the commands running at once, shared by the consumers of a worker. nil for one
 }
 
 type ConsumerParams struct {
 	QueueRoutingInfo map[string]string // Stores key: queue name, val: routing key
+
+	// queues commands and their results are sent to and their routing keys, see TaskQueues.
+	// the global queues and routing keys are used if they are empty.
+	CmdQueue         string
+	OutputQueue      string
+	ErrorQueue       string
+	CmdRoutingKey    string
+	OutputRoutingKey string
+	ErrorRoutingKey  string
+
+	Expires time.Duration // the queues are deleted by the broker after being unused for Expires, 0 to keep them
+}
+
+// ConsumerServer starts a consumer for every request, the commands of all of its consumers
+// share the worker's concurrency slots.
+type ConsumerServer struct {
+	slots chan struct{}
 }
 
-type ConsumerServer struct{}
+// CommandRunner runs a command consumed from the command queue with env added to its environment,
+// calling onLine with every line it outputs. it returns the combined output of the command.
+type CommandRunner func(ctx context.Context, cmd []byte, env map[string]string, onLine process.LineFunc) (string, error)
@@ -55,7 +55,7 @@ func RunGRPCConsumerServer(portCh chan int) {
 	server := grpc.NewServer()
 
 	logger.Printf("Registering services...")
-	pb.RegisterConsumerServicerServer(server, &ConsumerServer{})
+	pb.RegisterConsumerServicerServer(server, NewConsumerServer(1))
 
 	portCh <- port
 	logger.Printf("gRPC server Listening on port %d", port)
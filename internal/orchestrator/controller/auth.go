@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const bearerPrefix = "Bearer "
+
+// Authorize verifies the request carries the API token of the config as a bearer token.
+// runs execute arbitrary commands on every worker, so requests are rejected when no token is configured.
+func Authorize(ctx *fiber.Ctx, cfg config.ValidatedConfig) error {
+	expected := cfg.GetAPIToken()
+	if expected == "" {
+		logger.Printf("Rejecting %s %s: no API token configured", ctx.Method(), ctx.Path())
+		return fiber.ErrUnauthorized
+	}
+	token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), bearerPrefix)
+	// constant time comparison, so the token can't be guessed byte by byte.
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		logger.Printf("Rejecting %s %s: missing or invalid API token", ctx.Method(), ctx.Path())
+		return fiber.ErrUnauthorized
+	}
+	return nil
+}
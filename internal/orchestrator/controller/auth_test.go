@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string // configured token
+		header         string
+		expectedStatus int
+	}{
+		{name: "valid token", token: "secret", header: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "invalid token", token: "secret", header: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "missing token", token: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "secret", header: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "no token configured", header: "Bearer ", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ValidatedConfig{Config: &config.Config{}}
+			if tt.token != "" {
+				cfg.API = &config.API{Token: tt.token}
+			}
+			app := fiber.New()
+			app.Post("/runs", func(c *fiber.Ctx) error {
+				if err := Authorize(c, cfg); err != nil {
+					return err
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/runs", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -1,57 +1,148 @@
 package controller
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
 
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
 	"github.com/ImTheCurse/ConflowCI/internal/provider/github"
-	"github.com/ImTheCurse/ConflowCI/internal/sync"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/gofiber/fiber/v2"
 )
 
 var logger = log.New(os.Stdout, "[Webhook Handler]: ", log.Lshortfile|log.LstdFlags)
 
+// HandleWebhook verifies and parses a github webhook delivery and enqueues a pipeline run for it.
+// the pipeline is executed in the background by the run manager, the handler responds
+// right away with the run ID so github dosen't time out the delivery.
 // TODO: check for private repo and token.
-func HandleWebhook(ctx *fiber.Ctx, cfg config.ValidatedConfig) error {
+func HandleWebhook(ctx *fiber.Ctx, cfg config.ValidatedConfig, manager *runner.Manager) error {
 	event := ctx.Get("X-GitHub-Event")
 	body := ctx.Body()
 
-	if event != "pull_request" {
+	// verify the delivery was signed by github before acting on it, since a pipeline
+	// runs arbitrary commands on every worker.
+	err := github.ValidateSignature(body, ctx.Get(github.SignatureHeader), cfg.GetWebhookSecret())
+	if err != nil {
+		logger.Printf("Rejecting webhook delivery: %v", err)
+		return fiber.ErrUnauthorized
+	}
+
+	var run *runner.Run
+	switch event {
+	case "pull_request":
+		run, err = pullRequestRun(cfg, body, manager)
+	case "push":
+		run, err = pushRun(cfg, body)
+	default:
 		// dosen't mean much, we are sending back to the
 		// github worker that sent us to the webhook
-		logger.Printf("Invalid event type, expected pull_request, got: %v", event)
+		logger.Printf("Invalid event type, expected pull_request or push, got: %v", event)
 		return fiber.ErrBadRequest
 	}
-	var payload github.PullRequestPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	if err != nil {
 		logger.Printf("Failed to unmarshal payload: %v", err)
 		return fiber.ErrBadRequest
 	}
+	if run == nil {
+		// the event is valid, but there is nothing to build for it.
+		return ctx.SendStatus(fiber.StatusOK)
+	}
+	// the body is only valid until the handler returns, so it's copied.
+	run.Payload = bytes.Clone(body)
 
-	refSpec := fmt.Sprintf("pull/%d/head:pr-%d", payload.Number, payload.PullRequest.ID)
-	wb := sync.NewWorkerBuilder(cfg, "origin", payload.PullRequest.OriginBranch.Ref, refSpec)
-	outputs := wb.BuildAllEndpoints()
-
-	for _, job := range cfg.Pipeline.Tasks {
-		logger.Printf("Running task: %s", job.Name)
-		te, err := sync.NewTaskExecutor(cfg, job, wb.Name)
+	// pull request payloads don't include the changed files, so we get them from github
+	// when a trigger filters by paths.
+	if run.Event == "pull_request" && runner.RequiresChangedFiles(cfg) {
+		client := github.NewClient(cfg.GetAPIURL(), cfg.GetToken())
+		files, err := client.ListPullRequestFiles(ctx.UserContext(), cfg.Provider.Github.Repository, run.PRNumber)
 		if err != nil {
-			logger.Printf("Failed to create task executor for task: %s", job.Name)
-			continue
+			logger.Printf("Failed to get changed files of pull request %d, ignoring path filters: %v", run.PRNumber, err)
+		} else {
+			run.ChangedFiles = files
 		}
-		err = te.RunTaskOnAllMachines()
-		if err != nil {
-			logger.Printf("Failed to run task: %s", job.Name)
+	}
+	if !run.Matches(cfg.Pipeline.On) {
+		logger.Printf("Ignoring %s event on %s, it dosen't match the pipeline trigger filters", run.Event, run.TargetBranch)
+		return ctx.SendStatus(fiber.StatusOK)
+	}
+
+	if err := manager.Enqueue(run); err != nil {
+		logger.Printf("Failed to enqueue run for %s event: %v", event, err)
+		return fiber.ErrServiceUnavailable
+	}
+	if run.Event == "pull_request" {
+		// runs of older commits of the pull request are superseded once the new run was accepted.
+		cancelled := manager.CancelPullRequestRuns(run.PRNumber, run.SHA)
+		if len(cancelled) > 0 {
+			logger.Printf("Cancelled %d superseded runs of pull request %d", len(cancelled), run.PRNumber)
 		}
-		logger.Printf("%s runner output: %v", job.Name, te.Outputs)
 	}
-	errs := wb.RemoveAllRepositoryWorkspaces()
 
-	logger.Printf("RemoveAllRepositoryWorkspaces errors: %v", errs)
-	logger.Printf("Build Outputs: %v", outputs)
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{"run_id": run.ID})
+}
 
-	return ctx.SendStatus(fiber.StatusOK)
+// pullRequestRun creates a run that builds the head of the pull request.
+// only opened, reopened and synchronize actions start a run, runs of older commits of the
+// pull request are cancelled once the run is enqueued. closing the pull request cancels
+// all of its runs.
+func pullRequestRun(cfg config.ValidatedConfig, body []byte, manager *runner.Manager) (*runner.Run, error) {
+	var payload github.PullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	switch payload.Action {
+	case "opened", "reopened", "synchronize":
+	case "closed":
+		cancelled := manager.CancelPullRequestRuns(payload.Number, "")
+		logger.Printf("Pull request %d closed, cancelled %d runs", payload.Number, len(cancelled))
+		return nil, nil
+	default:
+		logger.Printf("Ignoring pull request %d action: %s", payload.Number, payload.Action)
+		return nil, nil
+	}
+
+	run := runner.NewRun(cfg, "pull_request", payload.PullRequest.OriginBranch.Ref, payload.RefSpec())
+	run.PRNumber = payload.Number
+	run.SHA = payload.PullRequest.OriginBranch.SHA
+	run.TargetBranch = payload.PullRequest.TargetBranch.Ref
+	return run, nil
+}
+
+// pushRun creates a run that builds the pushed commit. without pipeline trigger filters,
+// only pushes to the branch configured in the github provider are built.
+func pushRun(cfg config.ValidatedConfig, body []byte) (*runner.Run, error) {
+	var payload github.PushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.IsDelete() {
+		logger.Printf("Ignoring push deleting %s", payload.Ref)
+		return nil, nil
+	}
+	branch, tag := payload.Branch(), payload.Tag()
+	if branch == "" && tag == "" {
+		logger.Printf("Ignoring push to %s, not a branch or a tag", payload.Ref)
+		return nil, nil
+	}
+	if cfg.Pipeline.On == nil && branch != cfg.Provider.Github.Branch {
+		logger.Printf("Ignoring push to %s, only pushes to branch %s are built", payload.Ref, cfg.Provider.Github.Branch)
+		return nil, nil
+	}
+
+	// a tag's commit is fetched by its SHA on top of a clone of the provider branch.
+	cloneBranch := branch
+	if tag != "" {
+		cloneBranch = cfg.Provider.Github.Branch
+	}
+	run := runner.NewRun(cfg, "push", cloneBranch, payload.RefSpec())
+	run.SHA = payload.After
+	run.TargetBranch = branch
+	run.Tag = tag
+	if files := payload.ChangedFiles(); len(files) > 0 {
+		run.ChangedFiles = files
+	}
+	return run, nil
 }
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/internal/provider/github"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const testSecret = "webhook-secret"
+
+// newTestApp creates an app serving the webhook handler, the run manager isn't started
+// so enqueued runs stay queued and no build is started.
+func newTestApp(opts ...func(*config.Config)) (*fiber.App, *runner.Manager) {
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{
+			Provider: config.Provider{
+				Github: config.Github{
+					Repository:    "org/repo-name",
+					Branch:        "main",
+					WebhookSecret: testSecret,
+				},
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg.Config)
+	}
+	manager := runner.NewManager(1, 10, nil)
+	app := fiber.New()
+	app.Post("/github/webhook", func(c *fiber.Ctx) error {
+		return HandleWebhook(c, cfg, manager)
+	})
+	return app, manager
+}
+
+func sendWebhook(t *testing.T, app *fiber.App, event string, body []byte, signature string) *http.Response {
+	req := httptest.NewRequest("POST", "/github/webhook", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	if signature != "" {
+		req.Header.Set(github.SignatureHeader, signature)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	return resp
+}
+
+// enqueuedRun decodes the run ID from an accepted webhook response, and returns the enqueued run.
+func enqueuedRun(t *testing.T, resp *http.Response, manager *runner.Manager) *runner.Run {
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusAccepted, resp.StatusCode)
+	}
+	var res struct {
+		RunID uuid.UUID `json:"run_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	run, ok := manager.Get(res.RunID)
+	if !ok {
+		t.Fatalf("Expected run %s to be enqueued", res.RunID)
+	}
+	if run.GetState() != runner.QueuedRun {
+		t.Errorf("Expected run state %v, got %v", runner.QueuedRun, run.GetState())
+	}
+	return run
+}
+
+func TestHandleWebhookSignature(t *testing.T) {
+	app, _ := newTestApp()
+	body := []byte(`{"action":"opened","number":1}`)
+	tests := []struct {
+		name       string
+		event      string
+		signature  string
+		wantStatus int
+	}{
+		{
+			name:       "unsigned-delivery",
+			event:      "pull_request",
+			signature:  "",
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "mis-signed-delivery",
+			event:      "pull_request",
+			signature:  github.Sign(body, "not-the-secret"),
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			// a correctly signed delivery passes verification and is rejected
+			// later on for its event type, so no build is started.
+			name:       "signed-delivery-unsupported-event",
+			event:      "issues",
+			signature:  github.Sign(body, testSecret),
+			wantStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendWebhook(t, app, tt.event, body, tt.signature)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookEnqueuesRun(t *testing.T) {
+	app, manager := newTestApp()
+	body := []byte(`{"action":"opened","number":42,"pull_request":{"id":7,"head":{"ref":"feature","sha":"abc1234"}}}`)
+	resp := sendWebhook(t, app, "pull_request", body, github.Sign(body, testSecret))
+
+	run := enqueuedRun(t, resp, manager)
+	if run.PRNumber != 42 || run.Branch != "feature" || run.BranchRef != "pull/42/head:pr-7" || run.SHA != "abc1234" {
+		t.Errorf("Unexpected run fields: number %d, branch %s, ref %s, sha %s",
+			run.PRNumber, run.Branch, run.BranchRef, run.SHA)
+	}
+}
+
+func TestHandleWebhookPush(t *testing.T) {
+	app, manager := newTestApp()
+	sha := "b3cbd5bbd7e81436d2eee04537ea2b4c0cad4cdf"
+
+	body := []byte(`{"ref":"refs/heads/main","after":"` + sha + `"}`)
+	resp := sendWebhook(t, app, "push", body, github.Sign(body, testSecret))
+	run := enqueuedRun(t, resp, manager)
+	if run.Event != "push" || run.Branch != "main" || run.SHA != sha || run.BranchRef != sha+":push-b3cbd5b" {
+		t.Errorf("Unexpected run fields: event %s, branch %s, ref %s, sha %s", run.Event, run.Branch, run.BranchRef, run.SHA)
+	}
+
+	ignored := []struct {
+		name string
+		body string
+	}{
+		{name: "push-to-other-branch", body: `{"ref":"refs/heads/feature","after":"` + sha + `"}`},
+		{name: "tag-push", body: `{"ref":"refs/tags/v1.0.0","after":"` + sha + `"}`},
+		{name: "branch-delete", body: `{"ref":"refs/heads/main","deleted":true,"after":"0000000000000000000000000000000000000000"}`},
+	}
+	for _, tt := range ignored {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(tt.body)
+			resp := sendWebhook(t, app, "push", body, github.Sign(body, testSecret))
+			if resp.StatusCode != fiber.StatusOK {
+				t.Errorf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookTriggerFilters(t *testing.T) {
+	// stub github API, listing the files changed by pull request 1 and 2.
+	changedFiles := map[string]string{
+		"/repos/org/repo-name/pulls/1/files": `[{"filename":"docs/README.md"}]`,
+		"/repos/org/repo-name/pulls/2/files": `[{"filename":"internal/sync/build.go"}]`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files, ok := changedFiles[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, files)
+	}))
+	defer api.Close()
+
+	app, manager := newTestApp(func(cfg *config.Config) {
+		cfg.Provider.Github.APIURL = api.URL
+		cfg.Pipeline.On = &config.Trigger{
+			Branches: []string{"main", "release/*"},
+			Tags:     []string{"v*"},
+			Paths:    []string{"**/*.go"},
+		}
+	})
+	pr := func(number int, target string) []byte {
+		return []byte(fmt.Sprintf(`{"action":"opened","number":%d,"pull_request":{"id":7,"head":{"ref":"feature"},"base":{"ref":"%s"}}}`,
+			number, target))
+	}
+	sha := "b3cbd5bbd7e81436d2eee04537ea2b4c0cad4cdf"
+
+	tests := []struct {
+		name       string
+		event      string
+		body       []byte
+		wantStatus int
+	}{
+		{name: "pr-changing-go-files", event: "pull_request", body: pr(2, "main"), wantStatus: fiber.StatusAccepted},
+		{name: "pr-to-release-branch", event: "pull_request", body: pr(2, "release/1.0"), wantStatus: fiber.StatusAccepted},
+		{name: "pr-to-other-branch", event: "pull_request", body: pr(2, "dev"), wantStatus: fiber.StatusOK},
+		{name: "pr-changing-docs-only", event: "pull_request", body: pr(1, "main"), wantStatus: fiber.StatusOK},
+		{
+			name:  "tag-push",
+			event: "push",
+			body: []byte(`{"ref":"refs/tags/v1.0.0","after":"` + sha +
+				`","commits":[{"modified":["cmd/worker/main.go"]}]}`),
+			wantStatus: fiber.StatusAccepted,
+		},
+		{
+			name:  "push-changing-docs-only",
+			event: "push",
+			body: []byte(`{"ref":"refs/heads/main","after":"` + sha +
+				`","commits":[{"added":["docs/guide.md"]}]}`),
+			wantStatus: fiber.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendWebhook(t, app, tt.event, tt.body, github.Sign(tt.body, testSecret))
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus == fiber.StatusAccepted {
+				enqueuedRun(t, resp, manager)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookPullRequestActions(t *testing.T) {
+	app, manager := newTestApp()
+	prBody := func(action, sha string) []byte {
+		return []byte(fmt.Sprintf(`{"action":"%s","number":5,"pull_request":{"id":7,"head":{"ref":"feature","sha":"%s"}}}`,
+			action, sha))
+	}
+	send := func(action, sha string) *http.Response {
+		body := prBody(action, sha)
+		return sendWebhook(t, app, "pull_request", body, github.Sign(body, testSecret))
+	}
+
+	for _, action := range []string{"labeled", "edited", "assigned"} {
+		if resp := send(action, "aaa"); resp.StatusCode != fiber.StatusOK {
+			t.Errorf("Expected action %s to be ignored with status %d, got %d", action, fiber.StatusOK, resp.StatusCode)
+		}
+	}
+
+	first := enqueuedRun(t, send("opened", "aaa"), manager)
+	second := enqueuedRun(t, send("synchronize", "bbb"), manager)
+	if first.Context().Err() == nil {
+		t.Errorf("Expected run of superseded commit to be cancelled")
+	}
+	if second.Context().Err() != nil {
+		t.Errorf("Expected run of the new commit to not be cancelled")
+	}
+
+	if resp := send("closed", "bbb"); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status %d on close, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if second.Context().Err() == nil {
+		t.Errorf("Expected runs to be cancelled when the pull request is closed")
+	}
+}
+
+func TestHandleWebhookFilteredPullRequestKeepsRuns(t *testing.T) {
+	app, manager := newTestApp(func(cfg *config.Config) {
+		cfg.Pipeline.On = &config.Trigger{Branches: []string{"main"}}
+	})
+	send := func(action, sha, target string) *http.Response {
+		body := []byte(fmt.Sprintf(`{"action":"%s","number":5,"pull_request":{"id":7,"head":{"ref":"feature","sha":"%s"},"base":{"ref":"%s"}}}`,
+			action, sha, target))
+		return sendWebhook(t, app, "pull_request", body, github.Sign(body, testSecret))
+	}
+
+	first := enqueuedRun(t, send("opened", "aaa", "main"), manager)
+	// the new commit dosen't match the pipeline trigger, so no run replaces the first one.
+	if resp := send("synchronize", "bbb", "dev"); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected the filtered event to be ignored with status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if first.Context().Err() != nil {
+		t.Errorf("Expected the run to not be cancelled by an event that didn't start a run")
+	}
+}
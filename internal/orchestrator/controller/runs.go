@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// defaultRunsLimit is the amount of runs listed when no limit is given.
+const defaultRunsLimit = 50
+
+// planTimeout is how long resolving the files of the tasks of a plan may take.
+const planTimeout = 30 * time.Second
+
+// CreateRun starts a pipeline run manually, without a webhook event. the request body is an api.RunRequest.
+func CreateRun(ctx *fiber.Ctx, cfg config.ValidatedConfig, manager *runner.Manager) error {
+	var req api.RunRequest
+	if err := json.Unmarshal(ctx.Body(), &req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid run request: "+err.Error())
+	}
+	run, err := runner.NewManualRun(cfg, req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	// the body is only valid until the handler returns, so it's copied.
+	run.Payload = bytes.Clone(ctx.Body())
+
+	if err := manager.Enqueue(run); err != nil {
+		logger.Printf("Failed to enqueue manual run: %v", err)
+		return fiber.ErrServiceUnavailable
+	}
+	return ctx.Status(fiber.StatusAccepted).JSON(api.RunResponse{RunID: run.ID})
+}
+
+// PlanRun responds with an api.Plan of what a manual run of the request would execute, without
+// starting the run. the request body is an api.RunRequest, like CreateRun's.
+func PlanRun(ctx *fiber.Ctx, cfg config.ValidatedConfig) error {
+	var req api.RunRequest
+	if err := json.Unmarshal(ctx.Body(), &req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid run request: "+err.Error())
+	}
+	run, err := runner.NewManualRun(cfg, req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	planCtx, cancel := context.WithTimeout(ctx.UserContext(), planTimeout)
+	defer cancel()
+	return ctx.JSON(run.Plan(planCtx))
+}
+
+// ListRuns responds with the runs matching the query, newest first.
+// supported query parameters: pr, branch, state, since (RFC3339) and limit.
+func ListRuns(ctx *fiber.Ctx, manager *runner.Manager) error {
+	filter := store.RunFilter{
+		Branch: ctx.Query("branch"),
+		State:  ctx.Query("state"),
+		Limit:  defaultRunsLimit,
+	}
+	var err error
+	if pr := ctx.Query("pr"); pr != "" {
+		if filter.PRNumber, err = strconv.Atoi(pr); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid pr number: "+pr)
+		}
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid limit: "+limit)
+		}
+	}
+	if since := ctx.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid since, expected RFC3339 time: "+since)
+		}
+	}
+
+	runs, err := manager.ListRecords(filter)
+	if err != nil {
+		logger.Printf("Failed to list runs: %v", err)
+		return fiber.ErrInternalServerError
+	}
+	// payloads are large, they are only included when getting a single run.
+	for i := range runs {
+		runs[i].Payload = nil
+	}
+	return ctx.JSON(runs)
+}
+
+// GetRun responds with a single run, including its builds and tasks.
+func GetRun(ctx *fiber.Ctx, manager *runner.Manager) error {
+	rec, err := getRecord(ctx, manager)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(rec)
+}
+
+// GetRunTask responds with the result of a single task of a run.
+func GetRunTask(ctx *fiber.Ctx, manager *runner.Manager) error {
+	rec, err := getRecord(ctx, manager)
+	if err != nil {
+		return err
+	}
+	name := ctx.Params("name")
+	for _, task := range rec.Tasks {
+		if task.Name == name {
+			return ctx.JSON(task)
+		}
+	}
+	return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("task %s not found in run %s", name, rec.ID))
+}
+
+// GetRunLogs responds with the install, build and task outputs of a run as plain text.
+func GetRunLogs(ctx *fiber.Ctx, manager *runner.Manager) error {
+	rec, err := getRecord(ctx, manager)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, install := range rec.Installs {
+		if install.Skipped {
+			fmt.Fprintf(&b, "==> install on %s: skipped, already installed\n", install.Worker)
+			continue
+		}
+		fmt.Fprintf(&b, "==> install on %s\n", install.Worker)
+		writeLines(&b, install.Output)
+		if install.Error != "" {
+			writeLines(&b, "error: "+install.Error)
+		}
+	}
+	for _, build := range rec.Builds {
+		fmt.Fprintf(&b, "==> build on %s\n", build.Worker)
+		writeLines(&b, build.Output)
+		if build.Error != "" {
+			writeLines(&b, "error: "+build.Error)
+		}
+	}
+	for _, task := range rec.Tasks {
+		fmt.Fprintf(&b, "==> task %s: %s\n", task.Name, task.State)
+		if len(task.Results) > 0 {
+			// the result of every command, with the host it ran on.
+			for _, res := range task.Results {
+				fmt.Fprintf(&b, "$ %s [host %s, exit code %d, %s]\n", res.Command, res.Host, res.ExitCode,
+					res.FinishedAt.Sub(res.StartedAt).Round(time.Millisecond))
+				writeLines(&b, res.Output)
+				if res.Error != "" {
+					writeLines(&b, "error: "+res.Error)
+				}
+			}
+			continue
+		}
+		for _, cmd := range task.Commands {
+			writeLines(&b, "$ "+cmd)
+		}
+		for _, output := range task.Outputs {
+			writeLines(&b, output)
+		}
+		for _, e := range task.Errors {
+			writeLines(&b, "error: "+e)
+		}
+	}
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return ctx.SendString(b.String())
+}
+
+// CancelRun cancels a queued or running run.
+func CancelRun(ctx *fiber.Ctx, manager *runner.Manager) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid run id: "+ctx.Params("id"))
+	}
+	run, err := manager.CancelRun(id)
+	switch err {
+	case nil:
+	case runner.ErrRunNotFound:
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case runner.ErrRunFinished:
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{"run_id": run.ID, "state": run.GetState().String()})
+}
+
+// getRecord returns the record of the run in the id path parameter, or a fiber error.
+func getRecord(ctx *fiber.Ctx, manager *runner.Manager) (store.RunRecord, error) {
+	id, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return store.RunRecord{}, fiber.NewError(fiber.StatusBadRequest, "invalid run id: "+ctx.Params("id"))
+	}
+	rec, err := manager.GetRecord(id)
+	if err == runner.ErrRunNotFound {
+		return rec, fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		logger.Printf("Failed to get run %s: %v", id, err)
+		return rec, fiber.ErrInternalServerError
+	}
+	return rec, nil
+}
+
+// writeLines writes s to b, ending it with a newline.
+func writeLines(b *strings.Builder, s string) {
+	if s == "" {
+		return
+	}
+	b.WriteString(s)
+	if !strings.HasSuffix(s, "\n") {
+		b.WriteString("\n")
+	}
+}
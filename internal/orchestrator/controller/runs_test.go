@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// newRunsTestApp creates an app serving the runs API, backed by a run history with a finished run
+// of pull request 42. the manager isn't started so enqueued runs stay queued.
+func newRunsTestApp(t *testing.T) (*fiber.App, *runner.Manager, store.RunRecord) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "runs.db"), 0)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	startedAt := time.Now().Add(-24 * time.Hour)
+	finished := store.RunRecord{
+		ID:         uuid.New(),
+		State:      runner.FailedRun.String(),
+		Event:      "pull_request",
+		Branch:     "feature",
+		PRNumber:   42,
+		CreatedAt:  time.Now().Add(-24 * time.Hour),
+		FinishedAt: time.Now().Add(-23 * time.Hour),
+		Builds:     []store.BuildRecord{{Worker: "worker-1", Output: "built"}},
+		Tasks: []store.TaskRecord{{
+			Name:     "test",
+			State:    "Completed with errors",
+			Commands: []string{"go test ./..."},
+			Outputs:  []string{"ok  pkg/a"},
+			Errors:   []string{"FAIL pkg/b"},
+		}, {
+			Name:     "lint",
+			State:    "Completed with errors",
+			Commands: []string{"golangci-lint run"},
+			Errors:   []string{"main.go:1: unused"},
+			Results: []store.CommandRecord{{
+				ID: "1", Command: "golangci-lint run", Host: "worker-2", ExitCode: 1,
+				StartedAt: startedAt, FinishedAt: startedAt.Add(2 * time.Second),
+				Output: "main.go:1: unused", Error: "exit status 1",
+			}},
+		}},
+		Payload: []byte(`{"number":42}`),
+	}
+	if err := st.SaveRun(finished); err != nil {
+		t.Fatalf("Failed to save run: %v", err)
+	}
+
+	manager := runner.NewManager(1, 10, st)
+	app := fiber.New()
+	app.Get("/runs", func(c *fiber.Ctx) error { return ListRuns(c, manager) })
+	app.Get("/runs/:id", func(c *fiber.Ctx) error { return GetRun(c, manager) })
+	app.Get("/runs/:id/tasks/:name", func(c *fiber.Ctx) error { return GetRunTask(c, manager) })
+	app.Get("/runs/:id/logs", func(c *fiber.Ctx) error { return GetRunLogs(c, manager) })
+	app.Get("/runs/:id/logs/stream", func(c *fiber.Ctx) error { return StreamRunLogs(c, manager) })
+	app.Post("/runs/:id/cancel", func(c *fiber.Ctx) error { return CancelRun(c, manager) })
+	return app, manager, finished
+}
+
+func request(t *testing.T, app *fiber.App, method, url string) (*http.Response, []byte) {
+	resp, err := app.Test(httptest.NewRequest(method, url, nil))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp, b
+}
+
+func TestListRuns(t *testing.T) {
+	app, manager, finished := newRunsTestApp(t)
+	queued := runner.NewRun(config.ValidatedConfig{}, "push", "main", "abc:push-abc")
+	if err := manager.Enqueue(queued); err != nil {
+		t.Fatalf("Failed to enqueue run: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		status   int
+		expected []uuid.UUID
+	}{
+		{url: "/runs", status: fiber.StatusOK, expected: []uuid.UUID{queued.ID, finished.ID}},
+		{url: "/runs?pr=42", status: fiber.StatusOK, expected: []uuid.UUID{finished.ID}},
+		{url: "/runs?state=Queued", status: fiber.StatusOK, expected: []uuid.UUID{queued.ID}},
+		{url: "/runs?limit=1", status: fiber.StatusOK, expected: []uuid.UUID{queued.ID}},
+		{url: "/runs?branch=main", status: fiber.StatusOK, expected: []uuid.UUID{queued.ID}},
+		{url: "/runs?pr=abc", status: fiber.StatusBadRequest},
+		{url: "/runs?limit=0", status: fiber.StatusBadRequest},
+		{url: "/runs?since=yesterday", status: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			resp, body := request(t, app, "GET", tt.url)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, resp.StatusCode, body)
+			}
+			if tt.status != fiber.StatusOK {
+				return
+			}
+			var runs []store.RunRecord
+			if err := json.Unmarshal(body, &runs); err != nil {
+				t.Fatalf("Failed to decode runs: %v", err)
+			}
+			if len(runs) != len(tt.expected) {
+				t.Fatalf("Expected %d runs, got %d", len(tt.expected), len(runs))
+			}
+			for i, run := range runs {
+				if run.ID != tt.expected[i] {
+					t.Errorf("Expected run %d to be %s, got %s", i, tt.expected[i], run.ID)
+				}
+				if run.Payload != nil {
+					t.Errorf("Expected payload to be omitted from the list")
+				}
+			}
+		})
+	}
+}
+
+func TestGetRun(t *testing.T) {
+	app, _, finished := newRunsTestApp(t)
+
+	resp, body := request(t, app, "GET", "/runs/"+finished.ID.String())
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	var rec store.RunRecord
+	if err := json.Unmarshal(body, &rec); err != nil {
+		t.Fatalf("Failed to decode run: %v", err)
+	}
+	if rec.ID != finished.ID || rec.PRNumber != 42 || string(rec.Payload) != `{"number":42}` {
+		t.Errorf("Unexpected run: %+v", rec)
+	}
+
+	resp, body = request(t, app, "GET", "/runs/"+finished.ID.String()+"/tasks/test")
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	var task store.TaskRecord
+	if err := json.Unmarshal(body, &task); err != nil {
+		t.Fatalf("Failed to decode task: %v", err)
+	}
+	if task.Name != "test" || task.Errors[0] != "FAIL pkg/b" {
+		t.Errorf("Unexpected task: %+v", task)
+	}
+
+	resp, body = request(t, app, "GET", "/runs/"+finished.ID.String()+"/logs")
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	for _, line := range []string{"==> build on worker-1", "built", "==> task test: Completed with errors", "$ go test ./...", "ok  pkg/a", "error: FAIL pkg/b",
+		"$ golangci-lint run [host worker-2, exit code 1, 2s]", "main.go:1: unused", "error: exit status 1"} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Expected logs to contain %q, got:\n%s", line, body)
+		}
+	}
+
+	notFound := []string{
+		"/runs/" + uuid.NewString(),
+		"/runs/" + finished.ID.String() + "/tasks/missing",
+	}
+	for _, url := range notFound {
+		if resp, _ := request(t, app, "GET", url); resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("Expected status %d for %s, got %d", fiber.StatusNotFound, url, resp.StatusCode)
+		}
+	}
+	if resp, _ := request(t, app, "GET", "/runs/not-a-uuid"); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestCancelRun(t *testing.T) {
+	app, manager, finished := newRunsTestApp(t)
+	queued := runner.NewRun(config.ValidatedConfig{}, "push", "main", "abc:push-abc")
+	if err := manager.Enqueue(queued); err != nil {
+		t.Fatalf("Failed to enqueue run: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{name: "queued run", id: queued.ID.String(), status: fiber.StatusAccepted},
+		{name: "finished run", id: finished.ID.String(), status: fiber.StatusConflict},
+		{name: "unknown run", id: uuid.NewString(), status: fiber.StatusNotFound},
+		{name: "invalid id", id: "not-a-uuid", status: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := request(t, app, "POST", "/runs/"+tt.id+"/cancel")
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, resp.StatusCode, body)
+			}
+		})
+	}
+	if queued.Context().Err() == nil {
+		t.Errorf("Expected queued run to be cancelled")
+	}
+}
+
+func TestCreateRun(t *testing.T) {
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{
+			Provider: config.Provider{Github: config.Github{Branch: "main"}},
+			Pipeline: config.Pipeline{Tasks: []config.TaskConsumerJobs{{Name: "test"}}},
+		},
+	}
+	manager := runner.NewManager(1, 10, nil)
+	app := fiber.New()
+	app.Post("/runs", func(c *fiber.Ctx) error { return CreateRun(c, cfg, manager) })
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "branch", body: `{"branch":"feature","tasks":["test"]}`, status: fiber.StatusAccepted},
+		{name: "default branch", body: `{}`, status: fiber.StatusAccepted},
+		{name: "invalid json", body: `{"branch":`, status: fiber.StatusBadRequest},
+		{name: "unknown task", body: `{"tasks":["missing"]}`, status: fiber.StatusBadRequest},
+		{name: "invalid sha", body: `{"sha":"abc"}`, status: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/runs", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				b, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, resp.StatusCode, b)
+			}
+			if tt.status != fiber.StatusAccepted {
+				return
+			}
+			run := enqueuedRun(t, resp, manager)
+			if run.Event != runner.ManualEvent || string(run.Payload) != tt.body {
+				t.Errorf("Unexpected run: event %s, payload %s", run.Event, run.Payload)
+			}
+		})
+	}
+}
+
+func TestPlanRun(t *testing.T) {
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{
+			Provider: config.Provider{Github: config.Github{Branch: "main"}},
+			Pipeline: config.Pipeline{Tasks: []config.TaskConsumerJobs{
+				{Name: "test", RunsOn: []string{"node"}, File: []string{"a.sh"}, Commands: []string{"sh {file}"}},
+			}},
+		},
+		Endpoints: []config.EndpointInfo{{Name: "node"}},
+	}
+	app := fiber.New()
+	app.Post("/runs/plan", func(c *fiber.Ctx) error { return PlanRun(c, cfg) })
+
+	req := httptest.NewRequest("POST", "/runs/plan", strings.NewReader(`{"tasks":["test"]}`))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	var plan api.Plan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("Failed to decode plan: %v", err)
+	}
+	if len(plan.Tasks) != 1 || !plan.Tasks[0].Run || len(plan.Tasks[0].Commands) != 1 ||
+		!strings.HasSuffix(plan.Tasks[0].Commands[0], "a.sh") {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+
+	req = httptest.NewRequest("POST", "/runs/plan", strings.NewReader(`{"tasks":["missing"]}`))
+	if resp, err = app.Test(req); err != nil || resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown task, got %v %v", fiber.StatusBadRequest, resp.StatusCode, err)
+	}
+}
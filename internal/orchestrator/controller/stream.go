@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle log stream, so proxies
+// don't close the connection and disconnected clients are noticed.
+const keepAliveInterval = 15 * time.Second
+
+// StreamRunLogs streams the output of a run as server-sent events, starting with the lines
+// output so far. every line is sent as a "log" event with a JSON encoded api.LogLine,
+// and an "end" event with an api.LogEnd is sent once the run finished.
+// the task query parameter streams only the lines of a single task, use "build" for the build output.
+// finished runs from the run history have no live output, their recorded output is streamed at once.
+func StreamRunLogs(ctx *fiber.Ctx, manager *runner.Manager) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid run id: "+ctx.Params("id"))
+	}
+	source := ctx.Query("task")
+	run, ok := manager.Get(id)
+	if !ok {
+		rec, err := getRecord(ctx, manager)
+		if err != nil {
+			return err
+		}
+		return streamRecordLogs(ctx, rec, source)
+	}
+	setEventStreamHeaders(ctx)
+
+	history, lines, unsubscribe := run.SubscribeLogs()
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		for _, line := range history {
+			if source == "" || line.Source == source {
+				writeEvent(w, "log", line)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					writeEvent(w, "end", api.LogEnd{State: run.GetState().String()})
+					w.Flush()
+					return
+				}
+				if source != "" && line.Source != source {
+					continue
+				}
+				writeEvent(w, "log", line)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			// flushing fails once the client disconnected.
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// streamRecordLogs streams the recorded output of a run from the run history, followed by the "end" event.
+func streamRecordLogs(ctx *fiber.Ctx, rec store.RunRecord, source string) error {
+	setEventStreamHeaders(ctx)
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		for _, line := range recordLogLines(rec) {
+			if source == "" || line.Source == source {
+				writeEvent(w, "log", line)
+			}
+		}
+		writeEvent(w, "end", api.LogEnd{State: rec.State})
+		w.Flush()
+	})
+	return nil
+}
+
+// recordLogLines returns the install, build and task outputs of a recorded run as log lines.
+func recordLogLines(rec store.RunRecord) []api.LogLine {
+	lines := []api.LogLine{}
+	add := func(t time.Time, source, worker, output string) {
+		for line := range strings.Lines(output) {
+			lines = append(lines, api.LogLine{Time: t, Source: source, Worker: worker, Line: strings.TrimRight(line, "\r\n")})
+		}
+	}
+	for _, install := range rec.Installs {
+		add(rec.StartedAt, runner.InstallLogSource, install.Worker, install.Output)
+	}
+	for _, build := range rec.Builds {
+		add(rec.StartedAt, runner.BuildLogSource, build.Worker, build.Output)
+	}
+	for _, task := range rec.Tasks {
+		if len(task.Results) > 0 {
+			for _, res := range task.Results {
+				add(res.FinishedAt, task.Name, res.Host, res.Output)
+			}
+			continue
+		}
+		for _, output := range task.Outputs {
+			add(task.FinishedAt, task.Name, "", output)
+		}
+	}
+	return lines
+}
+
+func setEventStreamHeaders(ctx *fiber.Ctx) {
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+}
+
+// writeEvent writes a server-sent event with data encoded as JSON.
+func writeEvent(w *bufio.Writer, event string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	events := []sseEvent{}
+	var ev sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && ev.name != "":
+			events = append(events, ev)
+			ev = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	return events
+}
+
+func TestStreamRunLogs(t *testing.T) {
+	manager := runner.NewManager(1, 10, nil)
+	app := fiber.New()
+	app.Get("/runs/:id/logs/stream", func(c *fiber.Ctx) error { return StreamRunLogs(c, manager) })
+
+	run := runner.NewRun(config.ValidatedConfig{}, "push", "main", "abc:push-abc")
+	if err := manager.Enqueue(run); err != nil {
+		t.Fatalf("Failed to enqueue run: %v", err)
+	}
+	run.AppendLog(runner.BuildLogSource, "worker-1", "building")
+	run.AppendLog("test", "worker-1", "ok pkg/a")
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result)
+	go func() {
+		req := httptest.NewRequest("GET", "/runs/"+run.ID.String()+"/logs/stream?task=test", nil)
+		resp, err := app.Test(req, -1)
+		results <- result{resp, err}
+	}()
+
+	// lines written while the client is connected are streamed, the stream ends with the run.
+	time.Sleep(100 * time.Millisecond)
+	run.AppendLog("test", "worker-2", "ok pkg/b")
+	run.AppendLog("lint", "worker-2", "filtered out")
+	run.Cancel()
+	manager.Start()
+	manager.Close()
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("Failed to send request: %v", res.err)
+	}
+	if ct := res.resp.Header.Get(fiber.HeaderContentType); ct != "text/event-stream" {
+		t.Errorf("Expected event stream content type, got: %s", ct)
+	}
+	events := readEvents(t, res.resp)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got: %v", events)
+	}
+	for i, expected := range []string{"ok pkg/a", "ok pkg/b"} {
+		var line api.LogLine
+		if err := json.Unmarshal([]byte(events[i].data), &line); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+		if events[i].name != "log" || line.Source != "test" || line.Line != expected {
+			t.Errorf("Expected log event with line %q, got: %v", expected, events[i])
+		}
+	}
+	if events[2].name != "end" || !strings.Contains(events[2].data, runner.CancelledRun.String()) {
+		t.Errorf("Expected end event with the run state, got: %v", events[2])
+	}
+}
+
+func TestStreamRunLogsNotFound(t *testing.T) {
+	manager := runner.NewManager(1, 10, nil)
+	app := fiber.New()
+	app.Get("/runs/:id/logs/stream", func(c *fiber.Ctx) error { return StreamRunLogs(c, manager) })
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/runs/"+uuid.NewString()+"/logs/stream", nil))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestStreamRunLogsFromHistory(t *testing.T) {
+	app, _, finished := newRunsTestApp(t)
+	resp, err := app.Test(httptest.NewRequest("GET", "/runs/"+finished.ID.String()+"/logs/stream?task=lint", nil), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	events := readEvents(t, resp)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got: %v", events)
+	}
+	var line api.LogLine
+	if err := json.Unmarshal([]byte(events[0].data), &line); err != nil {
+		t.Fatalf("Failed to decode line: %v", err)
+	}
+	if events[0].name != "log" || line.Source != "lint" || line.Worker != "worker-2" || line.Line != "main.go:1: unused" {
+		t.Errorf("Expected the recorded output of the task, got: %v", events[0])
+	}
+	if events[1].name != "end" || !strings.Contains(events[1].data, runner.FailedRun.String()) {
+		t.Errorf("Expected end event with the recorded state, got: %v", events[1])
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/ImTheCurse/ConflowCI/pkg/ssh"
+	"github.com/gofiber/fiber/v2"
+)
+
+// pingTimeout is how long a worker has to accept a connection before it's reported offline.
+const pingTimeout = 3 * time.Second
+
+// ListWorkers responds with the workers of the config, and if they're reachable.
+// hosts using the ssh executor are reachable if their ssh server accepts the connection.
+func ListWorkers(ctx *fiber.Ctx, cfg config.ValidatedConfig) error {
+	workers := make([]api.Worker, len(cfg.Endpoints))
+	// the request's context is taken once, fiber's context isn't safe to use from the pinging goroutines.
+	reqCtx := ctx.UserContext()
+	var wg sync.WaitGroup
+	for i, ep := range cfg.Endpoints {
+		workers[i] = api.Worker{Name: ep.Name, Address: ep.GetEndpointURL()}
+		if ep.IsSSH() {
+			workers[i].Address = ep.GetSSHURL()
+		}
+		wg.Add(1)
+		go func(w *api.Worker) {
+			defer wg.Done()
+			if err := pingEndpoint(reqCtx, ep, w.Address); err != nil {
+				logger.Printf("Worker %s at %s is unreachable: %v", w.Name, w.Address, err)
+				w.Error = err.Error()
+				return
+			}
+			w.Online = true
+		}(&workers[i])
+	}
+	wg.Wait()
+	return ctx.JSON(workers)
+}
+
+// pingEndpoint checks the endpoint at addr accepts connections, over ssh if it uses the ssh executor.
+func pingEndpoint(ctx context.Context, ep config.EndpointInfo, addr string) error {
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if ep.IsSSH() {
+		conn, err := ssh.DialEndpointContext(pingCtx, ep)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return grpc.Ping(pingCtx, addr)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	grpcUtil "github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+)
+
+func TestListWorkers(t *testing.T) {
+	grpcUtil.DefineFlags()
+	*grpcUtil.TlsFlag = false
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on tcp: %v", err)
+	}
+	server := grpc.NewServer()
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on tcp: %v", err)
+	}
+	closed.Close()
+
+	online := lis.Addr().(*net.TCPAddr)
+	offline := closed.Addr().(*net.TCPAddr)
+	cfg := config.ValidatedConfig{Endpoints: []config.EndpointInfo{
+		{Name: "online", Host: "127.0.0.1", Port: uint16(online.Port)},
+		{Name: "offline", Host: "127.0.0.1", Port: uint16(offline.Port)},
+	}}
+
+	app := fiber.New()
+	app.Get("/workers", func(c *fiber.Ctx) error { return ListWorkers(c, cfg) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/workers", nil), 10_000)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	var workers []api.Worker
+	if err := json.NewDecoder(resp.Body).Decode(&workers); err != nil {
+		t.Fatalf("Failed to decode workers: %v", err)
+	}
+	if len(workers) != 2 {
+		t.Fatalf("Expected 2 workers, got %d", len(workers))
+	}
+	if workers[0].Name != "online" || !workers[0].Online || workers[0].Error != "" {
+		t.Errorf("Expected online worker to be reachable, got %+v", workers[0])
+	}
+	if workers[1].Name != "offline" || workers[1].Online || workers[1].Error == "" {
+		t.Errorf("Expected offline worker to be unreachable, got %+v", workers[1])
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"os"
 
 	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/controller"
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/runner"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/gofiber/fiber/v2"
 )
 
 var logger = log.New(os.Stdout, "[Routes]: ", log.Lshortfile|log.LstdFlags)
 
-func TaskRouter(router fiber.Router, filename string) {
+func TaskRouter(router fiber.Router, filename string, manager *runner.Manager) {
 	router.Post("webhook", func(c *fiber.Ctx) error {
 		logger.Println("Getting config...")
 		cfg, err := config.GetConfig(filename)
@@ -19,6 +20,75 @@ func TaskRouter(router fiber.Router, filename string) {
 			logger.Printf("Error getting config, got: %v", err)
 			return err
 		}
-		return controller.HandleWebhook(c, *cfg)
+		return controller.HandleWebhook(c, *cfg, manager)
+	})
+}
+
+// RunRouter serves the runs API, used to start, inspect and control pipeline runs.
+// every request requires the API token of the config, runs include their payload and logs.
+func RunRouter(router fiber.Router, filename string, manager *runner.Manager) {
+	router.Use(authorize(filename))
+	router.Post("/", func(c *fiber.Ctx) error {
+		cfg, err := config.GetConfig(filename)
+		if err != nil {
+			logger.Printf("Error getting config, got: %v", err)
+			return err
+		}
+		return controller.CreateRun(c, *cfg, manager)
+	})
+	router.Post("/plan", func(c *fiber.Ctx) error {
+		cfg, err := config.GetConfig(filename)
+		if err != nil {
+			logger.Printf("Error getting config, got: %v", err)
+			return err
+		}
+		return controller.PlanRun(c, *cfg)
+	})
+	router.Get("/", func(c *fiber.Ctx) error {
+		return controller.ListRuns(c, manager)
+	})
+	router.Get("/:id", func(c *fiber.Ctx) error {
+		return controller.GetRun(c, manager)
+	})
+	router.Get("/:id/tasks/:name", func(c *fiber.Ctx) error {
+		return controller.GetRunTask(c, manager)
+	})
+	router.Get("/:id/logs", func(c *fiber.Ctx) error {
+		return controller.GetRunLogs(c, manager)
+	})
+	router.Get("/:id/logs/stream", func(c *fiber.Ctx) error {
+		return controller.StreamRunLogs(c, manager)
+	})
+	router.Post("/:id/cancel", func(c *fiber.Ctx) error {
+		return controller.CancelRun(c, manager)
+	})
+}
+
+// authorize rejects requests without the API token of the config.
+func authorize(filename string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg, err := config.GetConfig(filename)
+		if err != nil {
+			logger.Printf("Error getting config, got: %v", err)
+			return err
+		}
+		if err := controller.Authorize(c, *cfg); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// WorkerRouter serves the workers API, used to inspect the hosts pipelines run on.
+// every request requires the API token of the config, since workers include the hosts' addresses.
+func WorkerRouter(router fiber.Router, filename string) {
+	router.Use(authorize(filename))
+	router.Get("/", func(c *fiber.Ctx) error {
+		cfg, err := config.GetConfig(filename)
+		if err != nil {
+			logger.Printf("Error getting config, got: %v", err)
+			return err
+		}
+		return controller.ListWorkers(c, *cfg)
 	})
 }
@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrQueueFull = errors.New("Run queue is full, try again later")
+var ErrRunNotFound = errors.New("Run not found")
+var ErrRunFinished = errors.New("Run already finished")
+var ErrInvalidSHA = errors.New("Invalid commit SHA, expected a full 40 character SHA")
+var ErrNoUsableHosts = errors.New("None of the hosts the task runs on are usable, their install steps failed")
+var ErrInvalidRef = errors.New("Invalid ref, expected a branch or tag ref without whitespace, ':' or '+', e.g. refs/heads/main or refs/tags/v1.0.0")
+
+type ErrUnknownTask struct {
+	Name string
+}
+
+func (e ErrUnknownTask) Error() string {
+	return fmt.Sprintf("Unknown task %s, the task isn't defined in the pipeline", e.Name)
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"context"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// executor builds the repository and runs the tasks of a run, on the workers or on the local machine.
+type executor interface {
+	build(run *Run) []*syncPB.WorkerBuildOutput
+	// newTaskExecutor resolves the files of the task and expands its commands, without running them.
+	newTaskExecutor(ctx context.Context, run *Run, job config.TaskConsumerJobs) (*csync.TaskExecutor, error)
+	// runTask runs the task's commands, cancelling ctx kills the running commands.
+	runTask(ctx context.Context, run *Run, te *csync.TaskExecutor) error
+	// cleanup removes what the build left behind, it's called even if the run was cancelled.
+	cleanup(run *Run)
+}
+
+// newExecutor returns the executor of the run, local runs are executed on the local machine.
+func newExecutor(run *Run) executor {
+	wb := csync.NewWorkerBuilder(run.cfg, "origin", run.Branch, run.BranchRef)
+	// work trees are named after the build, a resumed run keeps the work trees it built.
+	wb.BuildID = run.ID
+	wb.OnLine = func(worker, line string) {
+		run.AppendLog(BuildLogSource, worker, line)
+	}
+	if run.Dir != "" {
+		return localExecutor{wb: wb, dir: run.Dir}
+	}
+	return workerExecutor{wb: wb}
+}
+
+// workerExecutor builds the repository on every worker, and distributes the task commands
+// to the workers through the message queue.
+type workerExecutor struct {
+	wb *csync.WorkersBuilder
+}
+
+func (e workerExecutor) build(run *Run) []*syncPB.WorkerBuildOutput {
+	return e.wb.BuildAllEndpoints(run.ctx)
+}
+
+func (e workerExecutor) newTaskExecutor(ctx context.Context, run *Run, job config.TaskConsumerJobs) (
+	*csync.TaskExecutor, error) {
+	return csync.NewTaskExecutor(ctx, run.cfg, job, e.wb.Workspace())
+}
+
+func (e workerExecutor) runTask(ctx context.Context, run *Run, te *csync.TaskExecutor) error {
+	return te.RunTaskOnAllMachines(ctx)
+}
+
+func (e workerExecutor) cleanup(run *Run) {
+	errs := e.wb.RemoveAllRepositoryWorkspaces()
+	logger.Printf("RemoveAllRepositoryWorkspaces errors: %v", errs)
+}
+
+// localExecutor builds and runs the pipeline in a checkout on the local machine,
+// without workers or a message queue.
+type localExecutor struct {
+	wb  *csync.WorkersBuilder
+	dir string
+}
+
+func (e localExecutor) build(run *Run) []*syncPB.WorkerBuildOutput {
+	return []*syncPB.WorkerBuildOutput{e.wb.BuildLocal(run.ctx, e.dir)}
+}
+
+func (e localExecutor) newTaskExecutor(ctx context.Context, run *Run, job config.TaskConsumerJobs) (
+	*csync.TaskExecutor, error) {
+	return csync.NewLocalTaskExecutor(ctx, run.cfg, job, e.dir)
+}
+
+func (e localExecutor) runTask(ctx context.Context, run *Run, te *csync.TaskExecutor) error {
+	return te.RunTaskLocally(ctx, e.dir)
+}
+
+// cleanup does nothing, the checkout is built in place.
+func (e localExecutor) cleanup(run *Run) {}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// Matches returns if the run's event matches the trigger filters, a nil trigger matches every event.
+// like github actions, a push to a tag only matches if tags are filtered, and a branch event only
+// matches if branches are filtered, unless neither of them is filtered.
+// manual runs match every event.
+func (r *Run) Matches(trigger *config.Trigger) bool {
+	if trigger == nil {
+		return true
+	}
+	if len(trigger.Events) > 0 && r.Event != ManualEvent && !slices.Contains(trigger.Events, r.Event) {
+		return false
+	}
+
+	if r.Tag != "" {
+		if len(trigger.Tags) > 0 && !matchAny(trigger.Tags, r.Tag) {
+			return false
+		}
+		if len(trigger.Tags) == 0 && len(trigger.Branches) > 0 {
+			return false
+		}
+	} else {
+		if len(trigger.Branches) > 0 && !matchAny(trigger.Branches, r.TargetBranch) {
+			return false
+		}
+		if len(trigger.Branches) == 0 && len(trigger.Tags) > 0 {
+			return false
+		}
+	}
+
+	// if the changed files are unknown, we run rather than silently skip.
+	if len(trigger.Paths) > 0 && r.ChangedFiles != nil {
+		if !slices.ContainsFunc(r.ChangedFiles, func(f string) bool { return matchAny(trigger.Paths, f) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequiresChangedFiles returns if any of the pipeline or task triggers filter by changed paths.
+func RequiresChangedFiles(cfg config.ValidatedConfig) bool {
+	if cfg.Pipeline.On != nil && len(cfg.Pipeline.On.Paths) > 0 {
+		return true
+	}
+	for _, task := range cfg.Pipeline.Tasks {
+		if task.On != nil && len(task.On.Paths) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool { return matchGlob(p, name) })
+}
+
+// matchGlob matches name against a glob pattern, '*' matches any characters except '/',
+// '**' matches any characters including '/' and '?' matches a single character except '/'.
+func matchGlob(pattern, name string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "**/" matches zero or more directories.
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	ok, err := regexp.MatchString(b.String(), name)
+	return err == nil && ok
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "main", name: "main", want: true},
+		{pattern: "main", name: "main-2", want: false},
+		{pattern: "release/*", name: "release/1.0", want: true},
+		{pattern: "release/*", name: "release/1.0/hotfix", want: false},
+		{pattern: "release/**", name: "release/1.0/hotfix", want: true},
+		{pattern: "v?.*", name: "v1.2", want: true},
+		{pattern: "v?.*", name: "v10.2", want: false},
+		{pattern: "**/*.go", name: "main.go", want: true},
+		{pattern: "**/*.go", name: "internal/sync/build.go", want: true},
+		{pattern: "docs/**", name: "docs/README.md", want: true},
+		{pattern: "*.md", name: "docs/README.md", want: false},
+		{pattern: "cmd/(worker)", name: "cmd/(worker)", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"|"+tt.name, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("Expected matchGlob(%q, %q) to be %v, got %v", tt.pattern, tt.name, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunMatches(t *testing.T) {
+	prToMain := &Run{Event: "pull_request", TargetBranch: "main", ChangedFiles: []string{"internal/sync/build.go"}}
+	pushToDev := &Run{Event: "push", TargetBranch: "dev"}
+	tagPush := &Run{Event: "push", Tag: "v1.2.0"}
+	unknownFiles := &Run{Event: "pull_request", TargetBranch: "main"}
+
+	tests := []struct {
+		name    string
+		run     *Run
+		trigger *config.Trigger
+		want    bool
+	}{
+		{name: "no-trigger", run: prToMain, trigger: nil, want: true},
+		{name: "event-matches", run: prToMain, trigger: &config.Trigger{Events: []string{"pull_request"}}, want: true},
+		{name: "event-dosent-match", run: pushToDev, trigger: &config.Trigger{Events: []string{"pull_request"}}, want: false},
+		{name: "target-branch-matches", run: prToMain, trigger: &config.Trigger{Branches: []string{"main"}}, want: true},
+		{name: "branch-dosent-match", run: pushToDev, trigger: &config.Trigger{Branches: []string{"main", "release/*"}}, want: false},
+		{name: "tag-matches", run: tagPush, trigger: &config.Trigger{Tags: []string{"v*"}}, want: true},
+		{name: "tag-dosent-match", run: tagPush, trigger: &config.Trigger{Tags: []string{"release-*"}}, want: false},
+		{name: "tag-with-only-branch-filter", run: tagPush, trigger: &config.Trigger{Branches: []string{"**"}}, want: false},
+		{name: "branch-with-only-tag-filter", run: pushToDev, trigger: &config.Trigger{Tags: []string{"v*"}}, want: false},
+		{name: "path-matches", run: prToMain, trigger: &config.Trigger{Paths: []string{"internal/**"}}, want: true},
+		{name: "path-dosent-match", run: prToMain, trigger: &config.Trigger{Paths: []string{"docs/**"}}, want: false},
+		{name: "unknown-changed-files", run: unknownFiles, trigger: &config.Trigger{Paths: []string{"docs/**"}}, want: true},
+		{
+			name: "all-filters-match",
+			run:  prToMain,
+			trigger: &config.Trigger{
+				Events:   []string{"pull_request"},
+				Branches: []string{"main"},
+				Paths:    []string{"**/*.go"},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.run.Matches(tt.trigger); got != tt.want {
+				t.Errorf("Expected Matches to be %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
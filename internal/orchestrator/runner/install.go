@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"slices"
+	"strings"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// InstallLogSource is the source of the log lines of the hosts' install steps.
+const InstallLogSource = "install"
+
+// installHosts runs the install steps of the hosts before the repository is built, steps that
+// already completed on a host are skipped by the host. a host that failed to install is unusable,
+// it's removed from the run so nothing is built or run on it.
+// local runs don't run install steps, the local machine is set up by its user.
+func installHosts(run *Run) {
+	if run.Dir != "" {
+		return
+	}
+	outputs := csync.InstallAllEndpoints(run.ctx, run.cfg)
+	unusable := map[string]bool{}
+	for _, output := range outputs {
+		for line := range strings.Lines(output.Output) {
+			run.AppendLog(InstallLogSource, output.WorkerName, strings.TrimRight(line, "\r\n"))
+		}
+		if output.Error != "" {
+			logger.Printf("Host %s is unusable, its install steps failed: %s", output.WorkerName, output.Error)
+			unusable[output.WorkerName] = true
+		}
+	}
+
+	run.mu.Lock()
+	run.Installs = outputs
+	if len(unusable) > 0 {
+		run.cfg.Endpoints = slices.DeleteFunc(slices.Clone(run.cfg.Endpoints), func(ep config.EndpointInfo) bool {
+			return unusable[ep.Name]
+		})
+	}
+	run.mu.Unlock()
+	run.save()
+}
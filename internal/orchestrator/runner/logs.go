@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+)
+
+// BuildLogSource is the source of the log lines of the repository build.
+const BuildLogSource = "build"
+
+// maxLogLines is the maximum amount of lines a run keeps for new subscribers, older lines are dropped.
+const maxLogLines = 50000
+
+// subscriberBuffer is the amount of lines buffered for a subscriber, a subscriber that falls
+// further behind is dropped so it dosen't block the run.
+const subscriberBuffer = 1024
+
+// logStream keeps the lines a run outputs, and sends them to the subscribers as they are written.
+type logStream struct {
+	mu     sync.Mutex
+	lines  []api.LogLine
+	subs   map[chan api.LogLine]struct{}
+	closed bool
+}
+
+func newLogStream() *logStream {
+	return &logStream{subs: map[chan api.LogLine]struct{}{}}
+}
+
+func (l *logStream) write(line api.LogLine) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	if len(l.lines) >= maxLogLines {
+		l.lines = l.lines[1:]
+	}
+	l.lines = append(l.lines, line)
+	for ch := range l.subs {
+		select {
+		case ch <- line:
+		default:
+			logger.Printf("Dropping slow log subscriber")
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the lines written so far and a channel receiving the lines written from now on,
+// the channel is closed when the stream is closed. unsubscribe must be called once done reading.
+func (l *logStream) subscribe() (history []api.LogLine, lines <-chan api.LogLine, unsubscribe func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := make(chan api.LogLine, subscriberBuffer)
+	history = append([]api.LogLine{}, l.lines...)
+	if l.closed {
+		close(ch)
+		return history, ch, func() {}
+	}
+	l.subs[ch] = struct{}{}
+	return history, ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// close stops accepting lines and closes the subscribers' channels.
+func (l *logStream) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	for ch := range l.subs {
+		close(ch)
+	}
+	l.subs = nil
+}
+
+// AppendLog adds a line of output of the run, source is BuildLogSource or the name of a task.
+func (r *Run) AppendLog(source, worker, line string) {
+	r.logs.write(api.LogLine{Time: time.Now(), Source: source, Worker: worker, Line: line})
+}
+
+// SubscribeLogs returns the lines the run output so far, and a channel receiving the lines
+// it outputs from now on. the channel is closed once the run finished.
+// unsubscribe must be called once done reading.
+func (r *Run) SubscribeLogs() (history []api.LogLine, lines <-chan api.LogLine, unsubscribe func()) {
+	return r.logs.subscribe()
+}
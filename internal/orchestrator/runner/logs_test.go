@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func TestLogStream(t *testing.T) {
+	l := newLogStream()
+	l.write(api.LogLine{Source: "build", Line: "first"})
+
+	history, lines, unsubscribe := l.subscribe()
+	defer unsubscribe()
+	if len(history) != 1 || history[0].Line != "first" {
+		t.Errorf("Expected history with the first line, got: %v", history)
+	}
+
+	l.write(api.LogLine{Source: "test", Line: "second"})
+	if line := <-lines; line.Line != "second" || line.Source != "test" {
+		t.Errorf("Expected second line, got: %v", line)
+	}
+
+	l.close()
+	if _, ok := <-lines; ok {
+		t.Errorf("Expected subscriber channel to be closed")
+	}
+	l.write(api.LogLine{Line: "ignored"})
+
+	// subscribing after the stream is closed returns the history and a closed channel.
+	history, lines, _ = l.subscribe()
+	if len(history) != 2 {
+		t.Errorf("Expected 2 lines of history, got: %v", history)
+	}
+	if _, ok := <-lines; ok {
+		t.Errorf("Expected subscriber channel to be closed")
+	}
+}
+
+func TestLogStreamDropsSlowSubscriber(t *testing.T) {
+	l := newLogStream()
+	_, lines, unsubscribe := l.subscribe()
+	for range subscriberBuffer + 1 {
+		l.write(api.LogLine{Line: "line"})
+	}
+	n := 0
+	for range lines {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d buffered lines before the subscriber was dropped, got %d", subscriberBuffer, n)
+	}
+	// unsubscribing a dropped subscriber is a no-op.
+	unsubscribe()
+}
+
+func TestRunLogsClosedWhenFinished(t *testing.T) {
+	run := NewRun(config.ValidatedConfig{}, "push", "main", "abc:push-abc")
+	_, lines, unsubscribe := run.SubscribeLogs()
+	defer unsubscribe()
+	run.AppendLog(BuildLogSource, "worker-1", "building")
+	if line := <-lines; line.Worker != "worker-1" || line.Line != "building" {
+		t.Errorf("Unexpected line: %v", line)
+	}
+	run.setState(CompletedRun)
+	if _, ok := <-lines; ok {
+		t.Errorf("Expected logs to be closed once the run finished")
+	}
+}
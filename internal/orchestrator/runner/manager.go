@@ -0,0 +1,299 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/google/uuid"
+)
+
+// NewRun creates a queued run for the config, the run is executed once it is enqueued to a Manager.
+func NewRun(cfg config.ValidatedConfig, event, branch, branchRef string) *Run {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Run{
+		ID:        uuid.New(),
+		State:     QueuedRun,
+		Event:     event,
+		Branch:    branch,
+		BranchRef: branchRef,
+		CreatedAt: time.Now(),
+		cfg:       cfg,
+		ctx:       ctx,
+		cancel:    cancel,
+		logs:      newLogStream(),
+	}
+}
+
+// NewManager creates a run manager that executes at most workers runs concurrently,
+// and buffers up to queueSize runs waiting for a free worker.
+// every accepted run is persisted to st, runs aren't persisted if st is nil.
+func NewManager(workers, queueSize int, st *store.Store) *Manager {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Manager{
+		queue:   make(chan *Run, queueSize),
+		workers: workers,
+		runs:    map[uuid.UUID]*Run{},
+		store:   st,
+		execute: runPipeline,
+	}
+}
+
+// Start starts the manager's worker pool, each worker executes one run at a time.
+func (m *Manager) Start() {
+	m.wg.Add(m.workers)
+	for range m.workers {
+		go func() {
+			defer m.wg.Done()
+			for run := range m.queue {
+				if run.ctx.Err() != nil {
+					// cancelled while waiting in the queue.
+					logger.Printf("Run %s was cancelled before it started", run.ID)
+					run.setState(CancelledRun)
+					m.forget(run)
+					continue
+				}
+				m.execute(run)
+				m.forget(run)
+			}
+		}()
+	}
+	logger.Printf("Started run manager with %d workers", m.workers)
+}
+
+// Close stops accepting runs and waits for the enqueued runs to finish.
+func (m *Manager) Close() {
+	close(m.queue)
+	m.wg.Wait()
+}
+
+// Enqueue adds a run to the queue without blocking, if the queue is full ErrQueueFull is returned.
+func (m *Manager) Enqueue(run *Run) error {
+	// register the run before a worker can pick it up, so it's always found by Get.
+	m.mu.Lock()
+	m.runs[run.ID] = run
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- run:
+	default:
+		m.mu.Lock()
+		delete(m.runs, run.ID)
+		m.mu.Unlock()
+		return ErrQueueFull
+	}
+	logger.Printf("Enqueued run %s for branch %s", run.ID, run.Branch)
+	run.mu.Lock()
+	run.store = m.store
+	run.mu.Unlock()
+	run.save()
+	return nil
+}
+
+// Resume reloads the runs that didn't finish before the orchestrator stopped from the store and enqueues
+// them, it's called before the manager starts. with a durable message queue, the runs keep the builds and
+// the tasks that finished and resume waiting for the commands they published. otherwise their commands
+// were lost with the orchestrator, and the runs fail.
+func (m *Manager) Resume(cfg config.ValidatedConfig) error {
+	if m.store == nil {
+		return nil
+	}
+	recs, err := m.store.ListRuns(store.RunFilter{Unfinished: true})
+	if err != nil {
+		return err
+	}
+	// enqueue the oldest runs first.
+	slices.Reverse(recs)
+	for _, rec := range recs {
+		run := runFromRecord(cfg, rec)
+		run.resumed = true
+		if !cfg.DurableQueues() {
+			logger.Printf("Run %s didn't finish and can't be resumed without a durable message queue", run.ID)
+			m.abandon(run)
+			continue
+		}
+		if err := m.Enqueue(run); err != nil {
+			logger.Printf("Failed to resume run %s: %v", run.ID, err)
+			m.abandon(run)
+			continue
+		}
+		logger.Printf("Resumed run %s", run.ID)
+	}
+	return nil
+}
+
+// abandon fails a run that can't be resumed.
+func (m *Manager) abandon(run *Run) {
+	run.mu.Lock()
+	run.store = m.store
+	run.Running = nil
+	run.mu.Unlock()
+	run.setState(FailedRun)
+	newStatusReporter(run.cfg, run).reportRun(run)
+}
+
+// forget removes a finished run from the manager once it's persisted, from then on the run
+// is served by the run history. without a run history the manager keeps every run.
+func (m *Manager) forget(run *Run) {
+	if m.store == nil {
+		return
+	}
+	if err := run.save(); err != nil {
+		return
+	}
+	m.mu.Lock()
+	delete(m.runs, run.ID)
+	m.mu.Unlock()
+}
+
+// Get returns a run the manager has accepted and is executing or waiting to execute,
+// finished runs are only returned without a run history.
+func (m *Manager) Get(id uuid.UUID) (*Run, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.runs[id]
+	return run, ok
+}
+
+// CancelPullRequestRuns cancels the unfinished runs of a pull request, except runs that build keepSHA.
+// it returns the runs that were cancelled.
+func (m *Manager) CancelPullRequestRuns(number int, keepSHA string) []*Run {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cancelled := []*Run{}
+	for _, run := range m.runs {
+		if run.Event != "pull_request" || run.PRNumber != number || run.IsFinished() {
+			continue
+		}
+		if keepSHA != "" && run.SHA == keepSHA {
+			continue
+		}
+		run.Cancel()
+		cancelled = append(cancelled, run)
+	}
+	return cancelled
+}
+
+// Cancel cancels the run, a queued run is never started and a running run stops
+// its builds and commands, and cleans up its workspaces.
+func (r *Run) Cancel() {
+	logger.Printf("Cancelling run %s", r.ID)
+	r.cancel()
+}
+
+// timeOut stops the run since it exceeded the pipeline timeout, like Cancel does.
+func (r *Run) timeOut() {
+	logger.Printf("Run %s timed out", r.ID)
+	r.mu.Lock()
+	r.timedOut = true
+	r.mu.Unlock()
+	r.cancel()
+}
+
+// IsFinished returns if the run finished executing, either successfully or not.
+func (r *Run) IsFinished() bool {
+	switch r.GetState() {
+	case CompletedRun, FailedRun, CancelledRun, TimedOutRun:
+		return true
+	}
+	return false
+}
+
+// GetState returns the current state of the run.
+func (r *Run) GetState() RunState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.State
+}
+
+func (r *Run) setState(state RunState) {
+	defer r.save()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.State = state
+	switch state {
+	case RunningRun:
+		// a resumed run keeps the time it started at.
+		if r.StartedAt.IsZero() {
+			r.StartedAt = time.Now()
+		}
+	case CompletedRun, FailedRun, CancelledRun, TimedOutRun:
+		r.FinishedAt = time.Now()
+		// release the context's resources, the run is done.
+		r.cancel()
+		r.logs.close()
+	}
+}
+
+// Context returns the context of the run, it's done when the run is cancelled or finished.
+func (r *Run) Context() context.Context {
+	return r.ctx
+}
+
+// CancelRun cancels an unfinished run, it returns ErrRunNotFound if the manager dosen't know
+// the run and ErrRunFinished if the run already finished.
+func (m *Manager) CancelRun(id uuid.UUID) (*Run, error) {
+	run, ok := m.Get(id)
+	if !ok {
+		// a run from the history isn't executed by this manager.
+		if m.store != nil {
+			if _, err := m.store.GetRun(id); err == nil {
+				return nil, ErrRunFinished
+			}
+		}
+		return nil, ErrRunNotFound
+	}
+	if run.IsFinished() {
+		return run, ErrRunFinished
+	}
+	run.Cancel()
+	return run, nil
+}
+
+// GetRecord returns the record of a run, runs the manager accepted are returned with
+// their current state, older runs are read from the run history.
+func (m *Manager) GetRecord(id uuid.UUID) (store.RunRecord, error) {
+	if run, ok := m.Get(id); ok {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		return run.record(), nil
+	}
+	if m.store == nil {
+		return store.RunRecord{}, ErrRunNotFound
+	}
+	rec, err := m.store.GetRun(id)
+	if err == store.ErrRunNotFound {
+		return rec, ErrRunNotFound
+	}
+	return rec, err
+}
+
+// ListRecords returns the records of the runs matching filter, newest first.
+// without a run history, only the runs the manager accepted are listed.
+func (m *Manager) ListRecords(filter store.RunFilter) ([]store.RunRecord, error) {
+	if m.store != nil {
+		return m.store.ListRuns(filter)
+	}
+	m.mu.RLock()
+	recs := []store.RunRecord{}
+	for _, run := range m.runs {
+		run.mu.Lock()
+		rec := run.record()
+		run.mu.Unlock()
+		if filter.Matches(rec) {
+			recs = append(recs, rec)
+		}
+	}
+	m.mu.RUnlock()
+	slices.SortFunc(recs, func(a, b store.RunRecord) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if filter.Limit > 0 && len(recs) > filter.Limit {
+		recs = recs[:filter.Limit]
+	}
+	return recs, nil
+}
@@ -0,0 +1,308 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/google/uuid"
+)
+
+func TestManagerBoundedWorkers(t *testing.T) {
+	const workers = 2
+	const runs = 6
+
+	m := NewManager(workers, runs, nil)
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(runs)
+	m.execute = func(r *Run) {
+		defer wg.Done()
+		r.setState(RunningRun)
+		n := running.Add(1)
+		for {
+			cur := maxRunning.Load()
+			if n <= cur || maxRunning.CompareAndSwap(cur, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		r.setState(CompletedRun)
+	}
+	m.Start()
+
+	enqueued := []*Run{}
+	for range runs {
+		r := NewRun(config.ValidatedConfig{}, "pull_request", "main", "pull/1/head:pr-1")
+		if err := m.Enqueue(r); err != nil {
+			t.Fatalf("Failed to enqueue run: %v", err)
+		}
+		enqueued = append(enqueued, r)
+	}
+	wg.Wait()
+	m.Close()
+
+	if maxRunning.Load() > workers {
+		t.Errorf("Expected at most %d concurrent runs, got %d", workers, maxRunning.Load())
+	}
+	for _, r := range enqueued {
+		got, ok := m.Get(r.ID)
+		if !ok {
+			t.Fatalf("Expected run %s to be found", r.ID)
+		}
+		if got.GetState() != CompletedRun {
+			t.Errorf("Expected run %s to be completed, got %v", r.ID, got.GetState())
+		}
+	}
+}
+
+func TestManagerQueueFull(t *testing.T) {
+	// the manager isn't started, so runs stay in the queue.
+	m := NewManager(1, 1, nil)
+	first := NewRun(config.ValidatedConfig{}, "pull_request", "main", "pull/1/head:pr-1")
+	if err := m.Enqueue(first); err != nil {
+		t.Fatalf("Failed to enqueue run: %v", err)
+	}
+	second := NewRun(config.ValidatedConfig{}, "pull_request", "main", "pull/2/head:pr-2")
+	if err := m.Enqueue(second); err != ErrQueueFull {
+		t.Errorf("Expected error: %v, got: %v", ErrQueueFull, err)
+	}
+	if _, ok := m.Get(second.ID); ok {
+		t.Errorf("Expected rejected run to not be registered")
+	}
+}
+
+func TestManagerCancelPullRequestRuns(t *testing.T) {
+	m := NewManager(1, 10, nil)
+	newPRRun := func(number int, sha string) *Run {
+		r := NewRun(config.ValidatedConfig{}, "pull_request", "feature", "pull/1/head:pr-1")
+		r.PRNumber = number
+		r.SHA = sha
+		if err := m.Enqueue(r); err != nil {
+			t.Fatalf("Failed to enqueue run: %v", err)
+		}
+		return r
+	}
+	old := newPRRun(1, "aaa")
+	finished := newPRRun(1, "bbb")
+	finished.setState(CompletedRun)
+	current := newPRRun(1, "ccc")
+	other := newPRRun(2, "ddd")
+
+	cancelled := m.CancelPullRequestRuns(1, "ccc")
+	if len(cancelled) != 1 || cancelled[0] != old {
+		t.Fatalf("Expected only the superseded run to be cancelled, got: %v", cancelled)
+	}
+	if old.ctx.Err() == nil {
+		t.Errorf("Expected superseded run context to be cancelled")
+	}
+	for _, r := range []*Run{current, other} {
+		if r.ctx.Err() != nil {
+			t.Errorf("Expected run of pr %d sha %s to not be cancelled", r.PRNumber, r.SHA)
+		}
+	}
+	if finished.GetState() != CompletedRun {
+		t.Errorf("Expected finished run state to be unchanged, got %v", finished.GetState())
+	}
+
+	// the cancelled run is never executed.
+	executed := make(chan *Run, 10)
+	m.execute = func(r *Run) {
+		executed <- r
+		r.setState(CompletedRun)
+	}
+	m.Start()
+	m.Close()
+	close(executed)
+	for r := range executed {
+		if r == old {
+			t.Errorf("Expected cancelled run to not be executed")
+		}
+	}
+	if old.GetState() != CancelledRun {
+		t.Errorf("Expected cancelled run state %v, got %v", CancelledRun, old.GetState())
+	}
+}
+
+func TestManagerPersistsRuns(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "runs.db"), 0)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer st.Close()
+
+	m := NewManager(1, 1, st)
+	done := make(chan struct{})
+	m.execute = func(r *Run) {
+		defer close(done)
+		r.setState(RunningRun)
+		r.addTaskResult(TaskResult{Name: "test", State: csync.CompleteTaskWithErrors, Commands: []string{"go test ./..."}})
+		r.setState(FailedRun)
+	}
+	run := NewRun(config.ValidatedConfig{}, "pull_request", "feature", "pull/42/head:pr-1")
+	run.PRNumber = 42
+	run.Payload = []byte(`{"number":42}`)
+	if err := m.Enqueue(run); err != nil {
+		t.Fatalf("Failed to enqueue run: %v", err)
+	}
+	queued, err := st.GetRun(run.ID)
+	if err != nil {
+		t.Fatalf("Expected enqueued run to be persisted: %v", err)
+	}
+	if queued.State != QueuedRun.String() {
+		t.Errorf("Expected persisted state %s, got %s", QueuedRun, queued.State)
+	}
+
+	m.Start()
+	<-done
+	m.Close()
+
+	rec, err := st.GetRun(run.ID)
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	if rec.State != FailedRun.String() || rec.PRNumber != 42 || rec.FinishedAt.IsZero() {
+		t.Errorf("Unexpected persisted run: %+v", rec)
+	}
+	if len(rec.Tasks) != 1 || rec.Tasks[0].State != csync.CompleteTaskWithErrors.String() || rec.Tasks[0].Commands[0] != "go test ./..." {
+		t.Errorf("Unexpected persisted tasks: %+v", rec.Tasks)
+	}
+	if string(rec.Payload) != `{"number":42}` {
+		t.Errorf("Unexpected persisted payload: %s", rec.Payload)
+	}
+
+	// the finished run is served by the run history.
+	if _, ok := m.Get(run.ID); ok {
+		t.Errorf("Expected the finished run to be removed from the manager")
+	}
+	if rec, err := m.GetRecord(run.ID); err != nil || rec.State != FailedRun.String() {
+		t.Errorf("Expected the finished run to be read from the run history, got %+v: %v", rec, err)
+	}
+	if _, err := m.CancelRun(run.ID); err != ErrRunFinished {
+		t.Errorf("Expected error: %v, got: %v", ErrRunFinished, err)
+	}
+}
+
+func TestRunConcurrentSaves(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "runs.db"), 0)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer st.Close()
+
+	run := NewRun(config.ValidatedConfig{}, "push", "main", "")
+	run.store = st
+	// every task saves the run once it finished, the last save has every task.
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run.addTaskResult(TaskResult{Name: fmt.Sprintf("task-%d", i), State: csync.CompletedTask})
+			run.save()
+		}()
+	}
+	wg.Wait()
+
+	rec, err := st.GetRun(run.ID)
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	if len(rec.Tasks) != 20 {
+		t.Errorf("Expected the persisted run to have every task, got %d", len(rec.Tasks))
+	}
+}
+
+func TestManagerResume(t *testing.T) {
+	tests := []struct {
+		name          string
+		durable       bool
+		expectedState RunState
+	}{
+		{name: "durable runs are resumed", durable: true, expectedState: CompletedRun},
+		{name: "runs fail without a durable message queue", durable: false, expectedState: FailedRun},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := store.Open(filepath.Join(t.TempDir(), "runs.db"), 0)
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer st.Close()
+			cfg := config.ValidatedConfig{Config: &config.Config{
+				MessageQueue: &config.MessageQueue{Durable: tt.durable},
+			}}
+
+			// the run built the repository and finished a task, and another task was running
+			// when the orchestrator stopped.
+			started := time.Now().Add(-time.Minute).Round(0)
+			result := csync.CommandResult{ID: "cmd-1", Command: "go vet ./...", Host: "worker-1", Output: "ok"}
+			interrupted := NewRun(cfg, "push", "main", "refs/heads/main")
+			interrupted.store = st
+			interrupted.StartedAt = started
+			interrupted.Builds = []*syncPB.WorkerBuildOutput{{WorkerName: "worker-1", Output: "built"}}
+			interrupted.Tasks = []TaskResult{{Name: "lint", State: csync.CompletedTask, Results: []csync.CommandResult{result}}}
+			interrupted.Running = []RunningTask{{Name: "test", ID: uuid.New(), StartedAt: started, Progress: []csync.CommandProgress{
+				{ID: "cmd-2", Command: "go test ./...", Status: "running", Host: "worker-1", Attempts: 1},
+				{ID: "cmd-3", Command: "go test -race ./...", Status: "done", Attempts: 1, Result: &result},
+			}}}
+			interrupted.setState(RunningRun)
+			finished := NewRun(cfg, "push", "main", "refs/heads/main")
+			finished.store = st
+			finished.setState(CompletedRun)
+
+			m := NewManager(1, 1, st)
+			resumed := make(chan *Run, 2)
+			m.execute = func(r *Run) {
+				resumed <- r
+				r.setState(CompletedRun)
+			}
+			if err := m.Resume(cfg); err != nil {
+				t.Fatalf("Failed to resume runs: %v", err)
+			}
+			m.Start()
+			m.Close()
+			close(resumed)
+
+			runs := []*Run{}
+			for r := range resumed {
+				runs = append(runs, r)
+			}
+			if tt.durable {
+				if len(runs) != 1 || runs[0].ID != interrupted.ID {
+					t.Fatalf("Expected only the interrupted run to be resumed, got %v", runs)
+				}
+				r := runs[0]
+				if !r.StartedAt.Equal(started) || r.resumedBuilds() == nil {
+					t.Errorf("Expected the run to keep its start time and builds, got %v and %v", r.StartedAt, r.Builds)
+				}
+				if res, ok := r.finishedTask("lint"); !ok || res.State != csync.CompletedTask || res.Results[0].Output != "ok" {
+					t.Errorf("Expected the finished task to be restored, got %+v", res)
+				}
+				task, ok := r.runningTask("test")
+				if !ok || task.ID != interrupted.Running[0].ID || len(task.Progress) != 2 ||
+					task.Progress[0].Status != "running" || task.Progress[1].Result == nil {
+					t.Errorf("Expected the progress of the running task to be restored, got %+v", task)
+				}
+			} else if len(runs) != 0 {
+				t.Errorf("Expected no run to be resumed, got %v", runs)
+			}
+
+			rec, err := st.GetRun(interrupted.ID)
+			if err != nil {
+				t.Fatalf("Failed to get run: %v", err)
+			}
+			if rec.State != tt.expectedState.String() || rec.FinishedAt.IsZero() {
+				t.Errorf("Expected the interrupted run to be %s, got %s", tt.expectedState, rec.State)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// ManualEvent is the event of runs started through the API instead of a github webhook.
+const ManualEvent = "manual"
+
+var shaPattern = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// NewManualRun creates a run of the pipeline for a manual run request.
+// manual runs aren't filtered by the pipeline trigger, and task triggers are evaluated
+// without their events filter. tasks selected by the request always run, along with
+// the tasks they depend on.
+func NewManualRun(cfg config.ValidatedConfig, req api.RunRequest) (*Run, error) {
+	if err := validateTasks(cfg, req.Tasks); err != nil {
+		return nil, err
+	}
+	branch := req.Branch
+	if branch == "" {
+		branch = cfg.Provider.Github.Branch
+	}
+
+	run := NewRun(cfg, ManualEvent, branch, "")
+	run.TargetBranch = branch
+	run.SelectedTasks = req.Tasks
+	// every manual run fetches into its own local branch.
+	local := "manual-" + run.ID.String()[:8]
+	switch {
+	case req.SHA != "":
+		sha := strings.ToLower(req.SHA)
+		if !shaPattern.MatchString(sha) {
+			return nil, ErrInvalidSHA
+		}
+		run.SHA = sha
+		run.BranchRef = fmt.Sprintf("%s:%s", sha, local)
+	case req.Ref != "":
+		if !validRef(req.Ref) {
+			return nil, ErrInvalidRef
+		}
+		run.BranchRef = fmt.Sprintf("%s:%s", req.Ref, local)
+		if tag, ok := strings.CutPrefix(req.Ref, "refs/tags/"); ok {
+			run.Tag = tag
+			run.TargetBranch = ""
+		}
+	default:
+		if strings.ContainsAny(branch, ": \t\n+") || branch == "" {
+			return nil, ErrInvalidRef
+		}
+		run.BranchRef = fmt.Sprintf("refs/heads/%s:%s", branch, local)
+	}
+	return run, nil
+}
+
+// NewLocalRun creates a run that builds and runs the pipeline in the checkout at dir on the local
+// machine, without workers or a message queue. the selected tasks run along with the tasks they
+// depend on, and every task runs if none are selected. task trigger filters are ignored.
+func NewLocalRun(cfg config.ValidatedConfig, dir string, tasks []string) (*Run, error) {
+	if err := validateTasks(cfg, tasks); err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		for _, task := range cfg.Pipeline.Tasks {
+			tasks = append(tasks, task.Name)
+		}
+	}
+	run := NewRun(cfg, ManualEvent, "", "")
+	run.SelectedTasks = tasks
+	run.Dir = abs
+	return run, nil
+}
+
+// validRef returns if ref is a branch or a tag ref, other refs such as pull request heads
+// aren't built by manual runs.
+func validRef(ref string) bool {
+	name, ok := strings.CutPrefix(ref, "refs/heads/")
+	if !ok {
+		name, ok = strings.CutPrefix(ref, "refs/tags/")
+	}
+	return ok && name != "" && !strings.ContainsAny(name, ": \t\n+")
+}
+
+func validateTasks(cfg config.ValidatedConfig, names []string) error {
+	for _, name := range names {
+		if !slices.ContainsFunc(cfg.Pipeline.Tasks, func(t config.TaskConsumerJobs) bool { return t.Name == name }) {
+			return ErrUnknownTask{Name: name}
+		}
+	}
+	return nil
+}
+
+// pipelineTasks returns the tasks the run executes, every task of the pipeline or the selected
+// tasks and the tasks they depend on, in the order they are defined.
+func (r *Run) pipelineTasks() []config.TaskConsumerJobs {
+	if len(r.SelectedTasks) == 0 {
+		return r.cfg.Pipeline.Tasks
+	}
+	byName := map[string]config.TaskConsumerJobs{}
+	for _, task := range r.cfg.Pipeline.Tasks {
+		byName[task.Name] = task
+	}
+	included := map[string]bool{}
+	var include func(name string)
+	include = func(name string) {
+		task, ok := byName[name]
+		if !ok || included[name] {
+			return
+		}
+		included[name] = true
+		for _, dep := range task.DependsOn {
+			include(dep)
+		}
+	}
+	for _, name := range r.SelectedTasks {
+		include(name)
+	}
+	tasks := []config.TaskConsumerJobs{}
+	for _, task := range r.cfg.Pipeline.Tasks {
+		if included[task.Name] {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// shouldRunTask returns if a task of the run executes, based on its trigger filters.
+// local runs ignore the trigger filters.
+func (r *Run) shouldRunTask(task config.TaskConsumerJobs) bool {
+	return r.Dir != "" || slices.Contains(r.SelectedTasks, task.Name) || r.Matches(task.On)
+}
@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func newManualTestConfig() config.ValidatedConfig {
+	return config.ValidatedConfig{
+		Config: &config.Config{
+			Provider: config.Provider{Github: config.Github{Branch: "main"}},
+			Pipeline: config.Pipeline{
+				Tasks: []config.TaskConsumerJobs{
+					{Name: "lint"},
+					{Name: "build"},
+					{Name: "test", DependsOn: []string{"build"}},
+					{Name: "deploy", DependsOn: []string{"test"}, On: &config.Trigger{Events: []string{"push"}}},
+				},
+			},
+		},
+	}
+}
+
+func TestNewManualRun(t *testing.T) {
+	sha := strings.Repeat("ab", 20)
+	tests := []struct {
+		name           string
+		req            api.RunRequest
+		expectedBranch string
+		expectedRef    string // refspec source
+		expectedSHA    string
+		expectedTag    string
+		expectedErr    error
+	}{
+		{name: "default branch", req: api.RunRequest{}, expectedBranch: "main", expectedRef: "refs/heads/main"},
+		{name: "branch", req: api.RunRequest{Branch: "feature"}, expectedBranch: "feature", expectedRef: "refs/heads/feature"},
+		{name: "sha", req: api.RunRequest{SHA: strings.ToUpper(sha)}, expectedBranch: "main", expectedRef: sha, expectedSHA: sha},
+		{name: "branch ref", req: api.RunRequest{Ref: "refs/heads/feature"}, expectedBranch: "main", expectedRef: "refs/heads/feature"},
+		{name: "tag ref", req: api.RunRequest{Ref: "refs/tags/v1.0.0"}, expectedBranch: "main", expectedRef: "refs/tags/v1.0.0", expectedTag: "v1.0.0"},
+		{name: "short sha", req: api.RunRequest{SHA: "abc1234"}, expectedErr: ErrInvalidSHA},
+		{name: "ref with refspec", req: api.RunRequest{Ref: "main:evil"}, expectedErr: ErrInvalidRef},
+		{name: "forced ref", req: api.RunRequest{Ref: "+refs/heads/main"}, expectedErr: ErrInvalidRef},
+		{name: "pull request ref", req: api.RunRequest{Ref: "refs/pull/42/head"}, expectedErr: ErrInvalidRef},
+		{name: "short pull request ref", req: api.RunRequest{Ref: "pull/42/head"}, expectedErr: ErrInvalidRef},
+		{name: "empty tag ref", req: api.RunRequest{Ref: "refs/tags/"}, expectedErr: ErrInvalidRef},
+		{name: "unknown task", req: api.RunRequest{Tasks: []string{"missing"}}, expectedErr: ErrUnknownTask{Name: "missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := NewManualRun(newManualTestConfig(), tt.req)
+			if err != tt.expectedErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			src, dst, _ := strings.Cut(run.BranchRef, ":")
+			if run.Event != ManualEvent || run.Branch != tt.expectedBranch || src != tt.expectedRef {
+				t.Errorf("Unexpected run: event %s, branch %s, ref %s", run.Event, run.Branch, run.BranchRef)
+			}
+			if dst != "manual-"+run.ID.String()[:8] {
+				t.Errorf("Expected the run to fetch into its own branch, got ref %s", run.BranchRef)
+			}
+			if run.SHA != tt.expectedSHA || run.Tag != tt.expectedTag {
+				t.Errorf("Expected sha %q tag %q, got sha %q tag %q", tt.expectedSHA, tt.expectedTag, run.SHA, run.Tag)
+			}
+		})
+	}
+}
+
+func TestManualRunTasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected []string
+		expected []string
+		ran      []string
+	}{
+		{
+			name:     "every task, event filters ignored",
+			expected: []string{"lint", "build", "test", "deploy"},
+			ran:      []string{"lint", "build", "test", "deploy"},
+		},
+		{
+			name:     "selected task with dependencies",
+			selected: []string{"test"},
+			expected: []string{"build", "test"},
+			ran:      []string{"build", "test"},
+		},
+		{
+			name:     "selected tasks",
+			selected: []string{"deploy", "lint"},
+			expected: []string{"lint", "build", "test", "deploy"},
+			ran:      []string{"lint", "build", "test", "deploy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := NewManualRun(newManualTestConfig(), api.RunRequest{Tasks: tt.selected})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			names := []string{}
+			ran := []string{}
+			for _, task := range run.pipelineTasks() {
+				names = append(names, task.Name)
+				if run.shouldRunTask(task) {
+					ran = append(ran, task.Name)
+				}
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("Expected tasks %v, got %v", tt.expected, names)
+			}
+			if !slices.Equal(ran, tt.ran) {
+				t.Errorf("Expected tasks %v to run, got %v", tt.ran, ran)
+			}
+		})
+	}
+}
+
+func TestShouldRunTaskSelectedIgnoresFilters(t *testing.T) {
+	cfg := newManualTestConfig()
+	cfg.Pipeline.Tasks[0].On = &config.Trigger{Branches: []string{"release/*"}}
+	run, err := NewManualRun(cfg, api.RunRequest{Tasks: []string{"lint"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !run.shouldRunTask(cfg.Pipeline.Tasks[0]) {
+		t.Errorf("Expected a selected task to run regardless of its trigger filters")
+	}
+	run.SelectedTasks = nil
+	if run.shouldRunTask(cfg.Pipeline.Tasks[0]) {
+		t.Errorf("Expected task filtered by branch to not run on branch %s", run.TargetBranch)
+	}
+}
+
+func TestLocalRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte("echo checking\nexit 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newManualTestConfig()
+	cfg.Pipeline.Build.BuildSteps = []string{"echo built"}
+	cfg.Pipeline.Tasks = []config.TaskConsumerJobs{
+		{Name: "lint", File: []string{"check.sh"}, Commands: []string{"echo lint {file}"}},
+		{Name: "test", File: []string{"check.sh"}, Commands: []string{"sh {file}"}, DependsOn: []string{"lint"}},
+		{Name: "deploy", File: []string{"check.sh"}, Commands: []string{"echo deploy"}, DependsOn: []string{"test"},
+			On: &config.Trigger{Events: []string{"push"}, Tags: []string{"v*"}}},
+	}
+
+	if _, err := NewLocalRun(cfg, dir, []string{"missing"}); err != (ErrUnknownTask{Name: "missing"}) {
+		t.Errorf("Expected unknown task error, got: %v", err)
+	}
+	run, err := NewLocalRun(cfg, dir, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runPipeline(run)
+
+	if run.State != FailedRun {
+		t.Errorf("Expected run to fail, got state %s", run.State)
+	}
+	if len(run.Builds) != 1 || run.Builds[0].Error != nil || run.Builds[0].WorkerName != csync.LocalWorkerName {
+		t.Errorf("Expected a successful local build, got %v", run.Builds)
+	}
+	expected := map[string]csync.TaskState{
+		"lint":   csync.CompletedTask,
+		"test":   csync.CompleteTaskWithErrors,
+		"deploy": csync.SkippedTask, // trigger filters are ignored, but its dependency failed.
+	}
+	for _, task := range run.Tasks {
+		if task.State != expected[task.Name] {
+			t.Errorf("Expected task %s state %s, got %s", task.Name, expected[task.Name], task.State)
+		}
+	}
+	history, _, unsubscribe := run.SubscribeLogs()
+	defer unsubscribe()
+	lines := []string{}
+	for _, l := range history {
+		lines = append(lines, l.Source+": "+l.Line)
+	}
+	want := []string{"build: built", "lint: lint " + filepath.Join(dir, "check.sh"), "test: checking"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Expected log lines %v, got %v", want, lines)
+	}
+}
+
+func TestLocalRunTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newManualTestConfig()
+	cfg.Pipeline.Tasks = []config.TaskConsumerJobs{
+		{Name: "slow", File: []string{"x"}, Commands: []string{"sleep 10"}, Timeout: "200ms"},
+		{Name: "after", File: []string{"x"}, Commands: []string{"echo after"}, DependsOn: []string{"slow"}},
+		{Name: "lint", File: []string{"x"}, Commands: []string{"echo lint"}},
+	}
+	run, err := NewLocalRun(cfg, dir, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runPipeline(run)
+	if run.State != FailedRun {
+		t.Errorf("Expected a timed out task to fail the run, got state %s", run.State)
+	}
+	expected := map[string]csync.TaskState{
+		"slow":  csync.TimedOutTask,
+		"after": csync.SkippedTask,
+		"lint":  csync.CompletedTask,
+	}
+	for _, task := range run.Tasks {
+		if task.State != expected[task.Name] {
+			t.Errorf("Expected task %s state %s, got %s", task.Name, expected[task.Name], task.State)
+		}
+	}
+
+	cfg = newManualTestConfig()
+	cfg.Pipeline.Timeout = "200ms"
+	cfg.Pipeline.Tasks = []config.TaskConsumerJobs{
+		{Name: "slow", File: []string{"x"}, Commands: []string{"sleep 10"}},
+	}
+	run, err = NewLocalRun(cfg, dir, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	start := time.Now()
+	runPipeline(run)
+	if run.State != TimedOutRun {
+		t.Errorf("Expected the run to time out, got state %s", run.State)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the running task to be killed when the run timed out")
+	}
+	if !run.IsFinished() {
+		t.Errorf("Expected a timed out run to be finished")
+	}
+}
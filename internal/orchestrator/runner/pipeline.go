@@ -0,0 +1,287 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
+)
+
+// runPipeline builds the repository on all endpoints, runs every pipeline task and
+// removes the workspaces afterwards. local runs are built and run on the local machine instead.
+// the run's state is updated as the pipeline progresses, and reported to github as commit
+// statuses of the built commit. a resumed run that already built the repository keeps its
+// builds and the tasks that finished, and resumes waiting for the commands of its running tasks.
+func runPipeline(run *Run) {
+	run.setState(RunningRun)
+	logger.Printf("Running pipeline for run %s", run.ID)
+	if timeout := run.cfg.Pipeline.GetTimeout(); timeout > 0 {
+		// a resumed run keeps the time it already ran.
+		timer := time.AfterFunc(timeout-time.Since(run.StartedAt), run.timeOut)
+		defer timer.Stop()
+	}
+	failed := false
+	reporter := newStatusReporter(run.cfg, run)
+	reporter.reportRun(run)
+
+	// hosts that fail to install are removed from the run, so they're installed before the
+	// executor picks the run's hosts.
+	outputs := run.resumedBuilds()
+	if outputs == nil {
+		installHosts(run)
+		if run.Dir == "" && len(run.cfg.Endpoints) == 0 {
+			logger.Printf("Run %s failed, none of its hosts are usable", run.ID)
+			failed = true
+		}
+	}
+	ex := newExecutor(run)
+	if outputs == nil {
+		outputs = ex.build(run)
+	} else {
+		logger.Printf("Resuming run %s after its build", run.ID)
+	}
+	for _, output := range outputs {
+		if output == nil || output.Error != nil {
+			failed = true
+		}
+	}
+	run.mu.Lock()
+	run.Builds = outputs
+	run.mu.Unlock()
+	run.save()
+	logger.Printf("Run %s build outputs: %v", run.ID, outputs)
+
+	var mu sync.Mutex
+	results := map[string]TaskResult{}
+	tasks := run.pipelineTasks()
+	states := scheduleTasks(tasks, func(job config.TaskConsumerJobs) csync.TaskState {
+		if res, ok := run.finishedTask(job.Name); ok {
+			// the task finished before the run was resumed, its result is already recorded.
+			mu.Lock()
+			results[job.Name] = res
+			mu.Unlock()
+			return res.State
+		}
+		var res TaskResult
+		if run.ctx.Err() != nil {
+			res = TaskResult{Name: job.Name, State: csync.CancelledTask}
+		} else if run.shouldRunTask(job) {
+			reporter.reportTask(run, TaskResult{Name: job.Name, State: csync.RunningTask})
+			res = runTask(run, ex, job)
+		} else {
+			logger.Printf("Skipping task %s, it dosen't match the task trigger filters", job.Name)
+			res = TaskResult{Name: job.Name, State: csync.SkippedTask}
+		}
+		reporter.reportTask(run, res)
+		mu.Lock()
+		results[job.Name] = res
+		mu.Unlock()
+		// results are recorded as tasks finish, so the progress of the run can be followed.
+		run.addTaskResult(res)
+		run.save()
+		return res.State
+	})
+
+	for _, job := range tasks {
+		res, ok := results[job.Name]
+		if !ok {
+			res, ok = run.finishedTask(job.Name)
+		}
+		if !ok {
+			// the scheduler skipped the task without running it.
+			res = TaskResult{Name: job.Name, State: states[job.Name]}
+			reporter.reportTask(run, res)
+			run.addTaskResult(res)
+		}
+		// a skipped task only fails the run if a task it depends on failed, which already failed the run.
+		if res.State.Failed() {
+			failed = true
+		}
+	}
+	run.sortTaskResults()
+	run.save()
+	// workspaces are removed even if the run was cancelled.
+	ex.cleanup(run)
+
+	run.mu.Lock()
+	timedOut := run.timedOut
+	run.mu.Unlock()
+	if timedOut {
+		run.setState(TimedOutRun)
+	} else if run.ctx.Err() != nil {
+		run.setState(CancelledRun)
+	} else if failed {
+		run.setState(FailedRun)
+	} else {
+		run.setState(CompletedRun)
+	}
+	reporter.reportRun(run)
+	logger.Printf("Run %s finished: %s", run.ID, run.GetState())
+}
+
+// runTask runs a single task on all of the machines it runs on, the task is stopped if it
+// exceeds its timeout.
+func runTask(run *Run, ex executor, job config.TaskConsumerJobs) TaskResult {
+	if task, ok := run.runningTask(job.Name); ok {
+		return resumeTask(run, job, task)
+	}
+	logger.Printf("Running task: %s", job.Name)
+	startedAt := time.Now()
+	ctx, cancel := process.WithTimeout(run.ctx, job.GetTimeout())
+	defer cancel()
+	te, err := ex.newTaskExecutor(ctx, run, job)
+	if err != nil {
+		logger.Printf("Failed to create task executor for task: %s", job.Name)
+		return TaskResult{
+			Name:       job.Name,
+			State:      csync.ErrorInTask,
+			Errors:     []string{err.Error()},
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		}
+	}
+	watchTask(run, job, te, startedAt)
+	err = ex.runTask(ctx, run, te)
+	return taskResult(job, te, err, startedAt)
+}
+
+// resumeTask resumes a task that was running when the orchestrator stopped, the task keeps the time
+// it already ran.
+func resumeTask(run *Run, job config.TaskConsumerJobs, task RunningTask) TaskResult {
+	logger.Printf("Resuming task: %s", job.Name)
+	ctx, cancel := context.WithCancel(run.ctx)
+	if timeout := job.GetTimeout(); timeout > 0 {
+		ctx, cancel = context.WithDeadline(run.ctx, task.StartedAt.Add(timeout))
+	}
+	defer cancel()
+	te := csync.NewResumedTaskExecutor(run.cfg, job, task.ID, task.Progress)
+	if len(te.RunsOn) == 0 {
+		return TaskResult{
+			Name:       job.Name,
+			State:      csync.ErrorInTask,
+			Commands:   te.Cmds,
+			Errors:     []string{ErrNoUsableHosts.Error()},
+			StartedAt:  task.StartedAt,
+			FinishedAt: time.Now(),
+		}
+	}
+	watchTask(run, job, te, task.StartedAt)
+	err := te.ResumeTaskOnAllMachines(ctx, task.Progress)
+	return taskResult(job, te, err, task.StartedAt)
+}
+
+// watchTask streams the output of the task's commands to the run's logs, and records the progress
+// of the commands of a durable task so it can be resumed.
+func watchTask(run *Run, job config.TaskConsumerJobs, te *csync.TaskExecutor, startedAt time.Time) {
+	te.RunID = run.ID
+	te.OnLine = func(worker, line string) {
+		run.AppendLog(job.Name, worker, line)
+	}
+	if te.Durable {
+		te.OnProgress = func(progress []csync.CommandProgress) {
+			run.setRunningTask(RunningTask{Name: job.Name, ID: te.TaskID, StartedAt: startedAt, Progress: progress})
+			run.save()
+		}
+	}
+}
+
+// taskResult returns the result of a task whose commands ran, err is the error running them.
+func taskResult(job config.TaskConsumerJobs, te *csync.TaskExecutor, err error, startedAt time.Time) TaskResult {
+	if err != nil && te.State != csync.CancelledTask && te.State != csync.TimedOutTask {
+		logger.Printf("Failed to run task: %s", job.Name)
+		te.State = csync.ErrorInTask
+	}
+	logger.Printf("%s runner output: %v", job.Name, te.Outputs)
+	return TaskResult{
+		Name:       job.Name,
+		State:      te.State,
+		Commands:   te.Cmds,
+		Outputs:    te.Outputs,
+		Errors:     te.Errors,
+		Results:    te.Results,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+}
+
+func (r *Run) addTaskResult(res TaskResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Tasks = append(r.Tasks, res)
+	// the task isn't running anymore.
+	r.Running = slices.DeleteFunc(r.Running, func(task RunningTask) bool {
+		return task.Name == res.Name
+	})
+}
+
+// setRunningTask records the progress of a running task.
+func (r *Run) setRunningTask(task RunningTask) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.Running {
+		if r.Running[i].Name == task.Name {
+			r.Running[i] = task
+			return
+		}
+	}
+	r.Running = append(r.Running, task)
+}
+
+// runningTask returns the progress of a task that was running when the run was resumed.
+func (r *Run) runningTask(name string) (RunningTask, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.resumed {
+		return RunningTask{}, false
+	}
+	for _, task := range r.Running {
+		if task.Name == name {
+			return task, true
+		}
+	}
+	return RunningTask{}, false
+}
+
+// finishedTask returns the result of a task that finished before the run was resumed.
+func (r *Run) finishedTask(name string) (TaskResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.resumed {
+		return TaskResult{}, false
+	}
+	for _, res := range r.Tasks {
+		if res.Name == name {
+			return res, true
+		}
+	}
+	return TaskResult{}, false
+}
+
+// resumedBuilds returns the builds of a resumed run that built the repository before the orchestrator
+// stopped, nil if the run has to be built.
+func (r *Run) resumedBuilds() []*syncPB.WorkerBuildOutput {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.resumed || len(r.Builds) == 0 {
+		return nil
+	}
+	return r.Builds
+}
+
+// sortTaskResults orders the task results in the order the tasks are defined in the pipeline.
+func (r *Run) sortTaskResults() {
+	order := map[string]int{}
+	for i, job := range r.cfg.Pipeline.Tasks {
+		order[job.Name] = i
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	slices.SortStableFunc(r.Tasks, func(a, b TaskResult) int {
+		return order[a.Name] - order[b.Name]
+	})
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"context"
+	"slices"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// Plan returns what the run would execute, without executing anything. the tasks are scheduled
+// as if every task that runs completes successfully, so tasks are only skipped because of their
+// trigger filters or dependencies. the files of every task that would run are resolved through
+// the run's executor, pattern based files are looked up on the first host the task runs on.
+func (r *Run) Plan(ctx context.Context) api.Plan {
+	ex := newExecutor(r)
+	plan := api.Plan{
+		Build: api.PlanBuild{
+			Name:  r.cfg.Pipeline.Build.Name,
+			Hosts: r.hosts(r.cfg.Endpoints),
+			Steps: r.cfg.Pipeline.Build.BuildSteps,
+		},
+		Tasks: []api.PlanTask{},
+	}
+
+	tasks := r.pipelineTasks()
+	states := scheduleTasks(tasks, func(job config.TaskConsumerJobs) csync.TaskState {
+		if r.shouldRunTask(job) {
+			return csync.CompletedTask
+		}
+		return csync.SkippedTask
+	})
+	for _, job := range tasks {
+		task := api.PlanTask{
+			Name:      job.Name,
+			Run:       states[job.Name] == csync.CompletedTask,
+			DependsOn: job.DependsOn,
+			Hosts:     job.RunsOn,
+		}
+		if r.Dir != "" {
+			task.Hosts = []string{csync.LocalWorkerName}
+		}
+		switch {
+		case !r.shouldRunTask(job):
+			task.Reason = "the trigger filters of the task don't match the run"
+		case !task.Run && slices.ContainsFunc(job.DependsOn, func(dep string) bool { return !containsTask(tasks, dep) }):
+			task.Reason = "the task depends on a task that isn't part of the run"
+		case !task.Run:
+			task.Reason = "a task it depends on would be skipped"
+		}
+		if task.Run {
+			te, err := ex.newTaskExecutor(ctx, r, job)
+			if err != nil {
+				task.Error = err.Error()
+			} else {
+				task.Hosts = r.hosts(te.RunsOn)
+				task.Files = te.Files
+				task.Commands = te.Cmds
+			}
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+	return plan
+}
+
+// hosts returns the names of the endpoints, local runs run everything on the local machine.
+func (r *Run) hosts(endpoints []config.EndpointInfo) []string {
+	if r.Dir != "" {
+		return []string{csync.LocalWorkerName}
+	}
+	names := []string{}
+	for _, ep := range endpoints {
+		names = append(names, ep.Name)
+	}
+	return names
+}
+
+func containsTask(tasks []config.TaskConsumerJobs, name string) bool {
+	return slices.ContainsFunc(tasks, func(t config.TaskConsumerJobs) bool { return t.Name == name })
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/api"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func newPlanTestConfig() config.ValidatedConfig {
+	cfg := newManualTestConfig()
+	cfg.Endpoints = []config.EndpointInfo{{Name: "node-1"}, {Name: "node-2"}}
+	cfg.Pipeline.Build = config.BuildTaskProducer{Name: "app", BuildSteps: []string{"make"}}
+	cfg.Pipeline.Tasks = []config.TaskConsumerJobs{
+		{Name: "unit", RunsOn: []string{"node-1", "node-2"}, File: []string{"a_test.go", "b_test.go"}, Commands: []string{"go test {file}"}},
+		{Name: "release", RunsOn: []string{"node-1"}, File: []string{"release.sh"}, Commands: []string{"./{file}"},
+			On: &config.Trigger{Events: []string{"push"}, Tags: []string{"v*"}}},
+		{Name: "publish", RunsOn: []string{"node-2"}, File: []string{"publish.sh"}, Commands: []string{"./{file}"}, DependsOn: []string{"release"}},
+		{Name: "orphan", RunsOn: []string{"node-2"}, File: []string{"x.sh"}, Commands: []string{"./{file}"}, DependsOn: []string{"missing"}},
+	}
+	return cfg
+}
+
+func TestPlan(t *testing.T) {
+	run := NewRun(newPlanTestConfig(), "pull_request", "feature", "")
+	run.TargetBranch = "main"
+	plan := run.Plan(run.Context())
+
+	if plan.Build.Name != "app" || !slices.Equal(plan.Build.Hosts, []string{"node-1", "node-2"}) {
+		t.Errorf("Unexpected build plan: %+v", plan.Build)
+	}
+	// the files are resolved in the run's work tree.
+	ws := filepath.Join(csync.BuildPath, "app-"+run.ID.String())
+	expected := []api.PlanTask{
+		{
+			Name:     "unit",
+			Run:      true,
+			Hosts:    []string{"node-1", "node-2"},
+			Files:    []string{filepath.Join(ws, "a_test.go"), filepath.Join(ws, "b_test.go")},
+			Commands: []string{"go test " + filepath.Join(ws, "a_test.go"), "go test " + filepath.Join(ws, "b_test.go")},
+		},
+		{Name: "release", Reason: "the trigger filters of the task don't match the run"},
+		{Name: "publish", Reason: "a task it depends on would be skipped"},
+		{Name: "orphan", Reason: "the task depends on a task that isn't part of the run"},
+	}
+	if len(plan.Tasks) != len(expected) {
+		t.Fatalf("Expected %d tasks, got %+v", len(expected), plan.Tasks)
+	}
+	for i, task := range plan.Tasks {
+		want := expected[i]
+		if task.Name != want.Name || task.Run != want.Run || task.Reason != want.Reason {
+			t.Errorf("Expected task %+v, got %+v", want, task)
+		}
+		if want.Run && (!slices.Equal(task.Hosts, want.Hosts) || !slices.Equal(task.Files, want.Files) ||
+			!slices.Equal(task.Commands, want.Commands)) {
+			t.Errorf("Expected task %+v, got %+v", want, task)
+		}
+	}
+	if run.GetState() != QueuedRun || len(run.Tasks) != 0 {
+		t.Errorf("Expected planning to not execute the run")
+	}
+}
+
+func TestPlanLocal(t *testing.T) {
+	dir := t.TempDir()
+	run, err := NewLocalRun(newPlanTestConfig(), dir, []string{"publish"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	plan := run.Plan(run.Context())
+	names := []string{}
+	for _, task := range plan.Tasks {
+		names = append(names, task.Name)
+		if !task.Run || !slices.Equal(task.Hosts, []string{csync.LocalWorkerName}) {
+			t.Errorf("Expected task %s to run locally, got %+v", task.Name, task)
+		}
+	}
+	// the selected task runs with its dependencies, regardless of their trigger filters.
+	if !slices.Equal(names, []string{"release", "publish"}) {
+		t.Errorf("Expected tasks [release publish], got %v", names)
+	}
+	if cmds := plan.Tasks[0].Commands; !slices.Equal(cmds, []string{"./" + filepath.Join(dir, "release.sh")}) {
+		t.Errorf("Unexpected commands: %v", cmds)
+	}
+}
@@ -0,0 +1,208 @@
+package runner
+
+import (
+	"encoding/json"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// save persists the current state of the run, failing to persist is logged and dosen't affect the run.
+// the error is returned for callers that depend on the run being persisted.
+// saves are serialized, the state is taken and written under r.saveMu, so the last save always wins.
+func (r *Run) save() error {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	r.mu.Lock()
+	st := r.store
+	if st == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	rec := r.record()
+	r.mu.Unlock()
+
+	if err := st.SaveRun(rec); err != nil {
+		logger.Printf("Failed to save run %s: %v", r.ID, err)
+		return err
+	}
+	return nil
+}
+
+// record returns the persisted representation of the run, the caller must hold r.mu.
+func (r *Run) record() store.RunRecord {
+	rec := store.RunRecord{
+		ID:            r.ID,
+		State:         r.State.String(),
+		Event:         r.Event,
+		Branch:        r.Branch,
+		BranchRef:     r.BranchRef,
+		SHA:           r.SHA,
+		PRNumber:      r.PRNumber,
+		TargetBranch:  r.TargetBranch,
+		Tag:           r.Tag,
+		ChangedFiles:  r.ChangedFiles,
+		SelectedTasks: r.SelectedTasks,
+		CreatedAt:     r.CreatedAt,
+		StartedAt:     r.StartedAt,
+		FinishedAt:    r.FinishedAt,
+		Builds:        []store.BuildRecord{},
+		Tasks:         []store.TaskRecord{},
+	}
+	if json.Valid(r.Payload) {
+		rec.Payload = r.Payload
+	}
+	for _, install := range r.Installs {
+		rec.Installs = append(rec.Installs, store.InstallRecord{
+			Worker:      install.WorkerName,
+			Fingerprint: install.Fingerprint,
+			Skipped:     install.Skipped,
+			Output:      install.Output,
+			Error:       install.Error,
+		})
+	}
+	for _, build := range r.Builds {
+		if build == nil {
+			continue
+		}
+		b := store.BuildRecord{Worker: build.WorkerName, Output: build.Output, TimedOut: build.TimedOut}
+		if build.Error != nil {
+			b.Error = build.Error.Error
+		}
+		rec.Builds = append(rec.Builds, b)
+	}
+	for _, task := range r.Tasks {
+		t := store.TaskRecord{
+			Name:       task.Name,
+			State:      task.State.String(),
+			Commands:   task.Commands,
+			Outputs:    task.Outputs,
+			Errors:     task.Errors,
+			StartedAt:  task.StartedAt,
+			FinishedAt: task.FinishedAt,
+		}
+		for _, res := range task.Results {
+			t.Results = append(t.Results, commandRecord(res))
+		}
+		rec.Tasks = append(rec.Tasks, t)
+	}
+	for _, task := range r.Running {
+		t := store.RunningTaskRecord{Name: task.Name, ID: task.ID, StartedAt: task.StartedAt}
+		for _, p := range task.Progress {
+			cmd := store.CommandProgressRecord{
+				ID:       p.ID,
+				Command:  p.Command,
+				Status:   p.Status,
+				Host:     p.Host,
+				Attempts: p.Attempts,
+			}
+			if p.Result != nil {
+				res := commandRecord(*p.Result)
+				cmd.Result = &res
+			}
+			t.Commands = append(t.Commands, cmd)
+		}
+		rec.Running = append(rec.Running, t)
+	}
+	return rec
+}
+
+// runFromRecord recreates an unfinished run from its record, so it can be resumed with the config cfg.
+// the builds, the finished tasks and the progress of the running tasks are restored.
+func runFromRecord(cfg config.ValidatedConfig, rec store.RunRecord) *Run {
+	run := NewRun(cfg, rec.Event, rec.Branch, rec.BranchRef)
+	run.ID = rec.ID
+	run.SHA = rec.SHA
+	run.PRNumber = rec.PRNumber
+	run.TargetBranch = rec.TargetBranch
+	run.Tag = rec.Tag
+	run.ChangedFiles = rec.ChangedFiles
+	run.SelectedTasks = rec.SelectedTasks
+	run.CreatedAt = rec.CreatedAt
+	run.StartedAt = rec.StartedAt
+	run.Payload = rec.Payload
+	for _, install := range rec.Installs {
+		run.Installs = append(run.Installs, &syncPB.InstallOutput{
+			WorkerName:  install.Worker,
+			Fingerprint: install.Fingerprint,
+			Skipped:     install.Skipped,
+			Output:      install.Output,
+			Error:       install.Error,
+		})
+	}
+	for _, build := range rec.Builds {
+		b := &syncPB.WorkerBuildOutput{WorkerName: build.Worker, Output: build.Output, TimedOut: build.TimedOut}
+		if build.Error != "" {
+			b.Error = &syncPB.WorkerBuildError{Error: build.Error}
+		}
+		run.Builds = append(run.Builds, b)
+	}
+	for _, task := range rec.Tasks {
+		state, ok := csync.ParseTaskState(task.State)
+		if !ok {
+			state = csync.ErrorInTask
+		}
+		t := TaskResult{
+			Name:       task.Name,
+			State:      state,
+			Commands:   task.Commands,
+			Outputs:    task.Outputs,
+			Errors:     task.Errors,
+			StartedAt:  task.StartedAt,
+			FinishedAt: task.FinishedAt,
+		}
+		for _, res := range task.Results {
+			t.Results = append(t.Results, commandResult(res))
+		}
+		run.Tasks = append(run.Tasks, t)
+	}
+	for _, task := range rec.Running {
+		t := RunningTask{Name: task.Name, ID: task.ID, StartedAt: task.StartedAt}
+		for _, cmd := range task.Commands {
+			p := csync.CommandProgress{
+				ID:       cmd.ID,
+				Command:  cmd.Command,
+				Status:   cmd.Status,
+				Host:     cmd.Host,
+				Attempts: cmd.Attempts,
+			}
+			if cmd.Result != nil {
+				res := commandResult(*cmd.Result)
+				p.Result = &res
+			}
+			t.Progress = append(t.Progress, p)
+		}
+		run.Running = append(run.Running, t)
+	}
+	return run
+}
+
+func commandRecord(res csync.CommandResult) store.CommandRecord {
+	return store.CommandRecord{
+		ID:         res.ID,
+		Command:    res.Command,
+		Host:       res.Host,
+		ExitCode:   res.ExitCode,
+		StartedAt:  res.StartedAt,
+		FinishedAt: res.FinishedAt,
+		Output:     res.Output,
+		TimedOut:   res.TimedOut,
+		Error:      res.Error,
+	}
+}
+
+func commandResult(rec store.CommandRecord) csync.CommandResult {
+	return csync.CommandResult{
+		ID:         rec.ID,
+		Command:    rec.Command,
+		Host:       rec.Host,
+		ExitCode:   rec.ExitCode,
+		StartedAt:  rec.StartedAt,
+		FinishedAt: rec.FinishedAt,
+		Output:     rec.Output,
+		TimedOut:   rec.TimedOut,
+		Error:      rec.Error,
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"slices"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// taskFunc runs a single pipeline task and returns its final state.
+type taskFunc func(task config.TaskConsumerJobs) csync.TaskState
+
+type taskDone struct {
+	name  string
+	state csync.TaskState
+}
+
+// scheduleTasks runs the pipeline tasks as a DAG built from their depends_on field.
+// tasks without pending dependencies run concurrently, a task starts only after all of the
+// tasks it depends on completed successfully, and is skipped if one of them failed, timed out, was skipped or cancelled.
+// a task with parallel set to false runs alone - no other task runs while it's running.
+// it returns the final state of every task, keyed by the task name.
+func scheduleTasks(tasks []config.TaskConsumerJobs, run taskFunc) map[string]csync.TaskState {
+	states := make(map[string]csync.TaskState, len(tasks))
+	pending := slices.Clone(tasks)
+	done := make(chan taskDone)
+	running := 0
+	exclusiveRunning := false
+
+	for len(pending) > 0 || running > 0 {
+		pending = skipFailedDependents(pending, states)
+
+		// start every task that is ready to run, in the order they are defined.
+		remaining := []config.TaskConsumerJobs{}
+		blocked := exclusiveRunning
+		for _, task := range pending {
+			if blocked || !dependenciesCompleted(task, states) {
+				remaining = append(remaining, task)
+				continue
+			}
+			if !runsInParallel(task) {
+				if running > 0 {
+					// wait for the running tasks to finish, and don't start new ones
+					// so the task isn't starved.
+					blocked = true
+					remaining = append(remaining, task)
+					continue
+				}
+				exclusiveRunning = true
+				blocked = true
+			}
+			states[task.Name] = csync.RunningTask
+			running++
+			go func() {
+				done <- taskDone{name: task.Name, state: run(task)}
+			}()
+		}
+		pending = remaining
+
+		if running == 0 {
+			// nothing is running and nothing could be started, the remaining tasks depend on tasks
+			// that don't exist or on each other.
+			for _, task := range pending {
+				logger.Printf("Task %s has unresolvable dependencies %v, skipping.", task.Name, task.DependsOn)
+				states[task.Name] = csync.SkippedTask
+			}
+			break
+		}
+
+		d := <-done
+		running--
+		states[d.name] = d.state
+		if exclusiveRunning && running == 0 {
+			exclusiveRunning = false
+		}
+		logger.Printf("Task %s finished: %s", d.name, d.state)
+	}
+	return states
+}
+
+// skipFailedDependents marks every pending task that depends on a failed or skipped task as skipped,
+// and returns the tasks that are still pending.
+func skipFailedDependents(pending []config.TaskConsumerJobs, states map[string]csync.TaskState) []config.TaskConsumerJobs {
+	for {
+		remaining := []config.TaskConsumerJobs{}
+		skipped := false
+		for _, task := range pending {
+			if dependencyFailed(task, states) {
+				logger.Printf("Skipping task %s, a task it depends on did not complete successfully.", task.Name)
+				states[task.Name] = csync.SkippedTask
+				skipped = true
+				continue
+			}
+			remaining = append(remaining, task)
+		}
+		pending = remaining
+		// skipping a task can cause its own dependents to be skipped.
+		if !skipped {
+			return pending
+		}
+	}
+}
+
+func dependencyFailed(task config.TaskConsumerJobs, states map[string]csync.TaskState) bool {
+	for _, dep := range task.DependsOn {
+		state, ok := states[dep]
+		if !ok {
+			continue
+		}
+		if state.Failed() || state == csync.SkippedTask || state == csync.CancelledTask {
+			return true
+		}
+	}
+	return false
+}
+
+func dependenciesCompleted(task config.TaskConsumerJobs, states map[string]csync.TaskState) bool {
+	for _, dep := range task.DependsOn {
+		if state, ok := states[dep]; !ok || state != csync.CompletedTask {
+			return false
+		}
+	}
+	return true
+}
+
+// runsInParallel returns if the task may run in parallel to other tasks, true by default.
+func runsInParallel(task config.TaskConsumerJobs) bool {
+	return task.RunsInParallel == nil || *task.RunsInParallel
+}
@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// taskRecorder records the order tasks start and finish in, and which tasks overlapped.
+type taskRecorder struct {
+	mu       sync.Mutex
+	running  []string
+	started  []string
+	finished []string
+	overlaps map[string][]string // task name: tasks running when it started
+}
+
+func newTaskRecorder() *taskRecorder {
+	return &taskRecorder{overlaps: map[string][]string{}}
+}
+
+func (r *taskRecorder) run(results map[string]csync.TaskState) taskFunc {
+	return func(task config.TaskConsumerJobs) csync.TaskState {
+		r.mu.Lock()
+		r.overlaps[task.Name] = slices.Clone(r.running)
+		r.running = append(r.running, task.Name)
+		r.started = append(r.started, task.Name)
+		r.mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		r.mu.Lock()
+		r.running = slices.DeleteFunc(r.running, func(n string) bool { return n == task.Name })
+		r.finished = append(r.finished, task.Name)
+		r.mu.Unlock()
+
+		if state, ok := results[task.Name]; ok {
+			return state
+		}
+		return csync.CompletedTask
+	}
+}
+
+func (r *taskRecorder) index(arr []string, name string) int {
+	return slices.Index(arr, name)
+}
+
+func parallel(b bool) *bool {
+	return &b
+}
+
+func TestScheduleTasksIndependentRunConcurrently(t *testing.T) {
+	tasks := []config.TaskConsumerJobs{
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "c"},
+	}
+	rec := newTaskRecorder()
+	states := scheduleTasks(tasks, rec.run(nil))
+
+	for _, task := range tasks {
+		if states[task.Name] != csync.CompletedTask {
+			t.Errorf("Expected task %s to be completed, got %v", task.Name, states[task.Name])
+		}
+	}
+	if len(rec.overlaps["c"]) == 0 && len(rec.overlaps["b"]) == 0 {
+		t.Errorf("Expected independent tasks to run concurrently, got overlaps: %v", rec.overlaps)
+	}
+}
+
+func TestScheduleTasksDependencies(t *testing.T) {
+	tasks := []config.TaskConsumerJobs{
+		{Name: "test", DependsOn: []string{"lint", "unit"}},
+		{Name: "lint"},
+		{Name: "unit"},
+		{Name: "deploy", DependsOn: []string{"test"}},
+	}
+	rec := newTaskRecorder()
+	states := scheduleTasks(tasks, rec.run(nil))
+
+	for _, task := range tasks {
+		if states[task.Name] != csync.CompletedTask {
+			t.Errorf("Expected task %s to be completed, got %v", task.Name, states[task.Name])
+		}
+	}
+	for _, dep := range []string{"lint", "unit"} {
+		if rec.index(rec.finished, dep) > rec.index(rec.started, "test") {
+			t.Errorf("Expected %s to finish before test started, started: %v finished: %v", dep, rec.started, rec.finished)
+		}
+	}
+	if rec.index(rec.started, "deploy") != len(tasks)-1 {
+		t.Errorf("Expected deploy to start last, got: %v", rec.started)
+	}
+}
+
+func TestScheduleTasksSkipsDependentsOfFailedTasks(t *testing.T) {
+	tasks := []config.TaskConsumerJobs{
+		{Name: "build"},
+		{Name: "test", DependsOn: []string{"build"}},
+		{Name: "deploy", DependsOn: []string{"test"}},
+		{Name: "lint"},
+		{Name: "docs", DependsOn: []string{"lint"}},
+	}
+	rec := newTaskRecorder()
+	states := scheduleTasks(tasks, rec.run(map[string]csync.TaskState{
+		"build": csync.CompleteTaskWithErrors,
+		"lint":  csync.TimedOutTask,
+	}))
+
+	expected := map[string]csync.TaskState{
+		"build":  csync.CompleteTaskWithErrors,
+		"test":   csync.SkippedTask,
+		"deploy": csync.SkippedTask,
+		"lint":   csync.TimedOutTask,
+		"docs":   csync.SkippedTask,
+	}
+	for name, state := range expected {
+		if states[name] != state {
+			t.Errorf("Expected task %s state %v, got %v", name, state, states[name])
+		}
+	}
+	if slices.Contains(rec.started, "test") || slices.Contains(rec.started, "deploy") || slices.Contains(rec.started, "docs") {
+		t.Errorf("Expected skipped tasks to not run, started: %v", rec.started)
+	}
+}
+
+func TestScheduleTasksNonParallelRunsAlone(t *testing.T) {
+	tasks := []config.TaskConsumerJobs{
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "exclusive", RunsInParallel: parallel(false), DependsOn: []string{"a"}},
+		{Name: "c", DependsOn: []string{"a"}},
+	}
+	rec := newTaskRecorder()
+	states := scheduleTasks(tasks, rec.run(nil))
+
+	for _, task := range tasks {
+		if states[task.Name] != csync.CompletedTask {
+			t.Errorf("Expected task %s to be completed, got %v", task.Name, states[task.Name])
+		}
+	}
+	if len(rec.overlaps["exclusive"]) != 0 {
+		t.Errorf("Expected exclusive task to start alone, running: %v", rec.overlaps["exclusive"])
+	}
+	for name, overlap := range rec.overlaps {
+		if slices.Contains(overlap, "exclusive") {
+			t.Errorf("Expected no task to start while exclusive task runs, %s started with: %v", name, overlap)
+		}
+	}
+}
+
+func TestScheduleTasksUnresolvableDependencies(t *testing.T) {
+	tasks := []config.TaskConsumerJobs{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+		{Name: "c", DependsOn: []string{"missing"}},
+		{Name: "d"},
+	}
+	rec := newTaskRecorder()
+	states := scheduleTasks(tasks, rec.run(nil))
+
+	expected := map[string]csync.TaskState{
+		"a": csync.SkippedTask,
+		"b": csync.SkippedTask,
+		"c": csync.SkippedTask,
+		"d": csync.CompletedTask,
+	}
+	for name, state := range expected {
+		if states[name] != state {
+			t.Errorf("Expected task %s state %v, got %v", name, state, states[name])
+		}
+	}
+}
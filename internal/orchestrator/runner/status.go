@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/provider/github"
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// StatusContext is the context of the commit status of the whole pipeline,
+// each task reports its own status with the context: StatusContext/<task name>.
+const StatusContext = "conflow-ci"
+
+// statusTimeout bounds reporting a single status, reporting never blocks the pipeline for long.
+const statusTimeout = 10 * time.Second
+
+// statusReporter reports the progress of a run as commit statuses of the commit it builds.
+type statusReporter struct {
+	client     *github.Client
+	repository string
+	publicURL  string // url of the orchestrator's API, statuses link to the run's logs under it. can be empty
+}
+
+// newStatusReporter returns a reporter for the run, or nil if statuses can't be reported -
+// no token is configured or the commit SHA of the run is unknown.
+// the methods of a nil reporter do nothing.
+func newStatusReporter(cfg config.ValidatedConfig, run *Run) *statusReporter {
+	if cfg.Config == nil || cfg.GetToken() == "" || run.SHA == "" {
+		return nil
+	}
+	return &statusReporter{
+		client:     github.NewClient(cfg.GetAPIURL(), cfg.GetToken()),
+		repository: cfg.Provider.Github.Repository,
+		publicURL:  cfg.GetPublicURL(),
+	}
+}
+
+// reportRun reports the overall status of the run according to its state.
+func (s *statusReporter) reportRun(run *Run) {
+	if s == nil {
+		return
+	}
+	var state, desc string
+	switch run.GetState() {
+	case QueuedRun:
+		state, desc = github.StatusPending, "Queued"
+	case RunningRun:
+		state, desc = github.StatusPending, "Running"
+	case CompletedRun:
+		state, desc = github.StatusSuccess, "Pipeline completed"
+	case FailedRun:
+		state, desc = github.StatusFailure, failureDescription(run)
+	case CancelledRun:
+		state, desc = github.StatusError, "Cancelled"
+	case TimedOutRun:
+		state, desc = github.StatusFailure, "Timed out after "+run.cfg.Pipeline.GetTimeout().String()
+	}
+	s.report(run, github.CommitStatus{State: state, Description: desc, Context: StatusContext})
+}
+
+// reportTask reports the status of a single task, pending if it didn't finish yet.
+// commit statuses can be public, so a failed task only reports its failed command and exit code,
+// never the command's output or error, which can include secrets.
+func (s *statusReporter) reportTask(run *Run, res TaskResult) {
+	if s == nil {
+		return
+	}
+	var state, desc string
+	switch res.State {
+	case csync.CompletedTask:
+		state, desc = github.StatusSuccess, "Completed"
+	case csync.SkippedTask:
+		state, desc = github.StatusSuccess, "Skipped"
+	case csync.CompleteTaskWithErrors, csync.ErrorInTask:
+		state, desc = github.StatusFailure, failedCommandDescription(res)
+	case csync.TimedOutTask:
+		state, desc = github.StatusFailure, "Timed out"
+	case csync.CancelledTask:
+		state, desc = github.StatusError, "Cancelled"
+	default:
+		state, desc = github.StatusPending, res.State.String()
+	}
+	s.report(run, github.CommitStatus{State: state, Description: desc, Context: StatusContext + "/" + res.Name})
+}
+
+// report posts the status, failing to report is logged and dosen't affect the run.
+// the run's context isn't used, so the final status of a cancelled run is still reported.
+// the status links to the run's logs if the url of the API is configured.
+func (s *statusReporter) report(run *Run, status github.CommitStatus) {
+	if s.publicURL != "" {
+		status.TargetURL = fmt.Sprintf("%s/runs/%s/logs", s.publicURL, run.ID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+	if err := s.client.CreateCommitStatus(ctx, s.repository, run.SHA, status); err != nil {
+		logger.Printf("Failed to report status %s of run %s: %v", status.Context, run.ID, err)
+	}
+}
+
+// failedCommandDescription describes a failed task by its first failed command and the command's exit code.
+func failedCommandDescription(res TaskResult) string {
+	for _, r := range res.Results {
+		if r.Error == "" {
+			continue
+		}
+		if r.ExitCode < 0 {
+			return fmt.Sprintf("Failed: %s", r.Command)
+		}
+		return fmt.Sprintf("Failed: %s exited with code %d", r.Command, r.ExitCode)
+	}
+	return "Failed"
+}
+
+// failureDescription describes why the run failed, listing the failed tasks.
+func failureDescription(run *Run) string {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	failed := []string{}
+	for _, res := range run.Tasks {
+		if res.State.Failed() {
+			failed = append(failed, res.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return "Build failed"
+	}
+	return fmt.Sprintf("Failed tasks: %s", strings.Join(failed, ", "))
+}
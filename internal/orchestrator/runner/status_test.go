@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ImTheCurse/ConflowCI/internal/provider/github"
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+// statusServer is a stub github API recording the reported commit statuses.
+type statusServer struct {
+	mu       sync.Mutex
+	paths    []string
+	statuses []github.CommitStatus
+}
+
+func (s *statusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var status github.CommitStatus
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.statuses = append(s.statuses, status)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func newStatusTestConfig(apiURL, token string) config.ValidatedConfig {
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{
+			Provider: config.Provider{
+				Github: config.Github{Repository: "org/repo", APIURL: apiURL},
+			},
+		},
+	}
+	if token != "" {
+		cfg.Provider.Github.Auth = &config.Auth{Token: token}
+	}
+	return cfg
+}
+
+func TestStatusReporter(t *testing.T) {
+	stub := &statusServer{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	cfg := newStatusTestConfig(srv.URL, "token")
+	run := NewRun(cfg, "pull_request", "feature", "pull/1/head:pr-1")
+	run.SHA = "abc1234"
+	reporter := newStatusReporter(cfg, run)
+	if reporter == nil {
+		t.Fatalf("Expected a status reporter")
+	}
+
+	run.setState(RunningRun)
+	reporter.reportRun(run)
+	reporter.reportTask(run, TaskResult{Name: "lint", State: csync.CompletedTask})
+	reporter.reportTask(run, TaskResult{Name: "test", State: csync.CompleteTaskWithErrors,
+		Errors: []string{"exit status 1\nTOKEN=secret"},
+		Results: []csync.CommandResult{
+			{Command: "go vet ./...", ExitCode: 0},
+			{Command: "go test ./...", ExitCode: 1, Error: "exit status 1\nTOKEN=secret", Output: "TOKEN=secret"},
+		},
+	})
+	reporter.reportTask(run, TaskResult{Name: "unit", State: csync.ErrorInTask, Errors: []string{"no usable hosts"}})
+	reporter.reportTask(run, TaskResult{Name: "deploy", State: csync.SkippedTask})
+	run.addTaskResult(TaskResult{Name: "test", State: csync.CompleteTaskWithErrors})
+	run.setState(FailedRun)
+	reporter.reportRun(run)
+
+	expected := []github.CommitStatus{
+		{State: github.StatusPending, Description: "Running", Context: "conflow-ci"},
+		{State: github.StatusSuccess, Description: "Completed", Context: "conflow-ci/lint"},
+		{State: github.StatusFailure, Description: "Failed: go test ./... exited with code 1", Context: "conflow-ci/test"},
+		{State: github.StatusFailure, Description: "Failed", Context: "conflow-ci/unit"},
+		{State: github.StatusSuccess, Description: "Skipped", Context: "conflow-ci/deploy"},
+		{State: github.StatusFailure, Description: "Failed tasks: test", Context: "conflow-ci"},
+	}
+	if len(stub.statuses) != len(expected) {
+		t.Fatalf("Expected %d statuses, got %d: %+v", len(expected), len(stub.statuses), stub.statuses)
+	}
+	for i, status := range expected {
+		if stub.statuses[i] != status {
+			t.Errorf("Expected status %+v, got %+v", status, stub.statuses[i])
+		}
+		if stub.paths[i] != "/repos/org/repo/statuses/abc1234" {
+			t.Errorf("Unexpected path: %s", stub.paths[i])
+		}
+	}
+}
+
+func TestStatusReporterDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		sha   string
+	}{
+		{name: "no token", token: "", sha: "abc1234"},
+		{name: "unknown sha", token: "token", sha: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newStatusTestConfig("http://127.0.0.1:0", tt.token)
+			run := NewRun(cfg, "push", "main", "abc:push-abc")
+			run.SHA = tt.sha
+			reporter := newStatusReporter(cfg, run)
+			if reporter != nil {
+				t.Errorf("Expected no status reporter")
+			}
+			// a nil reporter dosen't report anything.
+			reporter.reportRun(run)
+		})
+	}
+}
+
+func TestStatusReporterLinksLogs(t *testing.T) {
+	stub := &statusServer{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	cfg := newStatusTestConfig(srv.URL, "token")
+	cfg.API = &config.API{PublicURL: "https://ci.example.com/"}
+	run := NewRun(cfg, "push", "main", "abc:push-abc")
+	run.SHA = "abc1234"
+	newStatusReporter(cfg, run).reportTask(run, TaskResult{Name: "test", State: csync.CompletedTask})
+
+	if len(stub.statuses) != 1 {
+		t.Fatalf("Expected 1 status, got %+v", stub.statuses)
+	}
+	if expected := "https://ci.example.com/runs/" + run.ID.String() + "/logs"; stub.statuses[0].TargetURL != expected {
+		t.Errorf("Expected the status to link to %s, got %s", expected, stub.statuses[0].TargetURL)
+	}
+}
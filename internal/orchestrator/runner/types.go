@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/orchestrator/store"
+	csync "github.com/ImTheCurse/ConflowCI/internal/sync"
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/google/uuid"
+)
+
+var logger = log.New(os.Stdout, "[Runner]: ", log.Lshortfile|log.LstdFlags)
+
+// Current state of a pipeline run.
+type RunState uint
+
+const (
+	QueuedRun RunState = iota
+	RunningRun
+	CompletedRun
+	FailedRun
+	CancelledRun
+	TimedOutRun // the run exceeded the pipeline timeout and was stopped
+)
+
+func (s RunState) String() string {
+	switch s {
+	case QueuedRun:
+		return "Queued"
+	case RunningRun:
+		return "Running"
+	case CompletedRun:
+		return "Completed"
+	case FailedRun:
+		return "Failed"
+	case CancelledRun:
+		return "Cancelled"
+	case TimedOutRun:
+		return "Timed out"
+	default:
+		return "Unkown run state"
+	}
+}
+
+// Run represents a single execution of the pipeline, triggered by a webhook event.
+// a run is enqueued by the webhook handler and executed in the background by the Manager.
+type Run struct {
+	ID        uuid.UUID
+	State     RunState
+	Event     string // github event that triggered the run
+	Branch    string // branch cloned on the workers
+	BranchRef string // refspec fetched on the workers
+	SHA       string // commit that is built
+	PRNumber  int
+
+	// fields used to evaluate the pipeline and task trigger filters.
+	TargetBranch string   // pushed branch, or the target branch of a pull request
+	Tag          string   // pushed tag
+	ChangedFiles []string // nil if the changed files are unknown
+
+	SelectedTasks []string // tasks selected by a manual run, nil runs every task
+	Dir           string   // checkout a local run builds and runs in, empty if the run is executed by the workers
+
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	Installs []*syncPB.InstallOutput // install steps of the hosts, run before the build
+	Builds   []*syncPB.WorkerBuildOutput
+	Tasks    []TaskResult
+	Running  []RunningTask // tasks whose commands are published to the durable message queue, while they run
+	Payload  []byte        // webhook payload that triggered the run
+
+	cfg      config.ValidatedConfig
+	store    *store.Store // nil if the run isn't persisted
+	logs     *logStream
+	ctx      context.Context // cancelled when the run is cancelled or times out
+	cancel   context.CancelFunc
+	timedOut bool // the run was cancelled since it exceeded the pipeline timeout
+	resumed  bool // the run was reloaded from the store after the orchestrator restarted
+	mu       sync.Mutex
+	saveMu   sync.Mutex // held while the run is saved, so an older state never overwrites a newer one
+}
+
+// TaskResult is the final result of a single pipeline task in a run.
+type TaskResult struct {
+	Name       string
+	State      csync.TaskState
+	Commands   []string
+	Outputs    []string
+	Errors     []string
+	Results    []csync.CommandResult // result of every command, nil if the task didn't run its commands
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// RunningTask is the progress of a pipeline task whose commands are published to the durable message queue,
+// the task is resumed from it if the orchestrator restarts while the task runs.
+type RunningTask struct {
+	Name      string
+	ID        uuid.UUID // ID of the task's executor, its queues are named by it
+	StartedAt time.Time
+	Progress  []csync.CommandProgress
+}
+
+// Manager executes enqueued runs using a bounded pool of workers,
+// so a burst of webhook deliveries dosen't start an unbounded amount of pipelines.
+type Manager struct {
+	queue   chan *Run
+	workers int
+
+	mu    sync.RWMutex
+	runs  map[uuid.UUID]*Run // unfinished runs, and finished runs without a store
+	wg    sync.WaitGroup
+	store *store.Store // persists the runs, can be nil
+
+	// execute runs the pipeline for a run, replaced in tests.
+	execute func(*Run)
+}
@@ -0,0 +1,5 @@
+package store
+
+import "errors"
+
+var ErrRunNotFound = errors.New("Run not found")
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var runsBucket = []byte("runs")
+
+// pruneInterval is how often finished runs older than the retention are deleted.
+const pruneInterval = time.Hour
+
+// Open opens the store at path, creating it if it dosen't exist.
+// finished runs older than retention are pruned periodically, a retention of 0 keeps them forever.
+func Open(path string, retention time.Duration) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(runsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{db: db, retention: retention, done: make(chan struct{})}
+	if retention > 0 {
+		s.wg.Add(1)
+		go s.prunePeriodically()
+	}
+	return s, nil
+}
+
+// Close stops pruning and closes the database.
+func (s *Store) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// SaveRun creates or replaces the record of a run.
+func (s *Store) SaveRun(rec RunRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).Put(rec.ID[:], b)
+	})
+}
+
+// GetRun returns the record of a run, or ErrRunNotFound.
+func (s *Store) GetRun(id uuid.UUID) (RunRecord, error) {
+	var rec RunRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runsBucket).Get(id[:])
+		if b == nil {
+			return ErrRunNotFound
+		}
+		return json.Unmarshal(b, &rec)
+	})
+	return rec, err
+}
+
+// ListRuns returns the runs matching filter, newest first.
+func (s *Store) ListRuns(filter RunFilter) ([]RunRecord, error) {
+	runs := []RunRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+			var rec RunRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if filter.Matches(rec) {
+				runs = append(runs, rec)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(runs, func(a, b RunRecord) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if filter.Limit > 0 && len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+	return runs, nil
+}
+
+// Prune deletes the runs that finished before t, and returns the amount of runs deleted.
+// unfinished runs are never pruned.
+func (s *Store) Prune(t time.Time) (int, error) {
+	pruned := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runsBucket)
+		keys := [][]byte{}
+		err := bucket.ForEach(func(k, v []byte) error {
+			var rec RunRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if !rec.FinishedAt.IsZero() && rec.FinishedAt.Before(t) {
+				keys = append(keys, slices.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// keys can't be deleted while iterating over the bucket.
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		pruned = len(keys)
+		return nil
+	})
+	return pruned, err
+}
+
+func (s *Store) prunePeriodically() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Prune(time.Now().Add(-s.retention))
+		if err != nil {
+			logger.Printf("Failed to prune runs: %v", err)
+		} else if n > 0 {
+			logger.Printf("Pruned %d runs older than %v", n, s.retention)
+		}
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Matches returns if the run record is selected by the filter.
+func (f RunFilter) Matches(rec RunRecord) bool {
+	if f.PRNumber != 0 && rec.PRNumber != f.PRNumber {
+		return false
+	}
+	if f.Branch != "" && rec.Branch != f.Branch && rec.TargetBranch != f.Branch {
+		return false
+	}
+	if f.State != "" && rec.State != f.State {
+		return false
+	}
+	if !f.Since.IsZero() && rec.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if f.Unfinished && !rec.FinishedAt.IsZero() {
+		return false
+	}
+	return true
+}
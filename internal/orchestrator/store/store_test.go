@@ -0,0 +1,137 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func openTestStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "runs.db"), 0)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSaveAndGetRun(t *testing.T) {
+	s := openTestStore(t)
+	rec := RunRecord{
+		ID:        uuid.New(),
+		State:     "Failed",
+		Event:     "pull_request",
+		Branch:    "feature",
+		SHA:       "abc1234",
+		PRNumber:  42,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Builds:    []BuildRecord{{Worker: "worker-1", Output: "ok"}},
+		Tasks: []TaskRecord{{
+			Name:     "test",
+			State:    "Completed with errors",
+			Commands: []string{"go test ./..."},
+			Errors:   []string{"exit status 1"},
+		}},
+		Payload: []byte(`{"action":"opened"}`),
+	}
+	if err := s.SaveRun(rec); err != nil {
+		t.Fatalf("Failed to save run: %v", err)
+	}
+	got, err := s.GetRun(rec.ID)
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	if got.PRNumber != 42 || got.State != "Failed" || !got.CreatedAt.Equal(rec.CreatedAt) {
+		t.Errorf("Unexpected run: %+v", got)
+	}
+	if len(got.Tasks) != 1 || got.Tasks[0].Commands[0] != "go test ./..." || got.Tasks[0].Errors[0] != "exit status 1" {
+		t.Errorf("Unexpected tasks: %+v", got.Tasks)
+	}
+	if len(got.Builds) != 1 || got.Builds[0].Worker != "worker-1" {
+		t.Errorf("Unexpected builds: %+v", got.Builds)
+	}
+	if string(got.Payload) != `{"action":"opened"}` {
+		t.Errorf("Unexpected payload: %s", got.Payload)
+	}
+
+	if _, err := s.GetRun(uuid.New()); err != ErrRunNotFound {
+		t.Errorf("Expected error: %v, got: %v", ErrRunNotFound, err)
+	}
+}
+
+func TestListRuns(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now()
+	runs := []RunRecord{
+		{ID: uuid.New(), State: "Completed", PRNumber: 42, Branch: "feature", TargetBranch: "main", CreatedAt: now.Add(-48 * time.Hour), FinishedAt: now.Add(-47 * time.Hour)},
+		{ID: uuid.New(), State: "Failed", PRNumber: 42, Branch: "feature", TargetBranch: "main", CreatedAt: now.Add(-24 * time.Hour), FinishedAt: now.Add(-23 * time.Hour)},
+		{ID: uuid.New(), State: "Failed", Branch: "main", TargetBranch: "main", CreatedAt: now.Add(-time.Hour), FinishedAt: now},
+		{ID: uuid.New(), State: "Running", PRNumber: 7, Branch: "fix", TargetBranch: "release", CreatedAt: now},
+	}
+	for _, rec := range runs {
+		if err := s.SaveRun(rec); err != nil {
+			t.Fatalf("Failed to save run: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   RunFilter
+		expected []RunRecord
+	}{
+		{name: "all newest first", filter: RunFilter{}, expected: []RunRecord{runs[3], runs[2], runs[1], runs[0]}},
+		{name: "pull request", filter: RunFilter{PRNumber: 42}, expected: []RunRecord{runs[1], runs[0]}},
+		{name: "failed pull request", filter: RunFilter{PRNumber: 42, State: "Failed"}, expected: []RunRecord{runs[1]}},
+		{name: "target branch", filter: RunFilter{Branch: "main"}, expected: []RunRecord{runs[2], runs[1], runs[0]}},
+		{name: "since", filter: RunFilter{Since: now.Add(-2 * time.Hour)}, expected: []RunRecord{runs[3], runs[2]}},
+		{name: "limit", filter: RunFilter{Limit: 1}, expected: []RunRecord{runs[3]}},
+		{name: "unfinished", filter: RunFilter{Unfinished: true}, expected: []RunRecord{runs[3]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ListRuns(tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to list runs: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %d runs, got %d", len(tt.expected), len(got))
+			}
+			for i := range got {
+				if got[i].ID != tt.expected[i].ID {
+					t.Errorf("Expected run %d to be %s, got %s", i, tt.expected[i].ID, got[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now()
+	old := RunRecord{ID: uuid.New(), State: "Completed", CreatedAt: now.Add(-72 * time.Hour), FinishedAt: now.Add(-71 * time.Hour)}
+	recent := RunRecord{ID: uuid.New(), State: "Completed", CreatedAt: now.Add(-time.Hour), FinishedAt: now}
+	unfinished := RunRecord{ID: uuid.New(), State: "Running", CreatedAt: now.Add(-72 * time.Hour)}
+	for _, rec := range []RunRecord{old, recent, unfinished} {
+		if err := s.SaveRun(rec); err != nil {
+			t.Fatalf("Failed to save run: %v", err)
+		}
+	}
+
+	n, err := s.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to prune runs: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 run to be pruned, got %d", n)
+	}
+	if _, err := s.GetRun(old.ID); err != ErrRunNotFound {
+		t.Errorf("Expected old run to be pruned, got: %v", err)
+	}
+	for _, rec := range []RunRecord{recent, unfinished} {
+		if _, err := s.GetRun(rec.ID); err != nil {
+			t.Errorf("Expected run %s to be kept, got: %v", rec.State, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var logger = log.New(os.Stdout, "[Store]: ", log.Lshortfile|log.LstdFlags)
+
+// Store is an embedded database keeping the history of pipeline runs.
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration // finished runs older than retention are pruned, 0 keeps runs forever
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// RunRecord is the persisted state of a pipeline run.
+type RunRecord struct {
+	ID            uuid.UUID           `json:"id"`
+	State         string              `json:"state"`
+	Event         string              `json:"event"`
+	Branch        string              `json:"branch"`
+	BranchRef     string              `json:"branch_ref"`
+	SHA           string              `json:"sha"`
+	PRNumber      int                 `json:"pr_number,omitempty"`
+	TargetBranch  string              `json:"target_branch,omitempty"`
+	Tag           string              `json:"tag,omitempty"`
+	ChangedFiles  []string            `json:"changed_files,omitempty"`
+	SelectedTasks []string            `json:"selected_tasks,omitempty"` // tasks selected by a manual run
+	CreatedAt     time.Time           `json:"created_at"`
+	StartedAt     time.Time           `json:"started_at"`
+	FinishedAt    time.Time           `json:"finished_at"`
+	Installs      []InstallRecord     `json:"installs,omitempty"`
+	Builds        []BuildRecord       `json:"builds"`
+	Tasks         []TaskRecord        `json:"tasks"`
+	Running       []RunningTaskRecord `json:"running,omitempty"` // tasks whose commands are published, while they run
+	Payload       json.RawMessage     `json:"payload,omitempty"` // webhook payload that triggered the run
+}
+
+// InstallRecord is the output of running the install steps of a single host.
+type InstallRecord struct {
+	Worker      string `json:"worker"`
+	Fingerprint string `json:"fingerprint"`
+	Skipped     bool   `json:"skipped"` // the steps already completed with the same fingerprint
+	Output      string `json:"output,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BuildRecord is the output of building the repository on a single worker.
+type BuildRecord struct {
+	Worker   string `json:"worker"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"` // the build exceeded the build timeout and was killed
+}
+
+// TaskRecord is the result of a single pipeline task.
+type TaskRecord struct {
+	Name       string          `json:"name"`
+	State      string          `json:"state"`
+	Commands   []string        `json:"commands"`
+	Outputs    []string        `json:"outputs"`
+	Errors     []string        `json:"errors"`
+	Results    []CommandRecord `json:"results,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+}
+
+// RunningTaskRecord is the progress of a task whose commands were published to the message queue,
+// it's kept while the task runs so the task can be resumed once the orchestrator restarts.
+type RunningTaskRecord struct {
+	Name      string                  `json:"name"`
+	ID        uuid.UUID               `json:"id"` // the task's queues are named by it
+	StartedAt time.Time               `json:"started_at"`
+	Commands  []CommandProgressRecord `json:"commands"`
+}
+
+// CommandProgressRecord is the progress of a single published command of a running task.
+type CommandProgressRecord struct {
+	ID       string         `json:"id"`
+	Command  string         `json:"command"`
+	Status   string         `json:"status"`
+	Host     string         `json:"host,omitempty"`
+	Attempts int            `json:"attempts"`
+	Result   *CommandRecord `json:"result,omitempty"` // set once the command is done
+}
+
+// CommandRecord is the result of a single command of a task, on the host that ran it.
+type CommandRecord struct {
+	ID         string    `json:"id"`
+	Command    string    `json:"command"`
+	Host       string    `json:"host"`
+	ExitCode   int       `json:"exit_code"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Output     string    `json:"output"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RunFilter selects runs when listing them, zero value fields are ignored.
+type RunFilter struct {
+	PRNumber int
+	Branch   string // matches the cloned or the target branch
+	State    string
+	Since    time.Time // runs created at or after Since
+	Limit    int
+
+	Unfinished bool // only runs that didn't finish, e.g. since the orchestrator stopped while they ran
+}
@@ -0,0 +1,117 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPullRequestFiles is the maximum amount of files github lists for a pull request.
+const maxPullRequestFiles = 3000
+const filesPerPage = 100
+
+// maxStatusDescription is the maximum length of a commit status description, in characters.
+const maxStatusDescription = 140
+
+// Client is a minimal client of the github REST API.
+type Client struct {
+	BaseURL    string // e.g. https://api.github.com
+	Token      string // PAT token, can be empty for public repositories
+	httpClient *http.Client
+}
+
+// NewClient creates a github REST API client, baseURL is configurable so the client
+// can be used with github enterprise or a local stub server.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		Token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ListPullRequestFiles returns the names of the files changed by a pull request,
+// repository is in the format: owner/repo.
+func (c *Client) ListPullRequestFiles(ctx context.Context, repository string, number int) ([]string, error) {
+	files := []string{}
+	for page := 1; len(files) < maxPullRequestFiles; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls/%d/files?per_page=%d&page=%d",
+			c.BaseURL, repository, number, filesPerPage, page)
+		var res []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename,omitempty"`
+		}
+		if err := c.do(ctx, http.MethodGet, url, nil, &res); err != nil {
+			return nil, err
+		}
+		for _, f := range res {
+			files = append(files, f.Filename)
+			// a renamed file changes its old path as well.
+			if f.PreviousFilename != "" {
+				files = append(files, f.PreviousFilename)
+			}
+		}
+		if len(res) < filesPerPage {
+			break
+		}
+	}
+	return files, nil
+}
+
+// CreateCommitStatus creates a commit status for sha, a status with the same context replaces the
+// previous one. repository is in the format: owner/repo.
+func (c *Client) CreateCommitStatus(ctx context.Context, repository, sha string, status CommitStatus) error {
+	status.Description = truncateDescription(status.Description)
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/repos/%s/statuses/%s", c.BaseURL, repository, sha)
+	return c.do(ctx, http.MethodPost, url, bytes.NewReader(b), nil)
+}
+
+// truncateDescription truncates a description longer than maxStatusDescription characters, cutting
+// it on a character boundary so multi-byte characters aren't split.
+func truncateDescription(s string) string {
+	if utf8.RuneCountInString(s) <= maxStatusDescription {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxStatusDescription-3]) + "..."
+}
+
+// do sends a request to the github API, and decodes the JSON response into out if it's not nil.
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return APIError{StatusCode: resp.StatusCode, Message: string(b)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCreateCommitStatus(t *testing.T) {
+	var got CommitStatus
+	var path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode status: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "token")
+	status := CommitStatus{
+		State:       StatusFailure,
+		Description: strings.Repeat("a", 200),
+		Context:     "conflow-ci",
+	}
+	if err := client.CreateCommitStatus(context.Background(), "org/repo", "abc1234", status); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != "/repos/org/repo/statuses/abc1234" {
+		t.Errorf("Unexpected path: %s", path)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("Unexpected authorization header: %s", auth)
+	}
+	if got.State != StatusFailure || got.Context != "conflow-ci" {
+		t.Errorf("Unexpected status: %+v", got)
+	}
+	if len(got.Description) != maxStatusDescription {
+		t.Errorf("Expected description to be truncated to %d characters, got %d", maxStatusDescription, len(got.Description))
+	}
+}
+
+func TestCreateCommitStatusAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "token")
+	err := client.CreateCommitStatus(context.Background(), "org/repo", "abc1234", CommitStatus{State: StatusPending})
+	apiErr, ok := err.(APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected API error with status %d, got: %v", http.StatusNotFound, err)
+	}
+}
+
+func TestTruncateDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		desc     string
+		expected string
+	}{
+		{name: "short", desc: "Build passed", expected: "Build passed"},
+		{name: "multi-byte at the limit", desc: strings.Repeat("é", maxStatusDescription), expected: strings.Repeat("é", maxStatusDescription)},
+		{name: "ascii", desc: strings.Repeat("a", 200), expected: strings.Repeat("a", maxStatusDescription-3) + "..."},
+		{name: "multi-byte", desc: strings.Repeat("✓", 200), expected: strings.Repeat("✓", maxStatusDescription-3) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateDescription(tt.desc)
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Expected a valid utf-8 description, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoWebhookSecret = errors.New("No webhook secret configured, refusing to accept unsigned deliveries")
+var ErrMissingSignature = errors.New("Missing X-Hub-Signature-256 header")
+var ErrInvalidSignature = errors.New("Webhook signature does not match payload")
+
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("github API error: status %d: %s", e.StatusCode, e.Message)
+}
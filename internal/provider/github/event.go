@@ -0,0 +1,70 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+const branchRefPrefix = "refs/heads/"
+const tagRefPrefix = "refs/tags/"
+
+// zeroSHA is sent by github as the after SHA when a ref is deleted.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// RefSpec returns the refspec that fetches the pull request head on the workers.
+func (p PullRequestPayload) RefSpec() string {
+	return fmt.Sprintf("pull/%d/head:pr-%d", p.Number, p.PullRequest.ID)
+}
+
+// RefSpec returns the refspec that fetches the pushed commit on the workers,
+// the commit is fetched by its exact SHA so a later push to the same branch
+// dosen't change what is built.
+func (p PushPayload) RefSpec() string {
+	return fmt.Sprintf("%s:push-%s", p.After, ShortSHA(p.After))
+}
+
+// Branch returns the name of the pushed branch, or an empty string if the push wasn't to a branch.
+func (p PushPayload) Branch() string {
+	if !strings.HasPrefix(p.Ref, branchRefPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(p.Ref, branchRefPrefix)
+}
+
+// Tag returns the name of the pushed tag, or an empty string if the push wasn't to a tag.
+func (p PushPayload) Tag() string {
+	if !strings.HasPrefix(p.Ref, tagRefPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(p.Ref, tagRefPrefix)
+}
+
+// IsDelete returns true if the push deleted the ref, there is nothing to build for such push.
+func (p PushPayload) IsDelete() bool {
+	return p.Deleted || p.After == zeroSHA
+}
+
+// ChangedFiles returns the files added, removed or modified by the pushed commits.
+func (p PushPayload) ChangedFiles() []string {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, commit := range p.Commits {
+		for _, changed := range [][]string{commit.Added, commit.Removed, commit.Modified} {
+			for _, f := range changed {
+				if !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// ShortSHA returns the abbreviated commit SHA, as shown by git.
+func ShortSHA(sha string) string {
+	if len(sha) < 7 {
+		return sha
+	}
+	return sha[:7]
+}
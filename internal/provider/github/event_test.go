@@ -0,0 +1,66 @@
+package github
+
+import (
+	"testing"
+)
+
+func TestPushPayloadRefs(t *testing.T) {
+	sha := "b3cbd5bbd7e81436d2eee04537ea2b4c0cad4cdf"
+	tests := []struct {
+		name       string
+		payload    PushPayload
+		wantBranch string
+		wantTag    string
+		wantDelete bool
+	}{
+		{
+			name:       "branch-push",
+			payload:    PushPayload{Ref: "refs/heads/main", After: sha},
+			wantBranch: "main",
+		},
+		{
+			name:       "nested-branch-push",
+			payload:    PushPayload{Ref: "refs/heads/feature/push-events", After: sha},
+			wantBranch: "feature/push-events",
+		},
+		{
+			name:    "tag-push",
+			payload: PushPayload{Ref: "refs/tags/v1.0.0", After: sha},
+			wantTag: "v1.0.0",
+		},
+		{
+			name:       "branch-delete",
+			payload:    PushPayload{Ref: "refs/heads/main", After: zeroSHA, Deleted: true},
+			wantBranch: "main",
+			wantDelete: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payload.Branch(); got != tt.wantBranch {
+				t.Errorf("Expected branch %q, got %q", tt.wantBranch, got)
+			}
+			if got := tt.payload.Tag(); got != tt.wantTag {
+				t.Errorf("Expected tag %q, got %q", tt.wantTag, got)
+			}
+			if got := tt.payload.IsDelete(); got != tt.wantDelete {
+				t.Errorf("Expected IsDelete %v, got %v", tt.wantDelete, got)
+			}
+		})
+	}
+}
+
+func TestRefSpec(t *testing.T) {
+	push := PushPayload{Ref: "refs/heads/main", After: "b3cbd5bbd7e81436d2eee04537ea2b4c0cad4cdf"}
+	expected := "b3cbd5bbd7e81436d2eee04537ea2b4c0cad4cdf:push-b3cbd5b"
+	if got := push.RefSpec(); got != expected {
+		t.Errorf("Expected push refspec %s, got %s", expected, got)
+	}
+
+	pr := PullRequestPayload{Number: 6, PullRequest: PullRequest{ID: 12}}
+	expected = "pull/6/head:pr-12"
+	if got := pr.RefSpec(); got != expected {
+		t.Errorf("Expected pull request refspec %s, got %s", expected, got)
+	}
+}
@@ -16,6 +16,36 @@ type PullRequestPayload struct {
 	Repository  Repository  `json:"repository"`
 }
 
+// PushPayload represents the GitHub webhook payload for pushes to a branch or a tag
+type PushPayload struct {
+	Ref        string     `json:"ref"`     // full ref that was pushed, e.g. refs/heads/main
+	Before     string     `json:"before"`  // SHA of the most recent commit on ref before the push
+	After      string     `json:"after"`   // SHA of the most recent commit on ref after the push
+	Created    bool       `json:"created"` // the push created the ref
+	Deleted    bool       `json:"deleted"` // the push deleted the ref
+	Pusher     Committer  `json:"pusher"`
+	Sender     User       `json:"sender"` // Github user
+	HeadCommit *Commit    `json:"head_commit"`
+	Commits    []Commit   `json:"commits"` // pushed commits, github includes up to 2048 commits
+	Repository Repository `json:"repository"`
+}
+
+// Commit represents a pushed commit and the files it changed
+type Commit struct {
+	ID       string    `json:"id"` // commit SHA
+	Message  string    `json:"message"`
+	Author   Committer `json:"author"`
+	Added    []string  `json:"added"`
+	Removed  []string  `json:"removed"`
+	Modified []string  `json:"modified"`
+}
+
+// Committer represents the git author or pusher of a commit
+type Committer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
 // PullRequest contains the details about the PR itself
 type PullRequest struct {
 	ID           int    `json:"id"`
@@ -52,3 +82,19 @@ type User struct {
 
 type GitRepoReader struct {
 }
+
+// CommitStatus represents a commit status, shown on the commit and the pull requests containing it
+type CommitStatus struct {
+	State       string `json:"state"`                 // one of: pending, success, failure, error
+	TargetURL   string `json:"target_url,omitempty"`  // link to the status details
+	Description string `json:"description,omitempty"` // short description, github allows up to 140 characters
+	Context     string `json:"context"`               // label that differentiates this status from other systems
+}
+
+// commit status states
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
@@ -108,7 +108,7 @@ func (reader *GitRepoReader) CreateWorkTree(ctx context.Context, req *pb.WorkTre
 	return &pb.SyncResponse{Output: "Worktree created successfully", Error: nil}, nil
 }
 
-// RemoveWorkTree removes a worktree from the repository.
+// RemoveWorkTree removes a worktree from the repository, and the branch it checked out if req.BranchName is set.
 func (reader *GitRepoReader) RemoveWorkTree(ctx context.Context, req *pb.WorkTreeRequest) (*pb.SyncResponse, error) {
 	args := []string{"worktree", "remove", req.WorktreeRelPath}
 	cmd := exec.Command("git", args...)
@@ -120,6 +120,15 @@ func (reader *GitRepoReader) RemoveWorkTree(ctx context.Context, req *pb.WorkTre
 			Reason: fmt.Sprintf("Failed to remove worktree: %v | output: %s", err, string(b)),
 		}}, nil
 	}
+	if req.BranchName != "" {
+		cmd = exec.Command("git", "branch", "-D", req.BranchName)
+		cmd.Dir = req.RepoDir
+		if b, err := cmd.CombinedOutput(); err != nil {
+			return &pb.SyncResponse{Error: &pb.SyncError{
+				Reason: fmt.Sprintf("Failed to remove worktree branch: %v | output: %s", err, string(b)),
+			}}, nil
+		}
+	}
 	logger.Println("Worktree removed successfully")
 	return &pb.SyncResponse{Output: "Worktree removed successfully", Error: nil}, nil
 }
@@ -0,0 +1,48 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const SignatureHeader = "X-Hub-Signature-256"
+const signaturePrefix = "sha256="
+
+// ValidateSignature verifies the signature github sends in the X-Hub-Signature-256 header.
+// github signs the raw request body with HMAC-SHA256 keyed with the webhook secret,
+// you can read more here:
+// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+func ValidateSignature(body []byte, signature, secret string) error {
+	if secret == "" {
+		return ErrNoWebhookSecret
+	}
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	// constant time comparison, so the signature can't be guessed byte by byte.
+	if !hmac.Equal(got, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the X-Hub-Signature-256 header value for the body, keyed with the secret.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"testing"
+)
+
+func TestValidateSignature(t *testing.T) {
+	body := []byte(`{"action":"opened","number":1}`)
+	secret := "very-secret"
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		secret    string
+		wantErr   error
+	}{
+		{
+			name:      "valid-signature",
+			body:      body,
+			signature: Sign(body, secret),
+			secret:    secret,
+			wantErr:   nil,
+		},
+		{
+			name:      "no-secret-configured",
+			body:      body,
+			signature: Sign(body, secret),
+			secret:    "",
+			wantErr:   ErrNoWebhookSecret,
+		},
+		{
+			name:      "missing-signature",
+			body:      body,
+			signature: "",
+			secret:    secret,
+			wantErr:   ErrMissingSignature,
+		},
+		{
+			name:      "signed-with-different-secret",
+			body:      body,
+			signature: Sign(body, "another-secret"),
+			secret:    secret,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "tampered-body",
+			body:      []byte(`{"action":"opened","number":2}`),
+			signature: Sign(body, secret),
+			secret:    secret,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "sha1-signature-prefix",
+			body:      body,
+			signature: "sha1=" + Sign(body, secret)[len(signaturePrefix):],
+			secret:    secret,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "non-hex-signature",
+			body:      body,
+			signature: "sha256=not-a-hex-digest",
+			secret:    secret,
+			wantErr:   ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSignature(tt.body, tt.signature, tt.secret)
+			if err != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
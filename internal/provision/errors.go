@@ -0,0 +1,46 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoWorkerBinary = errors.New("No worker binary specified")
+
+type ErrNoSSHAccess struct {
+	Host string
+}
+
+func (e ErrNoSSHAccess) Error() string {
+	return fmt.Sprintf("Host %s has no ssh access configured, set hosts[].ssh to provision it", e.Host)
+}
+
+type ErrUnknownHost struct {
+	Host string
+}
+
+func (e ErrUnknownHost) Error() string {
+	return fmt.Sprintf("Host %s is not defined in hosts", e.Host)
+}
+
+type ErrAgentlessHost struct {
+	Host string
+}
+
+func (e ErrAgentlessHost) Error() string {
+	return fmt.Sprintf("Host %s uses the ssh executor and doesn't run a worker", e.Host)
+}
+
+type ErrWorkerUnreachable struct {
+	Host string
+	Addr string
+	Err  error
+}
+
+func (e ErrWorkerUnreachable) Error() string {
+	return fmt.Sprintf("Worker on host %s started, but its gRPC port %s didn't answer: %v", e.Host, e.Addr, e.Err)
+}
+
+func (e ErrWorkerUnreachable) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,233 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	cgrpc "github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	cssh "github.com/ImTheCurse/ConflowCI/pkg/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// ProvisionAll provisions the hosts of cfg concurrently, if names is empty every host that runs
+// a worker is provisioned - hosts using the ssh executor don't need one.
+// a result is returned for every host, in the order of names or of the config's hosts.
+func ProvisionAll(ctx context.Context, cfg config.ValidatedConfig, names []string, opts Options) ([]Result, error) {
+	if opts.WorkerBinary == "" {
+		return nil, ErrNoWorkerBinary
+	}
+	eps := []config.EndpointInfo{}
+	if len(names) == 0 {
+		for _, ep := range cfg.Endpoints {
+			if !ep.IsSSH() {
+				eps = append(eps, ep)
+			}
+		}
+	}
+	for _, name := range names {
+		i := indexEndpoint(cfg.Endpoints, name)
+		if i < 0 {
+			return nil, ErrUnknownHost{Host: name}
+		}
+		if cfg.Endpoints[i].IsSSH() {
+			return nil, ErrAgentlessHost{Host: name}
+		}
+		eps = append(eps, cfg.Endpoints[i])
+	}
+
+	results := make([]Result, len(eps))
+	var wg sync.WaitGroup
+	for i, ep := range eps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Result{Host: ep.Name, Err: ProvisionHost(ctx, ep, opts)}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func indexEndpoint(eps []config.EndpointInfo, name string) int {
+	for i, ep := range eps {
+		if ep.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ProvisionHost connects to the host of ep over ssh, uploads the worker binary and the TLS material,
+// (re)starts the worker and waits until its gRPC port answers.
+// existing files are replaced, so provisioning a host again upgrades its worker.
+func ProvisionHost(ctx context.Context, ep config.EndpointInfo, opts Options) error {
+	if ep.User == "" {
+		return ErrNoSSHAccess{Host: ep.Name}
+	}
+	conn, err := cssh.DialEndpoint(ep)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	home, err := cssh.Home(conn)
+	if err != nil {
+		return err
+	}
+	layout := newRemoteLayout(home)
+
+	logger.Printf("Uploading worker to %s:%s", ep.Name, layout.Binary)
+	if err := uploadFile(conn, opts.WorkerBinary, layout.Binary+".new", 0o755); err != nil {
+		return err
+	}
+	if err := run(conn, fmt.Sprintf("mv -f %s %s", cssh.Quote(layout.Binary+".new"), cssh.Quote(layout.Binary))); err != nil {
+		return err
+	}
+	uploads := []struct{ local, remote string }{
+		{opts.TLS.CA, layout.TLS.CA},
+		{opts.TLS.ServerCert, layout.TLS.ServerCert},
+		{opts.TLS.ServerKey, layout.TLS.ServerKey},
+		{opts.TLS.ClientCert, layout.TLS.ClientCert},
+		{opts.TLS.ClientKey, layout.TLS.ClientKey},
+	}
+	for _, u := range uploads {
+		if err := uploadFile(conn, u.local, u.remote, 0o600); err != nil {
+			return err
+		}
+	}
+
+	port := ep.Port
+	if port == 0 {
+		port = DefaultWorkerPort
+	}
+	cmd := workerCommand(layout, port, opts.Concurrency)
+	if opts.Systemd {
+		logger.Printf("Installing systemd unit %s on %s", ServiceName, ep.Name)
+		unit := systemdUnit(ep.User, layout, cmd)
+		if err := cssh.Upload(conn, strings.NewReader(unit), layout.Unit, 0o644); err != nil {
+			return err
+		}
+		err = run(conn, systemdScript(layout))
+	} else {
+		logger.Printf("Starting worker on %s under nohup", ep.Name)
+		err = run(conn, nohupScript(layout, cmd))
+	}
+	if err != nil {
+		return err
+	}
+
+	addr := fmt.Sprintf("%s:%d", ep.Host, port)
+	vctx, cancel := context.WithTimeout(ctx, opts.VerifyTimeout)
+	defer cancel()
+	if err := cgrpc.Ping(vctx, addr); err != nil {
+		return ErrWorkerUnreachable{Host: ep.Name, Addr: addr, Err: err}
+	}
+	logger.Printf("Worker on %s is reachable at %s", ep.Name, addr)
+	return nil
+}
+
+func newRemoteLayout(home string) remoteLayout {
+	dir := path.Join(home, RemoteDir)
+	tls := path.Join(dir, "tls")
+	return remoteLayout{
+		Home:   home,
+		Dir:    dir,
+		Binary: path.Join(dir, "bin", ServiceName),
+		TLS: TLSFiles{
+			CA:         path.Join(tls, "root_ca.crt"),
+			ServerCert: path.Join(tls, "server_cert.pem"),
+			ServerKey:  path.Join(tls, "server_key.pem"),
+			ClientCert: path.Join(tls, "client_cert.pem"),
+			ClientKey:  path.Join(tls, "client_key.pem"),
+		},
+		Log:  path.Join(dir, "worker.log"),
+		Pid:  path.Join(dir, "worker.pid"),
+		Unit: path.Join(dir, ServiceName+".service"),
+	}
+}
+
+// workerCommand is the command line that starts the worker with the uploaded TLS material.
+// the worker's default concurrency is used if concurrency is 0.
+func workerCommand(layout remoteLayout, port uint16, concurrency int) string {
+	args := []string{
+		layout.Binary,
+		"-port", strconv.Itoa(int(port)),
+		"-ca", layout.TLS.CA,
+		"-server-cert", layout.TLS.ServerCert,
+		"-server-key", layout.TLS.ServerKey,
+		"-client-cert", layout.TLS.ClientCert,
+		"-client-key", layout.TLS.ClientKey,
+	}
+	if concurrency > 0 {
+		args = append(args, "-concurrency", strconv.Itoa(concurrency))
+	}
+	for i, arg := range args {
+		args[i] = cssh.Quote(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+func systemdUnit(user string, layout remoteLayout, cmd string) string {
+	return fmt.Sprintf(`[Unit]
+Description=ConflowCI worker
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+User=%s
+WorkingDirectory=%s
+ExecStart=%s
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`, user, layout.Home, cmd)
+}
+
+// systemdScript installs the uploaded unit and restarts the worker, sudo is used
+// if the ssh user isn't root and must not prompt for a password.
+func systemdScript(layout remoteLayout) string {
+	return fmt.Sprintf(`set -e
+SUDO=$([ "$(id -u)" -eq 0 ] || echo "sudo -n")
+$SUDO install -m 644 %s /etc/systemd/system/%s.service
+$SUDO systemctl daemon-reload
+$SUDO systemctl enable %[2]s
+$SUDO systemctl restart %[2]s`, cssh.Quote(layout.Unit), ServiceName)
+}
+
+// nohupScript stops the worker started by a previous provisioning and starts it again in the background.
+func nohupScript(layout remoteLayout, cmd string) string {
+	pid := cssh.Quote(layout.Pid)
+	return fmt.Sprintf(`if [ -f %[1]s ]; then
+  old="$(cat %[1]s)"
+  kill "$old" 2>/dev/null || true
+  for i in $(seq 50); do kill -0 "$old" 2>/dev/null || break; sleep 0.1; done
+fi
+cd %[2]s
+nohup %[3]s > %[4]s 2>&1 < /dev/null &
+echo $! > %[1]s`, pid, cssh.Quote(layout.Home), cmd, cssh.Quote(layout.Log))
+}
+
+func uploadFile(conn *ssh.Client, local, remote string, mode os.FileMode) error {
+	f, err := os.Open(os.ExpandEnv(local))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cssh.Upload(conn, f, remote, mode)
+}
+
+func run(conn *ssh.Client, cmd string) error {
+	var out bytes.Buffer
+	if err := cssh.Run(conn, cmd, &out); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
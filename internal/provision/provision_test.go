@@ -0,0 +1,146 @@
+package provision
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func TestProvisionAllErrors(t *testing.T) {
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{},
+		Endpoints: []config.EndpointInfo{
+			{Name: "no-ssh", Host: "localhost", Port: 8918},
+			{Name: "agentless", Host: "localhost", Executor: config.SSHExecutor},
+		},
+	}
+	tests := []struct {
+		name        string
+		hosts       []string
+		opts        Options
+		expectedErr error
+		resultErr   error
+	}{
+		{name: "no worker binary", expectedErr: ErrNoWorkerBinary},
+		{name: "unknown host", hosts: []string{"missing"}, opts: Options{WorkerBinary: "worker"}, expectedErr: ErrUnknownHost{Host: "missing"}},
+		{name: "agentless host", hosts: []string{"agentless"}, opts: Options{WorkerBinary: "worker"}, expectedErr: ErrAgentlessHost{Host: "agentless"}},
+		{name: "host without ssh", opts: Options{WorkerBinary: "worker"}, resultErr: ErrNoSSHAccess{Host: "no-ssh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := ProvisionAll(context.Background(), cfg, tt.hosts, tt.opts)
+			if err != tt.expectedErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(results) != 1 || results[0].Host != "no-ssh" || results[0].Err != tt.resultErr {
+				t.Errorf("Expected result error %v, got %v", tt.resultErr, results)
+			}
+		})
+	}
+}
+
+// TestNohupScript runs the script that starts the worker on the local machine, with a fake worker.
+func TestNohupScript(t *testing.T) {
+	layout := newRemoteLayout(filepath.Join(t.TempDir(), "home dir"))
+	if err := os.MkdirAll(filepath.Dir(layout.Binary), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	worker := "#!/bin/sh\necho \"$@\"\nexec sleep 30\n"
+	if err := os.WriteFile(layout.Binary, []byte(worker), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := workerCommand(layout, 9000, 0)
+
+	start := func() int {
+		if out, err := exec.Command("bash", "-c", nohupScript(layout, cmd)).CombinedOutput(); err != nil {
+			t.Fatalf("Failed to run script: %v: %s", err, out)
+		}
+		b, err := os.ReadFile(layout.Pid)
+		if err != nil {
+			t.Fatalf("Expected a pid file: %v", err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			t.Fatalf("Invalid pid file: %v", err)
+		}
+		return pid
+	}
+	alive := func(pid int) bool {
+		return syscall.Kill(pid, 0) == nil
+	}
+
+	first := start()
+	defer syscall.Kill(first, syscall.SIGKILL)
+	if !alive(first) {
+		t.Fatalf("Expected the worker to run")
+	}
+	second := start()
+	defer syscall.Kill(second, syscall.SIGKILL)
+	if first == second || !alive(second) {
+		t.Errorf("Expected a new worker to run, got pids %d and %d", first, second)
+	}
+	// the first worker isn't our child, so it's reaped by init once killed.
+	deadline := time.Now().Add(5 * time.Second)
+	for alive(first) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if alive(first) {
+		t.Errorf("Expected the previous worker to be stopped")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	var out []byte
+	for len(out) == 0 && time.Now().Before(deadline) {
+		out, _ = os.ReadFile(layout.Log)
+		time.Sleep(50 * time.Millisecond)
+	}
+	expected := "-port 9000 -ca " + layout.TLS.CA
+	if !strings.HasPrefix(string(out), expected) {
+		t.Errorf("Expected the worker to start with %q, got %q", expected, out)
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	layout := newRemoteLayout("/home/ci")
+	unit := systemdUnit("ci", layout, workerCommand(layout, 8918, 0))
+	for _, line := range []string{
+		"User=ci",
+		"WorkingDirectory=/home/ci",
+		"ExecStart='/home/ci/conflowci/bin/conflow-worker' '-port' '8918'",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("Expected the unit to contain %q, got:\n%s", line, unit)
+		}
+	}
+}
+
+func TestWorkerCommandConcurrency(t *testing.T) {
+	layout := newRemoteLayout("/home/ci")
+	tests := []struct {
+		name        string
+		concurrency int
+		expected    bool
+	}{
+		{name: "worker default", concurrency: 0, expected: false},
+		{name: "explicit concurrency", concurrency: 8, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := workerCommand(layout, 8918, tt.concurrency)
+			if got := strings.Contains(cmd, "'-concurrency' '8'"); got != tt.expected {
+				t.Errorf("Expected concurrency flag %v, got command %q", tt.expected, cmd)
+			}
+		})
+	}
+}
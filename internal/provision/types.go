@@ -0,0 +1,53 @@
+package provision
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+var logger = log.New(os.Stdout, "[Provision]: ", log.Lshortfile|log.LstdFlags)
+
+// DefaultWorkerPort is the port the worker listens on if the host's address has no port.
+const DefaultWorkerPort = 8918
+
+// ServiceName is the name of the systemd unit of the worker.
+const ServiceName = "conflow-worker"
+
+// RemoteDir is the directory on the host the worker is installed to, relative to the ssh user's home.
+const RemoteDir = "conflowci"
+
+// TLSFiles are the local paths of the TLS material uploaded to the workers.
+// the worker serves gRPC with the server certificate, and connects to itself with the client certificate.
+type TLSFiles struct {
+	CA         string
+	ServerCert string
+	ServerKey  string
+	ClientCert string
+	ClientKey  string
+}
+
+type Options struct {
+	WorkerBinary  string // local path of the worker binary, built for the host's platform
+	TLS           TLSFiles
+	Systemd       bool          // install the worker as a systemd unit, otherwise it's started under nohup
+	VerifyTimeout time.Duration // how long to wait for the worker's gRPC port to answer
+	Concurrency   int           // number of commands the worker runs at once, 0 for the worker's default
+}
+
+// Result is the outcome of provisioning a single host.
+type Result struct {
+	Host string
+	Err  error
+}
+
+// remoteLayout holds the absolute paths of the worker's files on the host.
+type remoteLayout struct {
+	Home   string
+	Dir    string
+	Binary string
+	TLS    TLSFiles
+	Log    string
+	Pid    string
+	Unit   string
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +17,7 @@ import (
 	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
 	"github.com/google/uuid"
 	"github.com/pelletier/go-toml"
 	googleGrpc "google.golang.org/grpc"
@@ -34,6 +36,8 @@ func NewWorkerBuilder(cfg config.ValidatedConfig, remote, branch, branchRef stri
 		BranchName: branch,
 		Token:      cfg.GetToken(),
 		BranchRef:  branchRef,
+		Env:        cfg.BuildEnv(),
+		Timeout:    cfg.Pipeline.Build.GetTimeout(),
 	}
 }
 
@@ -43,75 +47,118 @@ func NewWorkerBuilderServer(client providerPB.RepositoryProviderClient) *WorkerB
 
 func (s *WorkerBuilderServer) BuildRepository(ctx context.Context, cfg *syncPB.WorkerConfig) (
 	*syncPB.WorkerBuildOutput, error) {
-	repoWithBranch := fmt.Sprintf("%s-%s", cfg.Req.Name, cfg.Req.BranchName)
-	path := filepath.Join("..", repoWithBranch)
+	return s.buildRepository(ctx, cfg, nil)
+}
+
+// StreamBuildRepository builds the repository, sending every line of the build output as
+// soon as it's written. the build output is sent last, with the build error if the build failed.
+func (s *WorkerBuilderServer) StreamBuildRepository(cfg *syncPB.WorkerConfig,
+	stream googleGrpc.ServerStreamingServer[syncPB.WorkerBuildLog]) error {
+	output, err := s.buildRepository(stream.Context(), cfg, func(line string) {
+		stream.Send(&syncPB.WorkerBuildLog{Line: line})
+	})
+	if err != nil {
+		logger.Printf("Build of %s failed: %v", cfg.Req.Name, err)
+	}
+	return stream.Send(&syncPB.WorkerBuildLog{Output: output})
+}
+
+// buildRepository syncs the repository, creates a work tree of the built branch and runs the
+// build steps in it, onLine is called with every line the build steps output and can be nil.
+func (s *WorkerBuilderServer) buildRepository(ctx context.Context, cfg *syncPB.WorkerConfig,
+	onLine process.LineFunc) (*syncPB.WorkerBuildOutput, error) {
 	dir := filepath.Join(os.ExpandEnv(BuildPath), cfg.Req.Name)
+	wrkTreeReq := workTreeRequest(cfg, dir)
 
-	err := s.syncRepository(cfg)
+	// concurrent builds of the repository share its git directory, so they're synced one at a time.
+	unlock := s.lockRepository(dir)
+	err := s.syncRepository(ctx, cfg)
 	if err != nil {
+		unlock()
 		e := fmt.Sprintf("Error syncing repository: %s", err.Error())
 		return &syncPB.WorkerBuildOutput{WorkerName: cfg.WorkerName, Error: &syncPB.WorkerBuildError{Error: e}}, err
 	}
-
-	branchName := strings.Split(cfg.Req.BranchRef, ":")[1]
-	wrkTreeReq := providerPB.WorkTreeRequest{
-		Name:            cfg.WorkerName,
-		RepoDir:         dir,
-		BranchName:      branchName,
-		WorktreeRelPath: "../" + cfg.Req.Name + "-" + cfg.Req.BranchName,
-	}
-	resp, err := s.provider.CreateWorkTree(ctx, &wrkTreeReq)
-	if resp.Error != nil || err != nil {
+	resp, err := s.provider.CreateWorkTree(ctx, wrkTreeReq)
+	unlock()
+	if err != nil || resp.Error != nil {
 		e := GetProtoWorkerError("Error creating work tree", err, resp)
 		return &syncPB.WorkerBuildOutput{WorkerName: cfg.WorkerName, Error: &syncPB.WorkerBuildError{Error: e}}, err
 	}
 
-	cdToWrkTree := "cd " + filepath.Join(dir, path)
+	cmd := buildCommand(filepath.Join(dir, wrkTreeReq.WorktreeRelPath), cfg.BuildSteps)
+	logger.Printf("Executing command: %s", cmd)
+
+	// the build is killed if the run is cancelled.
+	c := process.Command(ctx, "bash", "-c", cmd)
+	c.Env = process.Environ(cfg.Env)
+	out, err := process.Run(c, onLine)
+	if err != nil {
+		e := GetProtoWorkerError("Error running commands", err, resp)
+		return &syncPB.WorkerBuildOutput{
+			WorkerName: cfg.WorkerName, Output: out,
+			Error: &syncPB.WorkerBuildError{Error: e},
+		}, err
+	}
+	return &syncPB.WorkerBuildOutput{WorkerName: cfg.WorkerName, Output: out}, nil
+}
+
+// buildCommand returns a shell command that runs the build steps sequentially in dir,
+// and stops at the first step that fails.
+func buildCommand(dir string, buildSteps []string) string {
+	cdToWrkTree := "cd " + dir
 	// Trim and filter steps
 	var steps []string
-	for _, step := range cfg.BuildSteps {
+	for _, step := range buildSteps {
 		s := strings.TrimSpace(step)
 		if s != "" {
 			steps = append(steps, s)
 		}
 	}
+	if len(steps) == 0 {
+		return cdToWrkTree
+	}
+	return cdToWrkTree + " && " + strings.Join(steps, " && ")
+}
 
-	var cmd string
-	if len(steps) != 0 {
-		buildCmd := strings.Join(steps, " && ")
-		cmd = cdToWrkTree + " && " + buildCmd
-	} else {
-		cmd = cdToWrkTree
+// workTreeRequest returns the request creating or removing the work tree of the build in the repository
+// at dir. the work tree is named after the local branch the built ref is fetched into, which is unique to
+// the build, so builds of the same branch don't share a work tree.
+func workTreeRequest(cfg *syncPB.WorkerConfig, dir string) *providerPB.WorkTreeRequest {
+	branchName := localBranch(cfg.Req.BranchRef)
+	return &providerPB.WorkTreeRequest{
+		Name:            cfg.WorkerName,
+		RepoDir:         dir,
+		BranchName:      branchName,
+		WorktreeRelPath: "../" + branchName,
 	}
-	logger.Printf("Executing command: %s", cmd)
+}
 
-	c := exec.Command("bash", "-c", cmd)
-	b, err := c.CombinedOutput()
-	if err != nil {
-		e := GetProtoWorkerError("Error running commands", err, resp)
-		return &syncPB.WorkerBuildOutput{
-			WorkerName: cfg.WorkerName, Output: string(b),
-			Error: &syncPB.WorkerBuildError{Error: e},
-		}, err
+// localBranch returns the local branch the refspec ref fetches into.
+func localBranch(ref string) string {
+	if _, dst, ok := strings.Cut(ref, ":"); ok {
+		return dst
 	}
-	return &syncPB.WorkerBuildOutput{WorkerName: cfg.WorkerName, Output: string(b)}, nil
+	return ref
 }
 
+// lockRepository locks the repository at dir until the returned func is called.
+func (s *WorkerBuilderServer) lockRepository(dir string) func() {
+	mu, _ := s.repoLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// RemoveRepositoryWorkspace removes the work tree of the build and its local branch.
 func (s *WorkerBuilderServer) RemoveRepositoryWorkspace(ctx context.Context, cfg *syncPB.WorkerConfig) (*emptypb.Empty, error) {
 	dir := filepath.Join(os.ExpandEnv(BuildPath), cfg.Req.Name)
-	branchName := strings.Split(cfg.Req.BranchRef, ":")[1]
-	wrkTreeReq := providerPB.WorkTreeRequest{
-		Name:            cfg.WorkerName,
-		RepoDir:         dir,
-		BranchName:      branchName,
-		WorktreeRelPath: "../" + cfg.Req.Name + "-" + cfg.Req.BranchName,
-	}
-	resp, err := s.provider.RemoveWorkTree(ctx, &wrkTreeReq)
-	if err != nil {
+	unlock := s.lockRepository(dir)
+	defer unlock()
+	resp, err := s.provider.RemoveWorkTree(ctx, workTreeRequest(cfg, dir))
+	if err != nil || resp.Error != nil {
 		e := GetProtoWorkerError("Error removing work tree", err, resp)
 		return nil, errors.New(e)
 	}
-	return nil, nil
+	return &emptypb.Empty{}, nil
 }
 
 func (wb *WorkersBuilder) RemoveAllRepositoryWorkspaces() []error {
@@ -126,16 +173,20 @@ func (wb *WorkersBuilder) RemoveAllRepositoryWorkspaces() []error {
 		go func() {
 			defer logger.Println("wg done in @RemoveAllRepositoryWorkspaces")
 			defer wg.Done()
+			if ep.IsSSH() {
+				ConcurrentAppendToArray(&mu, wb.removeWorkspaceOverSSH(ep), &errs)
+				return
+			}
 			conn, err := grpc.CreateNewClientConnection(addr)
 			if err != nil {
 				e := GetProtoWorkerError("Error creating new client connection", err, nil)
 				ConcurrentAppendToArray(&mu, errors.New(e), &errs)
+				return
 			}
 			defer conn.Close()
 
-			workerCfg, s := wb.getWorkerConfig(conn, ep.Name, dir)
-			ctx := context.Background()
-			_, err = s.RemoveRepositoryWorkspace(ctx, workerCfg)
+			client := syncPB.NewWorkerBuilderClient(conn)
+			_, err = client.RemoveRepositoryWorkspace(context.Background(), wb.getWorkerConfig(ep.Name, dir))
 			ConcurrentAppendToArray(&mu, err, &errs)
 		}()
 	}
@@ -144,10 +195,7 @@ func (wb *WorkersBuilder) RemoveAllRepositoryWorkspaces() []error {
 
 }
 
-func (wb *WorkersBuilder) getWorkerConfig(conn *googleGrpc.ClientConn, workerName, dir string) (
-	*syncPB.WorkerConfig, WorkerBuilderServer) {
-	providerClient := providerPB.NewRepositoryProviderClient(conn)
-	s := WorkerBuilderServer{provider: providerClient}
+func (wb *WorkersBuilder) getWorkerConfig(workerName, dir string) *syncPB.WorkerConfig {
 	workerCfg := syncPB.WorkerConfig{
 		WorkerName: workerName,
 		Req: &providerPB.SyncRequest{
@@ -157,11 +205,24 @@ func (wb *WorkersBuilder) getWorkerConfig(conn *googleGrpc.ClientConn, workerNam
 			BranchName:   wb.BranchName,
 			RemoteOrigin: wb.Remote,
 			Token:        wb.Token,
-			BranchRef:    wb.BranchRef,
+			BranchRef:    wb.fetchRef(),
 		},
 		BuildSteps: wb.Steps,
+		Env:        wb.Env,
 	}
-	return &workerCfg, s
+	return &workerCfg
+}
+
+// Workspace returns the name of the build's work tree, which is also the name of the local branch the
+// built ref is fetched into. runs of the same branch can build concurrently, so both are named after the build.
+func (wb *WorkersBuilder) Workspace() string {
+	return wb.Name + "-" + wb.BuildID.String()
+}
+
+// fetchRef returns the refspec fetching the built ref into the build's local branch.
+func (wb *WorkersBuilder) fetchRef() string {
+	src, _, _ := strings.Cut(wb.BranchRef, ":")
+	return src + ":" + wb.Workspace()
 }
 
 func formatAddress(ep config.EndpointInfo) string {
@@ -171,7 +232,11 @@ func formatAddress(ep config.EndpointInfo) string {
 	return fmt.Sprintf("%s:%d", ep.Host, ep.Port)
 }
 
-func (wb *WorkersBuilder) BuildAllEndpoints() []*syncPB.WorkerBuildOutput {
+// BuildAllEndpoints syncs and builds the repository on all endpoints concurrently,
+// cancelling ctx cancels the builds that are still running, a build exceeding wb.Timeout is killed.
+// the build output is streamed from the workers, and passed line by line to wb.OnLine.
+// endpoints using the ssh executor are built over ssh sessions.
+func (wb *WorkersBuilder) BuildAllEndpoints(ctx context.Context) []*syncPB.WorkerBuildOutput {
 	outputs := []*syncPB.WorkerBuildOutput{}
 	dir := filepath.Join(os.ExpandEnv(BuildPath), wb.Name)
 
@@ -184,6 +249,12 @@ func (wb *WorkersBuilder) BuildAllEndpoints() []*syncPB.WorkerBuildOutput {
 		go func() {
 			defer logger.Println("wg done in @BuildAllEndpoints")
 			defer wg.Done()
+			ctx, cancel := process.WithTimeout(ctx, wb.Timeout)
+			defer cancel()
+			if ep.IsSSH() {
+				ConcurrentAppendToArray(&mu, wb.checkTimedOut(ctx, wb.buildOverSSH(ctx, ep)), &outputs)
+				return
+			}
 			conn, err := grpc.CreateNewClientConnection(addr)
 			if err != nil {
 				e := GetProtoWorkerError("Error creating new client connection", err, nil)
@@ -192,30 +263,60 @@ func (wb *WorkersBuilder) BuildAllEndpoints() []*syncPB.WorkerBuildOutput {
 					&syncPB.WorkerBuildOutput{WorkerName: ep.Name, Error: &syncPB.WorkerBuildError{Error: e}},
 					&outputs,
 				)
+				return
 			}
 			defer conn.Close()
 
-			workerCfg, s := wb.getWorkerConfig(conn, ep.Name, dir)
-			ctx := context.Background()
-			output, err := s.BuildRepository(ctx, workerCfg)
+			output, err := wb.streamBuild(ctx, syncPB.NewWorkerBuilderClient(conn), ep.Name, dir)
 			if err != nil {
 				e := GetProtoWorkerError("Error Building repository", err, nil)
-				ConcurrentAppendToArray(
-					&mu,
-					&syncPB.WorkerBuildOutput{WorkerName: ep.Name, Error: &syncPB.WorkerBuildError{Error: e}},
-					&outputs,
-				)
+				output = &syncPB.WorkerBuildOutput{WorkerName: ep.Name, Error: &syncPB.WorkerBuildError{Error: e}}
 			}
-			ConcurrentAppendToArray(&mu, output, &outputs)
+			ConcurrentAppendToArray(&mu, wb.checkTimedOut(ctx, output), &outputs)
 		}()
 	}
 	wg.Wait()
 	return outputs
 }
 
+// checkTimedOut marks output as timed out if the build's ctx exceeded wb.Timeout, the build was killed
+// so its error is replaced with the timeout.
+func (wb *WorkersBuilder) checkTimedOut(ctx context.Context, output *syncPB.WorkerBuildOutput) *syncPB.WorkerBuildOutput {
+	if ctx.Err() != context.DeadlineExceeded {
+		return output
+	}
+	logger.Printf("Build on %s timed out after %s", output.WorkerName, wb.Timeout)
+	output.TimedOut = true
+	output.Error = &syncPB.WorkerBuildError{Error: fmt.Sprintf("Build timed out after %s", wb.Timeout)}
+	return output
+}
+
+// streamBuild builds the repository on a single worker, and returns the build output once the build finished.
+func (wb *WorkersBuilder) streamBuild(ctx context.Context, client syncPB.WorkerBuilderClient, workerName, dir string) (
+	*syncPB.WorkerBuildOutput, error) {
+	stream, err := client.StreamBuildRepository(ctx, wb.getWorkerConfig(workerName, dir))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("build stream of %s ended without a build output", workerName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.Output != nil {
+			return msg.Output, nil
+		}
+		if wb.OnLine != nil {
+			wb.OnLine(workerName, msg.Line)
+		}
+	}
+}
+
 // SyncRepository syncs the repository to the latest commit of specified branch.
-func (s *WorkerBuilderServer) syncRepository(cfg *syncPB.WorkerConfig) error {
-	ctx := context.Background()
+func (s *WorkerBuilderServer) syncRepository(ctx context.Context, cfg *syncPB.WorkerConfig) error {
 	path := filepath.Join(os.ExpandEnv(BuildPath), cfg.Req.Name)
 	logger.Printf("Syncing repository %s", path)
 	isMetadataExist := s.checkMetadatFileExist(cfg.Req.Name)
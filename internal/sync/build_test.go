@@ -2,6 +2,7 @@ package sync
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pelletier/go-toml"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func RunGRPCBuilderServer(t *testing.T, portCh chan<- int) {
@@ -34,6 +37,12 @@ func RunGRPCBuilderServer(t *testing.T, portCh chan<- int) {
 
 	logger.Printf("Registering services...")
 	providerPB.RegisterRepositoryProviderServer(server, &git.GitRepoReader{})
+	// the builder uses the provider service of the same server, like a worker does.
+	conn, err := cgrpc.CreateNewClientConnection(lis.Addr().String())
+	if err != nil {
+		logger.Fatalf("Failed to create client connection: %v", err)
+	}
+	syncPB.RegisterWorkerBuilderServer(server, NewWorkerBuilderServer(providerPB.NewRepositoryProviderClient(conn)))
 
 	logger.Printf("gRPC server Listening on port %d", port)
 	if err := server.Serve(lis); err != nil {
@@ -136,7 +145,7 @@ func TestBuildRepository(t *testing.T) {
 		Remote:     "origin",
 		BranchName: "another-change",
 	}
-	outputs := wb.BuildAllEndpoints()
+	outputs := wb.BuildAllEndpoints(context.Background())
 	fmt.Printf("outputs: %v", outputs)
 	if len(outputs) != 1 {
 		t.Errorf("Expected 1 output, got %d", len(outputs))
@@ -153,3 +162,126 @@ func TestBuildRepository(t *testing.T) {
 		t.Errorf("Error: expected: %s, got: %s", expectedOut, outputs[0].Output)
 	}
 }
+
+// TestConcurrentBuilds builds the same branch twice at once on a worker, each build gets its own work tree.
+func TestConcurrentBuilds(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = origin
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=ci", "GIT_AUTHOR_EMAIL=ci@example.com",
+			"GIT_COMMITTER_NAME=ci", "GIT_COMMITTER_EMAIL=ci@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(origin, "hello.txt"), []byte("hello from main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "hello.txt")
+	git("commit", "-m", "initial")
+
+	BuildPath = t.TempDir()
+	cgrpc.DefineFlags()
+	*cgrpc.TlsFlag = false
+	flag.Parse()
+	ch := make(chan int)
+	go RunGRPCBuilderServer(t, ch)
+	ep := config.EndpointInfo{Name: "test", Host: "localhost", Port: uint16(<-ch)}
+
+	builders := make([]*WorkersBuilder, 2)
+	outputs := make([][]*syncPB.WorkerBuildOutput, len(builders))
+	var wg sync.WaitGroup
+	for i := range builders {
+		builders[i] = &WorkersBuilder{
+			Name:       "demo",
+			BuildID:    uuid.New(),
+			CloneURL:   "file://" + origin,
+			RunsOn:     []config.EndpointInfo{ep},
+			Steps:      []string{"cat hello.txt"},
+			BranchRef:  "main:ci-main",
+			Remote:     "origin",
+			BranchName: "main",
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i] = builders[i].BuildAllEndpoints(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i, wb := range builders {
+		if len(outputs[i]) != 1 || outputs[i][0].Error != nil {
+			t.Fatalf("Expected build %d to succeed, got %v", i, outputs[i])
+		}
+		if _, err := os.Stat(filepath.Join(BuildPath, wb.Workspace(), "hello.txt")); err != nil {
+			t.Errorf("Expected a work tree of build %d: %v", i, err)
+		}
+	}
+	for i, wb := range builders {
+		if errs := wb.RemoveAllRepositoryWorkspaces(); len(errs) != 1 || errs[0] != nil {
+			t.Errorf("Expected the work tree of build %d to be removed, got %v", i, errs)
+		}
+		if _, err := os.Stat(filepath.Join(BuildPath, wb.Workspace())); !os.IsNotExist(err) {
+			t.Errorf("Expected the work tree of build %d to be removed: %v", i, err)
+		}
+	}
+}
+
+// fakeStreamBuilder streams fixed build lines followed by the build output.
+type fakeStreamBuilder struct {
+	syncPB.UnimplementedWorkerBuilderServer
+	lines []string
+}
+
+func (f *fakeStreamBuilder) StreamBuildRepository(cfg *syncPB.WorkerConfig,
+	stream grpc.ServerStreamingServer[syncPB.WorkerBuildLog]) error {
+	for _, line := range f.lines {
+		stream.Send(&syncPB.WorkerBuildLog{Line: line})
+	}
+	out := strings.Join(f.lines, "\n")
+	return stream.Send(&syncPB.WorkerBuildLog{Output: &syncPB.WorkerBuildOutput{WorkerName: cfg.WorkerName, Output: out}})
+}
+
+func TestStreamBuild(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	fake := &fakeStreamBuilder{lines: []string{"step 1", "step 2"}}
+	syncPB.RegisterWorkerBuilderServer(server, fake)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+
+	var lines []string
+	wb := &WorkersBuilder{
+		Name:      "demo-repo",
+		BranchRef: "pull/6/head:pr-6",
+		OnLine: func(worker, line string) {
+			lines = append(lines, worker+": "+line)
+		},
+	}
+	output, err := wb.streamBuild(context.Background(), syncPB.NewWorkerBuilderClient(conn), "worker-1", t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.WorkerName != "worker-1" || output.Output != "step 1\nstep 2" {
+		t.Errorf("Unexpected build output: %v", output)
+	}
+	expected := []string{"worker-1: step 1", "worker-1: step 2"}
+	if strings.Join(lines, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected lines %v, got %v", expected, lines)
+	}
+}
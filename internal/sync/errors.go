@@ -19,3 +19,11 @@ type MetadataEncodeError struct {
 func (e MetadataEncodeError) Error() string {
 	return fmt.Sprintf("metadata encode error: %s", e.message)
 }
+
+type NoEndpointError struct {
+	task string
+}
+
+func (e NoEndpointError) Error() string {
+	return fmt.Sprintf("no endpoint error: none of the endpoints task %s runs on are usable", e.task)
+}
@@ -16,27 +16,39 @@ import (
 // Creates a new task executor, the task executor is responsible for executing tasks on a remote machine
 // it dispatches each cmd with file/pattern to a remote machine in a concurrent way using the RunTaskOnAllMachines func.
 // there is no guarantee that the commands will be executed in the order they were dispatched.
-func NewTaskExecutor(cfg config.ValidatedConfig, task config.TaskConsumerJobs, wsName string) (*TaskExecutor, error) {
-	ctx := context.Background()
+// files and patterns are resolved in the run's work tree wsName, under BuildPath.
+func NewTaskExecutor(ctx context.Context, cfg config.ValidatedConfig, task config.TaskConsumerJobs, wsName string) (
+	*TaskExecutor, error) {
+	runsOn := getTasksMachine(cfg, task)
+	if len(runsOn) == 0 {
+		return nil, NoEndpointError{task: task.Name}
+	}
 	files := []string{}
 	var err error
 	if task.File == nil {
-		finder := pb.TaskFileFinder{
-			Pattern:  task.Pattern,
-			BuildDir: BuildPath,
-		}
-		endpoint := cfg.Endpoints[0]
-		conn, err := grpc.CreateNewClientConnection(endpoint.GetEndpointURL())
-		if err != nil {
-			return nil, err
-		}
-		client := pb.NewFileExtractorClient(conn)
-
-		f, err := client.GetFilesByRegex(ctx, &finder)
-		if err != nil {
-			return nil, err
+		endpoint := runsOn[0]
+		if endpoint.IsSSH() {
+			files, err = findFilesOverSSH(endpoint, task.Pattern, wsName)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			conn, err := grpc.CreateNewClientConnection(endpoint.GetEndpointURL())
+			if err != nil {
+				return nil, err
+			}
+			defer conn.Close()
+			client := pb.NewFileExtractorClient(conn)
+			finder := pb.TaskFileFinder{
+				Pattern:  task.Pattern,
+				BuildDir: filepath.Join(BuildPath, wsName),
+			}
+			f, err := client.GetFilesByRegex(ctx, &finder)
+			if err != nil {
+				return nil, err
+			}
+			files = f.Files
 		}
-		files = f.Files
 	} else {
 		for _, file := range task.File {
 			filesWithPath := filepath.Join(BuildPath, wsName, file)
@@ -44,27 +56,62 @@ func NewTaskExecutor(cfg config.ValidatedConfig, task config.TaskConsumerJobs, w
 			files = append(files, filesWithPath)
 		}
 	}
-	cmds := []string{}
-	for _, cmd := range task.Commands {
-		for _, file := range files {
-			expandedCmd := strings.ReplaceAll(cmd, "{file}", file)
-			cmds = append(cmds, expandedCmd)
-		}
-	}
+	cmds := expandCommands(task.Commands, files)
 	logger.Println("Added task commands.")
 	logger.Println("Create TaskExecutor.")
 	return &TaskExecutor{
 		TaskID:  uuid.New(),
+		Name:    task.Name,
 		State:   StartingTask,
-		RunsOn:  getTasksMachine(cfg, task),
+		RunsOn:  runsOn,
 		Files:   files,
 		Cmds:    cmds,
 		Outputs: []string{},
 		Errors:  []string{},
+		Env:     cfg.TaskEnv(task),
+
+		CommandTimeout: task.GetCommandTimeout(),
+		Durable:        cfg.DurableQueues(),
 	}, err
 
 }
 
+// NewResumedTaskExecutor creates a task executor of a task whose commands were already published to the
+// durable message queue, with the task ID and the progress of its commands. the task is resumed with
+// ResumeTaskOnAllMachines.
+func NewResumedTaskExecutor(cfg config.ValidatedConfig, task config.TaskConsumerJobs, taskID uuid.UUID,
+	progress []CommandProgress) *TaskExecutor {
+	cmds := make([]string, len(progress))
+	for i, p := range progress {
+		cmds[i] = p.Command
+	}
+	return &TaskExecutor{
+		TaskID:  taskID,
+		Name:    task.Name,
+		State:   StartingTask,
+		RunsOn:  getTasksMachine(cfg, task),
+		Cmds:    cmds,
+		Outputs: []string{},
+		Errors:  []string{},
+		Env:     cfg.TaskEnv(task),
+
+		CommandTimeout: task.GetCommandTimeout(),
+		Durable:        true,
+	}
+}
+
+// expandCommands expands every command once for each file, replacing {file} with the file's path.
+func expandCommands(commands, files []string) []string {
+	cmds := []string{}
+	for _, cmd := range commands {
+		for _, file := range files {
+			expandedCmd := strings.ReplaceAll(cmd, "{file}", file)
+			cmds = append(cmds, expandedCmd)
+		}
+	}
+	return cmds
+}
+
 // getTasksMachine returns the list of endpoints that the task should be executed on.
 func getTasksMachine(cfg config.ValidatedConfig, task config.TaskConsumerJobs) []config.EndpointInfo {
 	res := []config.EndpointInfo{}
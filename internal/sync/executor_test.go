@@ -2,6 +2,7 @@ package sync
 
 import (
 	"context"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,6 +12,8 @@ import (
 
 	"github.com/ImTheCurse/ConflowCI/internal/sync/pb"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	cgrpc "github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"google.golang.org/grpc"
 )
 
 func TestGetFilesByRegex(t *testing.T) {
@@ -155,3 +158,68 @@ func TestGetTasksMachine(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTaskExecutorWithoutEndpoints(t *testing.T) {
+	cfg := config.ValidatedConfig{
+		Endpoints: []config.EndpointInfo{{Name: "test-node-1", Host: "localhost", Port: 8871}},
+	}
+	tests := []struct {
+		name string
+		task config.TaskConsumerJobs
+	}{
+		{
+			name: "pattern",
+			task: config.TaskConsumerJobs{Name: "test-task", Pattern: ".+_test.go", RunsOn: []string{"removed-node"}},
+		},
+		{
+			name: "files",
+			task: config.TaskConsumerJobs{Name: "test-task", File: []string{"a.sh"}, RunsOn: []string{"removed-node"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTaskExecutor(context.Background(), cfg, tt.task, "")
+			if _, ok := err.(NoEndpointError); !ok {
+				t.Errorf("Expected a NoEndpointError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewTaskExecutorFindsFilesInWorkspace(t *testing.T) {
+	cgrpc.DefineFlags()
+	*cgrpc.TlsFlag = false
+	BuildPath = t.TempDir()
+	for _, ws := range []string{"demo-a", "demo-b"} {
+		if err := os.MkdirAll(filepath.Join(BuildPath, ws), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(BuildPath, ws, "pkg_test.go"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterFileExtractorServer(server, &TaskExecutorServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	cfg := config.ValidatedConfig{
+		Config:    &config.Config{},
+		Endpoints: []config.EndpointInfo{{Name: "test-node-1", Host: "127.0.0.1", Port: uint16(lis.Addr().(*net.TCPAddr).Port)}},
+	}
+	task := config.TaskConsumerJobs{Name: "test-task", Pattern: ".+_test.go", Commands: []string{"go test {file}"},
+		RunsOn: []string{"test-node-1"}}
+	te, err := NewTaskExecutor(context.Background(), cfg, task, "demo-a")
+	if err != nil {
+		t.Fatalf("Failed to create task executor: %v", err)
+	}
+	expected := []string{filepath.Join(BuildPath, "demo-a", "pkg_test.go")}
+	if !reflect.DeepEqual(te.Files, expected) {
+		t.Errorf("Expected files: %v got: %v", expected, te.Files)
+	}
+}
@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
+)
+
+// installMu serializes the installs of a worker, runs that start at the same time
+// don't run the install steps twice.
+var installMu sync.Mutex
+
+// InstallHost runs the install steps of the host once, the fingerprint of the steps is saved
+// after they complete successfully and later installs with the same fingerprint are skipped.
+// failed installs aren't saved, so they rerun on the next install.
+func (s *WorkerBuilderServer) InstallHost(ctx context.Context, req *syncPB.InstallRequest) (*syncPB.InstallOutput, error) {
+	installMu.Lock()
+	defer installMu.Unlock()
+	res := &syncPB.InstallOutput{WorkerName: req.WorkerName, Fingerprint: req.Fingerprint}
+
+	path := os.ExpandEnv(InstallFingerprintPath)
+	if b, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(b)) == req.Fingerprint {
+		logger.Printf("Install steps with fingerprint %s already completed, skipping.", req.Fingerprint)
+		res.Skipped = true
+		return res, nil
+	}
+
+	cmd := strings.Join(req.Steps, " && ")
+	logger.Printf("Executing install steps: %s", cmd)
+	c := process.Command(ctx, "bash", "-c", cmd)
+	c.Env = process.Environ(req.Env)
+	out, err := process.Run(c, nil)
+	res.Output = out
+	if err != nil {
+		res.Error = fmt.Sprintf("Error running install steps: %v", err)
+		return res, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		res.Error = fmt.Sprintf("Error saving install fingerprint: %v", err)
+		return res, nil
+	}
+	if err := os.WriteFile(path, []byte(req.Fingerprint+"\n"), 0o644); err != nil {
+		res.Error = fmt.Sprintf("Error saving install fingerprint: %v", err)
+	}
+	return res, nil
+}
+
+// InstallAllEndpoints runs the install steps of every host that has them concurrently,
+// it returns the install output of each of these hosts.
+func InstallAllEndpoints(ctx context.Context, cfg config.ValidatedConfig) []*syncPB.InstallOutput {
+	outputs := []*syncPB.InstallOutput{}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, host := range cfg.Hosts {
+		steps := host.GetInstallSteps()
+		if len(steps) == 0 {
+			continue
+		}
+		i := slices.IndexFunc(cfg.Endpoints, func(ep config.EndpointInfo) bool { return ep.Name == host.Name })
+		if i < 0 {
+			continue
+		}
+		req := &syncPB.InstallRequest{
+			WorkerName:  host.Name,
+			Steps:       steps,
+			Fingerprint: host.InstallFingerprint(),
+			Env:         cfg.GetGlobalEnv(),
+		}
+		ep := cfg.Endpoints[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var output *syncPB.InstallOutput
+			var err error
+			if ep.IsSSH() {
+				output, err = installOverSSH(ctx, ep, req)
+			} else {
+				output, err = installHost(ctx, formatAddress(ep), req)
+			}
+			if err != nil {
+				output = &syncPB.InstallOutput{
+					WorkerName:  req.WorkerName,
+					Fingerprint: req.Fingerprint,
+					Error:       fmt.Sprintf("Error installing host: %v", err),
+				}
+			}
+			ConcurrentAppendToArray(&mu, output, &outputs)
+		}()
+	}
+	wg.Wait()
+	return outputs
+}
+
+func installHost(ctx context.Context, addr string, req *syncPB.InstallRequest) (*syncPB.InstallOutput, error) {
+	conn, err := grpc.CreateNewClientConnection(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	output, err := syncPB.NewWorkerBuilderClient(conn).InstallHost(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return nil, errors.New("empty install output")
+	}
+	return output, nil
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	cgrpc "github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"google.golang.org/grpc"
+)
+
+func TestInstallHost(t *testing.T) {
+	dir := t.TempDir()
+	InstallFingerprintPath = filepath.Join(dir, "conflowci", "install.fingerprint")
+	marker := filepath.Join(dir, "runs")
+	s := &WorkerBuilderServer{}
+
+	tests := []struct {
+		name            string
+		steps           []string
+		fingerprint     string
+		expectedSkipped bool
+		expectedError   bool
+		expectedRuns    int // times the steps ran so far
+	}{
+		{name: "first install", steps: []string{"echo run >> " + marker}, fingerprint: "a", expectedRuns: 1},
+		{name: "same fingerprint is skipped", steps: []string{"echo run >> " + marker}, fingerprint: "a", expectedSkipped: true, expectedRuns: 1},
+		{name: "changed fingerprint reruns", steps: []string{"echo run >> " + marker}, fingerprint: "b", expectedRuns: 2},
+		{name: "failed install", steps: []string{"echo run >> " + marker, "false"}, fingerprint: "c", expectedError: true, expectedRuns: 3},
+		{name: "failed install reruns", steps: []string{"echo run >> " + marker}, fingerprint: "c", expectedRuns: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.InstallHost(context.Background(), &syncPB.InstallRequest{
+				WorkerName: "worker-1", Steps: tt.steps, Fingerprint: tt.fingerprint,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if res.Skipped != tt.expectedSkipped || (res.Error != "") != tt.expectedError {
+				t.Errorf("Expected skipped %v and error %v, got %+v", tt.expectedSkipped, tt.expectedError, res)
+			}
+			b, _ := os.ReadFile(marker)
+			if runs := strings.Count(string(b), "run"); runs != tt.expectedRuns {
+				t.Errorf("Expected the steps to run %d times, got %d", tt.expectedRuns, runs)
+			}
+		})
+	}
+}
+
+func TestInstallAllEndpoints(t *testing.T) {
+	cgrpc.DefineFlags()
+	*cgrpc.TlsFlag = false
+	InstallFingerprintPath = filepath.Join(t.TempDir(), "install.fingerprint")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	syncPB.RegisterWorkerBuilderServer(server, &WorkerBuilderServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+	ok := []string{"echo installing $GREETING"}
+	cfg := config.ValidatedConfig{
+		Config: &config.Config{
+			Env: &config.Environment{GlobalEnv: map[string]string{"GREETING": "hello"}},
+			Hosts: []config.Host{
+				{Name: "installed", InstallSteps: &ok},
+				{Name: "no-steps"},
+				{Name: "unreachable", InstallSteps: &ok},
+			},
+		},
+		Endpoints: []config.EndpointInfo{
+			{Name: "installed", Host: "127.0.0.1", Port: port},
+			{Name: "no-steps", Host: "127.0.0.1", Port: port},
+			{Name: "unreachable", Host: "127.0.0.1", Port: 1},
+		},
+	}
+	outputs := InstallAllEndpoints(context.Background(), cfg)
+	slices.SortFunc(outputs, func(a, b *syncPB.InstallOutput) int { return strings.Compare(a.WorkerName, b.WorkerName) })
+	if len(outputs) != 2 {
+		t.Fatalf("Expected the hosts with install steps to install, got %v", outputs)
+	}
+	if outputs[0].WorkerName != "installed" || outputs[0].Error != "" || outputs[0].Output != "installing hello\n" {
+		t.Errorf("Unexpected install output: %+v", outputs[0])
+	}
+	if outputs[0].Fingerprint != cfg.Hosts[0].InstallFingerprint() {
+		t.Errorf("Expected fingerprint %s, got %s", cfg.Hosts[0].InstallFingerprint(), outputs[0].Fingerprint)
+	}
+	if outputs[1].WorkerName != "unreachable" || outputs[1].Error == "" {
+		t.Errorf("Expected the unreachable host to fail installing, got %+v", outputs[1])
+	}
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/internal/mq"
+	pb "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
+	"github.com/google/uuid"
+)
+
+// LocalWorkerName is the worker name of the output of builds and tasks executed on the local machine.
+const LocalWorkerName = "local"
+
+// BuildLocal runs the build steps in dir on the local machine, the repository isn't synced
+// and no work tree is created - dir is built as it is.
+// cancelling ctx or exceeding wb.Timeout kills the build, the build output is passed line by line to wb.OnLine.
+func (wb *WorkersBuilder) BuildLocal(ctx context.Context, dir string) *pb.WorkerBuildOutput {
+	wb.State = RunningBuild
+	ctx, cancel := process.WithTimeout(ctx, wb.Timeout)
+	defer cancel()
+	cmd := buildCommand(dir, wb.Steps)
+	logger.Printf("Executing command locally: %s", cmd)
+
+	c := process.Command(ctx, "bash", "-c", cmd)
+	c.Env = process.Environ(wb.Env)
+	out, err := process.Run(c, func(line string) {
+		if wb.OnLine != nil {
+			wb.OnLine(LocalWorkerName, line)
+		}
+	})
+	if err != nil {
+		wb.State = ErrorInBuild
+		e := GetProtoWorkerError("Error running commands", err, nil)
+		return wb.checkTimedOut(ctx, &pb.WorkerBuildOutput{
+			WorkerName: LocalWorkerName, Output: out,
+			Error: &pb.WorkerBuildError{Error: e},
+		})
+	}
+	wb.State = CompletedBuild
+	return &pb.WorkerBuildOutput{WorkerName: LocalWorkerName, Output: out}
+}
+
+// NewLocalTaskExecutor creates a task executor that runs the task's commands in dir on the local machine.
+// files are resolved like NewTaskExecutor does on the workers, with dir as the build directory.
+func NewLocalTaskExecutor(ctx context.Context, cfg config.ValidatedConfig, task config.TaskConsumerJobs, dir string) (
+	*TaskExecutor, error) {
+	files := []string{}
+	if task.File == nil {
+		finder := pb.TaskFileFinder{
+			Pattern:  task.Pattern,
+			BuildDir: dir,
+		}
+		f, err := (&TaskExecutorServer{}).GetFilesByRegex(ctx, &finder)
+		if err != nil {
+			return nil, err
+		}
+		files = f.Files
+	} else {
+		for _, file := range task.File {
+			files = append(files, filepath.Join(dir, file))
+		}
+	}
+	return &TaskExecutor{
+		TaskID:  uuid.New(),
+		Name:    task.Name,
+		State:   StartingTask,
+		RunsOn:  []config.EndpointInfo{{Name: LocalWorkerName, Host: "localhost"}},
+		Files:   files,
+		Cmds:    expandCommands(task.Commands, files),
+		Outputs: []string{},
+		Errors:  []string{},
+		Env:     cfg.TaskEnv(task),
+
+		CommandTimeout: task.GetCommandTimeout(),
+	}, nil
+}
+
+// RunTaskLocally runs the task's commands one after the other in dir, like a single worker would.
+// cancelling ctx kills the running command and the remaining commands aren't run.
+// a command exceeding te.CommandTimeout is killed and the task times out, the remaining commands still run.
+func (te *TaskExecutor) RunTaskLocally(ctx context.Context, dir string) error {
+	te.State = RunningTask
+	logger.Printf("%s locally: with id: %s", te.State.String(), te.TaskID)
+
+	results := make([]CommandResult, 0, len(te.Cmds))
+	for _, cmd := range te.Cmds {
+		if ctx.Err() != nil {
+			return te.cancelled(ctx)
+		}
+		res := CommandResult{ID: uuid.NewString(), Command: cmd, Host: LocalWorkerName, StartedAt: time.Now()}
+		cmdCtx, cancel := process.WithTimeout(ctx, te.CommandTimeout)
+		out, err := mq.RunCommand(cmdCtx, dir, []byte(cmd), te.Env, func(line string) {
+			if te.OnLine != nil {
+				te.OnLine(LocalWorkerName, line)
+			}
+		})
+		cmdErr := cmdCtx.Err()
+		cancel()
+		if ctx.Err() != nil {
+			return te.cancelled(ctx)
+		}
+		res.FinishedAt = time.Now()
+		res.Output = out
+		res.ExitCode = process.ExitCode(err)
+		if cmdErr == context.DeadlineExceeded {
+			logger.Printf("Command timed out after %s: %s", te.CommandTimeout, cmd)
+			res.Output += mq.TimedOutOutput(te.CommandTimeout)
+			res.TimedOut = true
+			err = mq.ErrCommandTimedOut{Timeout: te.CommandTimeout}
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+
+	te.setResults(results)
+	logger.Printf("%s: with id: %s", te.State.String(), te.TaskID)
+	return nil
+}
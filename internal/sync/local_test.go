@@ -0,0 +1,171 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+)
+
+func TestBuildLocal(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []string
+		timeout       time.Duration
+		expectedState BuildState
+		expectedLines []string
+	}{
+		{name: "successful build", steps: []string{"echo one", " ", "echo two"}, expectedState: CompletedBuild, expectedLines: []string{"one", "two"}},
+		{name: "failed step stops the build", steps: []string{"echo one", "false", "echo two"}, expectedState: ErrorInBuild, expectedLines: []string{"one"}},
+		{name: "timed out build", steps: []string{"echo one", "sleep 10"}, timeout: 200 * time.Millisecond, expectedState: ErrorInBuild, expectedLines: []string{"one"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []string{}
+			wb := &WorkersBuilder{Steps: tt.steps, Timeout: tt.timeout, OnLine: func(worker, line string) {
+				if worker != LocalWorkerName {
+					t.Errorf("Expected worker %s, got %s", LocalWorkerName, worker)
+				}
+				lines = append(lines, line)
+			}}
+			out := wb.BuildLocal(context.Background(), t.TempDir())
+			if wb.State != tt.expectedState {
+				t.Errorf("Expected state %s, got %s", tt.expectedState, wb.State)
+			}
+			if (out.Error != nil) != (tt.expectedState == ErrorInBuild) {
+				t.Errorf("Unexpected build error: %v", out.Error)
+			}
+			if out.TimedOut != (tt.timeout > 0) {
+				t.Errorf("Expected timed out to be %v, got %v", tt.timeout > 0, out.TimedOut)
+			}
+			if !reflect.DeepEqual(lines, tt.expectedLines) {
+				t.Errorf("Expected lines %v, got %v", tt.expectedLines, lines)
+			}
+		})
+	}
+}
+
+func TestRunTaskLocally(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a_test.sh", "b_test.sh", "other.sh"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("echo "+name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		task            config.TaskConsumerJobs
+		expectedCmds    []string
+		expectedState   TaskState
+		expectedOutputs int
+		expectedErrors  int
+	}{
+		{
+			name:            "pattern",
+			task:            config.TaskConsumerJobs{Pattern: ".+_test.sh", Commands: []string{"sh {file}"}},
+			expectedCmds:    []string{"sh " + filepath.Join(dir, "a_test.sh"), "sh " + filepath.Join(dir, "b_test.sh")},
+			expectedState:   CompletedTask,
+			expectedOutputs: 2,
+		},
+		{
+			name:            "files",
+			task:            config.TaskConsumerJobs{File: []string{"other.sh"}, Commands: []string{"sh {file}", "false"}},
+			expectedCmds:    []string{"false", "sh " + filepath.Join(dir, "other.sh")},
+			expectedState:   CompleteTaskWithErrors,
+			expectedOutputs: 1,
+			expectedErrors:  1,
+		},
+		{
+			name:            "command timeout",
+			task:            config.TaskConsumerJobs{File: []string{"other.sh"}, Commands: []string{"sleep 10", "sh {file}"}, CommandTimeout: "200ms"},
+			expectedCmds:    []string{"sh " + filepath.Join(dir, "other.sh"), "sleep 10"},
+			expectedState:   TimedOutTask,
+			expectedOutputs: 1,
+			expectedErrors:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			te, err := NewLocalTaskExecutor(context.Background(), config.ValidatedConfig{Config: &config.Config{}}, tt.task, dir)
+			if err != nil {
+				t.Fatalf("Failed to create local task executor: %v", err)
+			}
+			slices.Sort(te.Cmds)
+			if !reflect.DeepEqual(te.Cmds, tt.expectedCmds) {
+				t.Errorf("Expected commands %v, got %v", tt.expectedCmds, te.Cmds)
+			}
+			if err := te.RunTaskLocally(context.Background(), dir); err != nil {
+				t.Fatalf("Failed to run task locally: %v", err)
+			}
+			if te.State != tt.expectedState {
+				t.Errorf("Expected state %s, got %s", tt.expectedState, te.State)
+			}
+			if len(te.Outputs) != tt.expectedOutputs || len(te.Errors) != tt.expectedErrors {
+				t.Errorf("Expected %d outputs and %d errors, got %v and %v", tt.expectedOutputs, tt.expectedErrors, te.Outputs, te.Errors)
+			}
+			for _, e := range te.Errors {
+				if e == "" {
+					t.Errorf("Expected the errors of failed commands, got %q", te.Errors)
+				}
+			}
+			if len(te.Results) != len(te.Cmds) {
+				t.Fatalf("Expected a result for every command, got %v", te.Results)
+			}
+			for i, res := range te.Results {
+				if res.Command != te.Cmds[i] || res.Host != LocalWorkerName || res.ID == "" {
+					t.Errorf("Expected result of command %s on %s, got %+v", te.Cmds[i], LocalWorkerName, res)
+				}
+				if (res.ExitCode != 0) != (res.Error != "") {
+					t.Errorf("Expected a non zero exit code only for failed commands, got %+v", res)
+				}
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	te, err := NewLocalTaskExecutor(context.Background(), config.ValidatedConfig{Config: &config.Config{}}, config.TaskConsumerJobs{File: []string{"other.sh"}, Commands: []string{"sh {file}"}}, dir)
+	if err != nil {
+		t.Fatalf("Failed to create local task executor: %v", err)
+	}
+	if err := te.RunTaskLocally(ctx, dir); err == nil || te.State != CancelledTask {
+		t.Errorf("Expected cancelled task, got state %s and error %v", te.State, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	te, err = NewLocalTaskExecutor(context.Background(), config.ValidatedConfig{Config: &config.Config{}}, config.TaskConsumerJobs{File: []string{"other.sh"}, Commands: []string{"sleep 10"}}, dir)
+	if err != nil {
+		t.Fatalf("Failed to create local task executor: %v", err)
+	}
+	if err := te.RunTaskLocally(ctx, dir); err == nil || te.State != TimedOutTask {
+		t.Errorf("Expected timed out task, got state %s and error %v", te.State, err)
+	}
+}
+
+func TestRunTaskLocallyEnv(t *testing.T) {
+	cfg := config.ValidatedConfig{Config: &config.Config{
+		Env: &config.Environment{GlobalEnv: map[string]string{"GREETING": "hello", "NAME": "global"}},
+	}}
+	task := config.TaskConsumerJobs{
+		File:     []string{"x"},
+		Commands: []string{"echo $GREETING $NAME"},
+		Env:      map[string]string{"NAME": "task"},
+	}
+	te, err := NewLocalTaskExecutor(context.Background(), cfg, task, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local task executor: %v", err)
+	}
+	if err := te.RunTaskLocally(context.Background(), t.TempDir()); err != nil {
+		t.Fatalf("Failed to run task locally: %v", err)
+	}
+	if len(te.Outputs) != 1 || te.Outputs[0] != "hello task\n" {
+		t.Errorf("Expected the task env to override the global env, got %q", te.Outputs)
+	}
+}
@@ -28,6 +28,7 @@ type WorkerConfig struct {
 	WorkerName    string                 `protobuf:"bytes,1,opt,name=worker_name,json=workerName,proto3" json:"worker_name,omitempty"`
 	Req           *pb.SyncRequest        `protobuf:"bytes,2,opt,name=req,proto3" json:"req,omitempty"`
 	BuildSteps    []string               `protobuf:"bytes,3,rep,name=build_steps,json=buildSteps,proto3" json:"build_steps,omitempty"`
+	Env           map[string]string      `protobuf:"bytes,4,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // environment variables of the build steps
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -83,11 +84,19 @@ func (x *WorkerConfig) GetBuildSteps() []string {
 	return nil
 }
 
+func (x *WorkerConfig) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
 type WorkerBuildOutput struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	WorkerName    string                 `protobuf:"bytes,1,opt,name=WorkerName,proto3" json:"WorkerName,omitempty"`
 	Output        string                 `protobuf:"bytes,2,opt,name=Output,proto3" json:"Output,omitempty"`
 	Error         *WorkerBuildError      `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	TimedOut      bool                   `protobuf:"varint,4,opt,name=TimedOut,proto3" json:"TimedOut,omitempty"` // the build was killed since it exceeded the build timeout
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -143,6 +152,13 @@ func (x *WorkerBuildOutput) GetError() *WorkerBuildError {
 	return nil
 }
 
+func (x *WorkerBuildOutput) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
 type WorkerBuildError struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Error         string                 `protobuf:"bytes,1,opt,name=Error,proto3" json:"Error,omitempty"`
@@ -187,28 +203,250 @@ func (x *WorkerBuildError) GetError() string {
 	return ""
 }
 
+type WorkerBuildLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          string                 `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`
+	Output        *WorkerBuildOutput     `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"` // set once the build finished
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerBuildLog) Reset() {
+	*x = WorkerBuildLog{}
+	mi := &file_sync_build_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerBuildLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerBuildLog) ProtoMessage() {}
+
+func (x *WorkerBuildLog) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_build_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerBuildLog.ProtoReflect.Descriptor instead.
+func (*WorkerBuildLog) Descriptor() ([]byte, []int) {
+	return file_sync_build_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WorkerBuildLog) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+func (x *WorkerBuildLog) GetOutput() *WorkerBuildOutput {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+type InstallRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerName    string                 `protobuf:"bytes,1,opt,name=worker_name,json=workerName,proto3" json:"worker_name,omitempty"`
+	Steps         []string               `protobuf:"bytes,2,rep,name=steps,proto3" json:"steps,omitempty"`
+	Fingerprint   string                 `protobuf:"bytes,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"` // identifies the step list, the steps rerun only when it changes
+	Env           map[string]string      `protobuf:"bytes,4,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InstallRequest) Reset() {
+	*x = InstallRequest{}
+	mi := &file_sync_build_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstallRequest) ProtoMessage() {}
+
+func (x *InstallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_build_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstallRequest.ProtoReflect.Descriptor instead.
+func (*InstallRequest) Descriptor() ([]byte, []int) {
+	return file_sync_build_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InstallRequest) GetWorkerName() string {
+	if x != nil {
+		return x.WorkerName
+	}
+	return ""
+}
+
+func (x *InstallRequest) GetSteps() []string {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *InstallRequest) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *InstallRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+type InstallOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerName    string                 `protobuf:"bytes,1,opt,name=worker_name,json=workerName,proto3" json:"worker_name,omitempty"`
+	Fingerprint   string                 `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Skipped       bool                   `protobuf:"varint,3,opt,name=skipped,proto3" json:"skipped,omitempty"` // the steps already completed with the same fingerprint
+	Output        string                 `protobuf:"bytes,4,opt,name=output,proto3" json:"output,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InstallOutput) Reset() {
+	*x = InstallOutput{}
+	mi := &file_sync_build_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstallOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstallOutput) ProtoMessage() {}
+
+func (x *InstallOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_build_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstallOutput.ProtoReflect.Descriptor instead.
+func (*InstallOutput) Descriptor() ([]byte, []int) {
+	return file_sync_build_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InstallOutput) GetWorkerName() string {
+	if x != nil {
+		return x.WorkerName
+	}
+	return ""
+}
+
+func (x *InstallOutput) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *InstallOutput) GetSkipped() bool {
+	if x != nil {
+		return x.Skipped
+	}
+	return false
+}
+
+func (x *InstallOutput) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *InstallOutput) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_sync_build_proto protoreflect.FileDescriptor
 
 const file_sync_build_proto_rawDesc = "" +
 	"\n" +
-	"\x10sync/build.proto\x12\x04sync\x1a\x17provider/provider.proto\x1a\x1bgoogle/protobuf/empty.proto\"y\n" +
+	"\x10sync/build.proto\x12\x04sync\x1a\x17provider/provider.proto\x1a\x1bgoogle/protobuf/empty.proto\"\xe0\x01\n" +
 	"\fWorkerConfig\x12\x1f\n" +
 	"\vworker_name\x18\x01 \x01(\tR\n" +
 	"workerName\x12'\n" +
 	"\x03req\x18\x02 \x01(\v2\x15.provider.SyncRequestR\x03req\x12\x1f\n" +
 	"\vbuild_steps\x18\x03 \x03(\tR\n" +
-	"buildSteps\"y\n" +
+	"buildSteps\x12-\n" +
+	"\x03env\x18\x04 \x03(\v2\x1b.sync.WorkerConfig.EnvEntryR\x03env\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x95\x01\n" +
 	"\x11WorkerBuildOutput\x12\x1e\n" +
 	"\n" +
 	"WorkerName\x18\x01 \x01(\tR\n" +
 	"WorkerName\x12\x16\n" +
 	"\x06Output\x18\x02 \x01(\tR\x06Output\x12,\n" +
-	"\x05error\x18\x03 \x01(\v2\x16.sync.WorkerBuildErrorR\x05error\"(\n" +
+	"\x05error\x18\x03 \x01(\v2\x16.sync.WorkerBuildErrorR\x05error\x12\x1a\n" +
+	"\bTimedOut\x18\x04 \x01(\bR\bTimedOut\"(\n" +
 	"\x10WorkerBuildError\x12\x14\n" +
-	"\x05Error\x18\x01 \x01(\tR\x05Error2\x98\x01\n" +
+	"\x05Error\x18\x01 \x01(\tR\x05Error\"U\n" +
+	"\x0eWorkerBuildLog\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\tR\x04line\x12/\n" +
+	"\x06output\x18\x02 \x01(\v2\x17.sync.WorkerBuildOutputR\x06output\"\xd2\x01\n" +
+	"\x0eInstallRequest\x12\x1f\n" +
+	"\vworker_name\x18\x01 \x01(\tR\n" +
+	"workerName\x12\x14\n" +
+	"\x05steps\x18\x02 \x03(\tR\x05steps\x12 \n" +
+	"\vfingerprint\x18\x03 \x01(\tR\vfingerprint\x12/\n" +
+	"\x03env\x18\x04 \x03(\v2\x1d.sync.InstallRequest.EnvEntryR\x03env\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9a\x01\n" +
+	"\rInstallOutput\x12\x1f\n" +
+	"\vworker_name\x18\x01 \x01(\tR\n" +
+	"workerName\x12 \n" +
+	"\vfingerprint\x18\x02 \x01(\tR\vfingerprint\x12\x18\n" +
+	"\askipped\x18\x03 \x01(\bR\askipped\x12\x16\n" +
+	"\x06output\x18\x04 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error2\x97\x02\n" +
 	"\rWorkerBuilder\x12>\n" +
-	"\x0fBuildRepository\x12\x12.sync.WorkerConfig\x1a\x17.sync.WorkerBuildOutput\x12G\n" +
-	"\x19RemoveRepositoryWorkspace\x12\x12.sync.WorkerConfig\x1a\x16.google.protobuf.EmptyB2Z0github.com/ImTheCurse/ConflowCI/internal/sync/pbb\x06proto3"
+	"\x0fBuildRepository\x12\x12.sync.WorkerConfig\x1a\x17.sync.WorkerBuildOutput\x12C\n" +
+	"\x15StreamBuildRepository\x12\x12.sync.WorkerConfig\x1a\x14.sync.WorkerBuildLog0\x01\x12G\n" +
+	"\x19RemoveRepositoryWorkspace\x12\x12.sync.WorkerConfig\x1a\x16.google.protobuf.Empty\x128\n" +
+	"\vInstallHost\x12\x14.sync.InstallRequest\x1a\x13.sync.InstallOutputB2Z0github.com/ImTheCurse/ConflowCI/internal/sync/pbb\x06proto3"
 
 var (
 	file_sync_build_proto_rawDescOnce sync.Once
@@ -222,26 +460,38 @@ func file_sync_build_proto_rawDescGZIP() []byte {
 	return file_sync_build_proto_rawDescData
 }
 
-var file_sync_build_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_sync_build_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_sync_build_proto_goTypes = []any{
 	(*WorkerConfig)(nil),      // 0: sync.WorkerConfig
 	(*WorkerBuildOutput)(nil), // 1: sync.WorkerBuildOutput
 	(*WorkerBuildError)(nil),  // 2: sync.WorkerBuildError
-	(*pb.SyncRequest)(nil),    // 3: provider.SyncRequest
-	(*emptypb.Empty)(nil),     // 4: google.protobuf.Empty
+	(*WorkerBuildLog)(nil),    // 3: sync.WorkerBuildLog
+	(*InstallRequest)(nil),    // 4: sync.InstallRequest
+	(*InstallOutput)(nil),     // 5: sync.InstallOutput
+	nil,                       // 6: sync.WorkerConfig.EnvEntry
+	nil,                       // 7: sync.InstallRequest.EnvEntry
+	(*pb.SyncRequest)(nil),    // 8: provider.SyncRequest
+	(*emptypb.Empty)(nil),     // 9: google.protobuf.Empty
 }
 var file_sync_build_proto_depIdxs = []int32{
-	3, // 0: sync.WorkerConfig.req:type_name -> provider.SyncRequest
-	2, // 1: sync.WorkerBuildOutput.error:type_name -> sync.WorkerBuildError
-	0, // 2: sync.WorkerBuilder.BuildRepository:input_type -> sync.WorkerConfig
-	0, // 3: sync.WorkerBuilder.RemoveRepositoryWorkspace:input_type -> sync.WorkerConfig
-	1, // 4: sync.WorkerBuilder.BuildRepository:output_type -> sync.WorkerBuildOutput
-	4, // 5: sync.WorkerBuilder.RemoveRepositoryWorkspace:output_type -> google.protobuf.Empty
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	8, // 0: sync.WorkerConfig.req:type_name -> provider.SyncRequest
+	6, // 1: sync.WorkerConfig.env:type_name -> sync.WorkerConfig.EnvEntry
+	2, // 2: sync.WorkerBuildOutput.error:type_name -> sync.WorkerBuildError
+	1, // 3: sync.WorkerBuildLog.output:type_name -> sync.WorkerBuildOutput
+	7, // 4: sync.InstallRequest.env:type_name -> sync.InstallRequest.EnvEntry
+	0, // 5: sync.WorkerBuilder.BuildRepository:input_type -> sync.WorkerConfig
+	0, // 6: sync.WorkerBuilder.StreamBuildRepository:input_type -> sync.WorkerConfig
+	0, // 7: sync.WorkerBuilder.RemoveRepositoryWorkspace:input_type -> sync.WorkerConfig
+	4, // 8: sync.WorkerBuilder.InstallHost:input_type -> sync.InstallRequest
+	1, // 9: sync.WorkerBuilder.BuildRepository:output_type -> sync.WorkerBuildOutput
+	3, // 10: sync.WorkerBuilder.StreamBuildRepository:output_type -> sync.WorkerBuildLog
+	9, // 11: sync.WorkerBuilder.RemoveRepositoryWorkspace:output_type -> google.protobuf.Empty
+	5, // 12: sync.WorkerBuilder.InstallHost:output_type -> sync.InstallOutput
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_sync_build_proto_init() }
@@ -255,7 +505,7 @@ func file_sync_build_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sync_build_proto_rawDesc), len(file_sync_build_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,7 +21,9 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	WorkerBuilder_BuildRepository_FullMethodName           = "/sync.WorkerBuilder/BuildRepository"
+	WorkerBuilder_StreamBuildRepository_FullMethodName     = "/sync.WorkerBuilder/StreamBuildRepository"
 	WorkerBuilder_RemoveRepositoryWorkspace_FullMethodName = "/sync.WorkerBuilder/RemoveRepositoryWorkspace"
+	WorkerBuilder_InstallHost_FullMethodName               = "/sync.WorkerBuilder/InstallHost"
 )
 
 // WorkerBuilderClient is the client API for WorkerBuilder service.
@@ -29,7 +31,13 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type WorkerBuilderClient interface {
 	BuildRepository(ctx context.Context, in *WorkerConfig, opts ...grpc.CallOption) (*WorkerBuildOutput, error)
+	// StreamBuildRepository builds the repository like BuildRepository, streaming each line of the
+	// build output as it is written, the last message holds the build output.
+	StreamBuildRepository(ctx context.Context, in *WorkerConfig, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WorkerBuildLog], error)
 	RemoveRepositoryWorkspace(ctx context.Context, in *WorkerConfig, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// InstallHost runs the install steps of the host, the steps are skipped if they already
+	// completed successfully with the same fingerprint.
+	InstallHost(ctx context.Context, in *InstallRequest, opts ...grpc.CallOption) (*InstallOutput, error)
 }
 
 type workerBuilderClient struct {
@@ -50,6 +58,25 @@ func (c *workerBuilderClient) BuildRepository(ctx context.Context, in *WorkerCon
 	return out, nil
 }
 
+func (c *workerBuilderClient) StreamBuildRepository(ctx context.Context, in *WorkerConfig, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WorkerBuildLog], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WorkerBuilder_ServiceDesc.Streams[0], WorkerBuilder_StreamBuildRepository_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WorkerConfig, WorkerBuildLog]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerBuilder_StreamBuildRepositoryClient = grpc.ServerStreamingClient[WorkerBuildLog]
+
 func (c *workerBuilderClient) RemoveRepositoryWorkspace(ctx context.Context, in *WorkerConfig, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -60,12 +87,28 @@ func (c *workerBuilderClient) RemoveRepositoryWorkspace(ctx context.Context, in
 	return out, nil
 }
 
+func (c *workerBuilderClient) InstallHost(ctx context.Context, in *InstallRequest, opts ...grpc.CallOption) (*InstallOutput, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InstallOutput)
+	err := c.cc.Invoke(ctx, WorkerBuilder_InstallHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WorkerBuilderServer is the server API for WorkerBuilder service.
 // All implementations should embed UnimplementedWorkerBuilderServer
 // for forward compatibility.
 type WorkerBuilderServer interface {
 	BuildRepository(context.Context, *WorkerConfig) (*WorkerBuildOutput, error)
+	// StreamBuildRepository builds the repository like BuildRepository, streaming each line of the
+	// build output as it is written, the last message holds the build output.
+	StreamBuildRepository(*WorkerConfig, grpc.ServerStreamingServer[WorkerBuildLog]) error
 	RemoveRepositoryWorkspace(context.Context, *WorkerConfig) (*emptypb.Empty, error)
+	// InstallHost runs the install steps of the host, the steps are skipped if they already
+	// completed successfully with the same fingerprint.
+	InstallHost(context.Context, *InstallRequest) (*InstallOutput, error)
 }
 
 // UnimplementedWorkerBuilderServer should be embedded to have
@@ -78,9 +121,15 @@ type UnimplementedWorkerBuilderServer struct{}
 func (UnimplementedWorkerBuilderServer) BuildRepository(context.Context, *WorkerConfig) (*WorkerBuildOutput, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BuildRepository not implemented")
 }
+func (UnimplementedWorkerBuilderServer) StreamBuildRepository(*WorkerConfig, grpc.ServerStreamingServer[WorkerBuildLog]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamBuildRepository not implemented")
+}
 func (UnimplementedWorkerBuilderServer) RemoveRepositoryWorkspace(context.Context, *WorkerConfig) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveRepositoryWorkspace not implemented")
 }
+func (UnimplementedWorkerBuilderServer) InstallHost(context.Context, *InstallRequest) (*InstallOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InstallHost not implemented")
+}
 func (UnimplementedWorkerBuilderServer) testEmbeddedByValue() {}
 
 // UnsafeWorkerBuilderServer may be embedded to opt out of forward compatibility for this service.
@@ -119,6 +168,17 @@ func _WorkerBuilder_BuildRepository_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkerBuilder_StreamBuildRepository_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WorkerConfig)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkerBuilderServer).StreamBuildRepository(m, &grpc.GenericServerStream[WorkerConfig, WorkerBuildLog]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerBuilder_StreamBuildRepositoryServer = grpc.ServerStreamingServer[WorkerBuildLog]
+
 func _WorkerBuilder_RemoveRepositoryWorkspace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(WorkerConfig)
 	if err := dec(in); err != nil {
@@ -137,6 +197,24 @@ func _WorkerBuilder_RemoveRepositoryWorkspace_Handler(srv interface{}, ctx conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkerBuilder_InstallHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InstallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerBuilderServer).InstallHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WorkerBuilder_InstallHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerBuilderServer).InstallHost(ctx, req.(*InstallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // WorkerBuilder_ServiceDesc is the grpc.ServiceDesc for WorkerBuilder service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -152,7 +230,17 @@ var WorkerBuilder_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveRepositoryWorkspace",
 			Handler:    _WorkerBuilder_RemoveRepositoryWorkspace_Handler,
 		},
+		{
+			MethodName: "InstallHost",
+			Handler:    _WorkerBuilder_InstallHost_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamBuildRepository",
+			Handler:       _WorkerBuilder_StreamBuildRepository_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "sync/build.proto",
 }
@@ -2,152 +2,313 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"os"
-	"sync"
 	"time"
 
 	"github.com/ImTheCurse/ConflowCI/internal/mq"
 	mqpb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/ImTheCurse/ConflowCI/pkg/grpc"
+	"github.com/google/uuid"
 )
 
-// RunTaskOnAllMachines distributes tasks across all endpoints
-func (te *TaskExecutor) RunTaskOnAllMachines() error {
+// RunTaskOnAllMachines distributes tasks across all endpoints.
+// cancelling ctx stops the consumers on the endpoints, which kill the commands that are still running.
+// the commands of endpoints using the ssh executor are consumed by the orchestrator and run over ssh.
+// commands and results are sent through queues of the task, which are deleted once the task finished.
+// every command is tracked until its result is received, commands of consumers that stopped are redelivered
+// by the broker and commands whose result is lost are requeued, so the task finishes even if its workers die.
+// the progress of the commands is passed to te.OnProgress, so a durable task can be resumed with ResumeTaskOnAllMachines.
+func (te *TaskExecutor) RunTaskOnAllMachines(ctx context.Context) error {
+	return te.runOnAllMachines(ctx, nil)
+}
+
+// ResumeTaskOnAllMachines resumes a task whose commands were published to the durable message queue before
+// the orchestrator restarted, from the progress of its commands. the results that were already received
+// are kept, and the task waits for the remaining results like RunTaskOnAllMachines does - the commands
+// that were running when the orchestrator stopped are redelivered by the broker.
+func (te *TaskExecutor) ResumeTaskOnAllMachines(ctx context.Context, progress []CommandProgress) error {
+	return te.runOnAllMachines(ctx, progress)
+}
+
+// runOnAllMachines runs the task's commands on all endpoints, the task is resumed if progress isn't nil.
+func (te *TaskExecutor) runOnAllMachines(parent context.Context, progress []CommandProgress) error {
 	uri := os.Getenv("CONFLOW_MQ_URI")
+	exchange := mq.Exchange(te.Durable)
 
 	te.State = RunningTask
 	logger.Printf("%s: with id: %s", te.State.String(), te.TaskID)
 
-	ctx, cancel := context.WithCancel(context.Background())
-
-	var wg sync.WaitGroup
-	wg.Add(len(te.Cmds))
-
-	params := mq.ConsumerParams{
-		QueueRoutingInfo: map[string]string{
-			mq.QueueNameCmd:    mq.RoutingKeyCmdQueue,
-			mq.QueueNameOutput: mq.RoutingKeyOutputQueue,
-			mq.QueueNameError:  mq.RoutingKeyErrorOutputQueue,
-		},
+	params := mq.TaskQueues(te.RunID.String(), te.TaskID.String())
+	if te.Durable {
+		params = mq.DurableTaskQueues(te.RunID.String(), te.TaskID.String())
 	}
+	if err := mq.DeclareQueues(uri, exchange, params); err != nil {
+		logger.Printf("Error declaring the queues of task %s: %v", te.TaskID, err)
+		te.State = ErrorInTask
+		return err
+	}
+	defer func() {
+		if err := mq.DeleteQueues(uri, params); err != nil {
+			logger.Printf("Failed to delete the queues of task %s: %v", te.TaskID, err)
+		}
+	}()
+	// the consumers are stopped before the queues are deleted.
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
 
-	var consumersReady sync.WaitGroup
-	consumersReady.Add(len(te.RunsOn))
-	// start all consumer goroutines
-	for _, ep := range te.RunsOn {
-		logger.Printf("Creating consumer for endpoint: %s", ep.Name)
+	msgs := make([]*mqpb.CommandMessage, len(te.Cmds))
+	for i, cmd := range te.Cmds {
+		msgs[i] = te.commandMessage(cmd)
+		if progress != nil {
+			// a resumed command keeps its ID, so its results are still matched to it.
+			msgs[i].CommandId = progress[i].ID
+		}
+	}
+	names := make([]string, len(te.RunsOn))
+	for i, ep := range te.RunsOn {
+		names[i] = ep.Name
+	}
+	tracker := newCommandTracker(msgs, names, te.CommandTimeout)
+	if progress != nil {
+		tracker.restore(progress)
+	}
 
-		go func() {
-			conn, err := grpc.CreateNewClientConnection(ep.GetEndpointURL())
-			if err != nil {
-				logger.Printf("Error creating gRPC connection: %v", err)
-				return
+	// handle is called with every message a consumer streams while consuming commands.
+	handle := func(worker string, msg *mqpb.ConsumerCommandResponse) {
+		if msg.Line != "" {
+			if te.OnLine != nil {
+				te.OnLine(worker, msg.Line)
 			}
+			return
+		}
+		if msg.Started {
+			tracker.started(worker, msg.CommandId)
+			return
+		}
 
-			client := mqpb.NewConsumerServicerClient(conn)
-			req := mqpb.ConsumerCommandRequest{
-				MqUrl:    uri,
-				Exchange: mq.ExchangeName,
-				Params:   params.QueueRoutingInfo,
-				Tag:      ep.Name,
-			}
-			stream, err := client.StartConsumer(ctx, &req)
-			if err != nil {
-				logger.Printf("Error starting consumer: %v", err)
-				return
-			}
+		if msg.FinishedCommand != nil {
+			logger.Printf("Command %s finished on %s", msg.CommandId, worker)
+			tracker.finished(worker, msg.CommandId)
+		}
 
-			consumersReady.Done()
-			logger.Printf("Consumer ready for endpoint: %s", ep.Name)
-
-			// we recieve the client's stream right away so client.StartConsumer isn't blocking.
-			for {
-				msg, err := stream.Recv()
-				if err != nil {
-					logger.Printf("Error receiving message: %v", err)
-					return
-				}
-
-				if msg.FinishedCommand != nil {
-					logger.Println("Command finished")
-					logger.Println("wg done in @RunTaskOnAllMachines")
-					wg.Done()
-				}
-
-				if msg.Error != nil {
-					logger.Printf("Error received from remote machine: %v", msg.Error)
-				}
-				logger.Printf("Output received from remote machine: %v", msg.Output)
-			}
-		}()
+		if msg.Error != nil {
+			logger.Printf("Error received from remote machine: %v", msg.Error)
+		}
+		logger.Printf("Output received from remote machine: %v", msg.Output)
 	}
 
-	consumersReady.Wait()
-	time.Sleep(3 * time.Second)
-	for i, cmd := range te.Cmds {
-		p, err := mq.NewPublisher(uri, mq.ExchangeName)
+	// the results are consumed while the commands run, the queues are declared so no result is lost.
+	resultErrs := make(chan error, 2)
+	for queue, tag := range map[string]string{params.OutputQueue: "output-consumer", params.ErrorQueue: "error-consumer"} {
+		consumer, err := mq.NewConsumer(uri, exchange, params, tag)
 		if err != nil {
-			logger.Printf("Error creating publisher: %v", err)
-			continue
+			logger.Printf("Error creating %s: %v", tag, err)
+			te.State = ErrorInTask
+			return err
 		}
-		defer p.Close()
-
-		p.Publish(ctx, mq.RoutingKeyCmdQueue, []byte(cmd))
-		logger.Printf("Published command: %s", cmd)
-		logger.Printf("%v commands remaining to publish.", len(te.Cmds)-i-1)
+		defer consumer.Close()
+		go func() {
+			resultErrs <- consumer.ConsumeResults(ctx, queue, tracker.result)
+		}()
 	}
 
-	wg.Wait()
-	cancel()
+	// start all consumer goroutines
+	for _, ep := range te.RunsOn {
+		logger.Printf("Creating consumer for endpoint: %s", ep.Name)
 
-	logger.Println("Getting command outputs and errors...")
+		if ep.IsSSH() {
+			go te.consumeOverSSH(ctx, ep, uri, exchange, params, tracker, handle)
+			continue
+		}
+		go te.consumeOnWorker(ctx, ep, uri, exchange, params, tracker, handle)
+	}
 
-	outputConsumer, err := mq.NewConsumer(uri, mq.ExchangeName, params, "output-consumer")
+	p, err := mq.NewPublisher(uri, exchange)
 	if err != nil {
-		logger.Printf("Error creating output consumer: %v", err)
+		logger.Printf("Error creating publisher: %v", err)
+		te.State = ErrorInTask
 		return err
 	}
-	defer outputConsumer.Close()
+	defer p.Close()
+	// the queues exist, so commands can be published before the consumers started.
+	publish := func(msg *mqpb.CommandMessage) {
+		if err := p.PublishCommand(ctx, params.CmdRoutingKey, msg); err != nil {
+			tracker.failed(msg.CommandId, fmt.Sprintf("failed to publish command: %v", err))
+			return
+		}
+		logger.Printf("Published command: %s", msg.Command)
+	}
+	if progress == nil {
+		for _, msg := range msgs {
+			publish(msg)
+		}
+		// report the published commands, so the task can be resumed before any of them started.
+		signal(tracker.changed)
+	}
 
-	errorConsumer, err := mq.NewConsumer(uri, mq.ExchangeName, params, "error-consumer")
+	ticker := time.NewTicker(commandTrackerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tracker.done:
+			te.setResults(tracker.commandResults())
+			logger.Printf("%s: with id: %s", te.State.String(), te.TaskID)
+			return nil
+		case <-tracker.wake:
+			for _, msg := range tracker.takeRequeued() {
+				publish(msg)
+			}
+		case <-tracker.changed:
+			if te.OnProgress != nil {
+				te.OnProgress(tracker.progress())
+			}
+		case now := <-ticker.C:
+			tracker.expire(now)
+		case err := <-resultErrs:
+			if err != nil && ctx.Err() == nil {
+				logger.Printf("Error consuming results of task %s: %v", te.TaskID, err)
+				te.State = ErrorInTask
+				return err
+			}
+		case <-ctx.Done():
+			return te.cancelled(ctx)
+		}
+	}
+}
+
+// consumeOnWorker starts a consumer of the task's commands on the worker of an endpoint, and passes every
+// message it streams to handle until ctx is done. the tracker is told once the consumer stopped.
+func (te *TaskExecutor) consumeOnWorker(ctx context.Context, ep config.EndpointInfo, uri, exchange string,
+	params mq.ConsumerParams, tracker *commandTracker, handle func(string, *mqpb.ConsumerCommandResponse)) {
+	defer consumerStopped(ctx, tracker, ep.Name)
+	conn, err := grpc.CreateNewClientConnection(ep.GetEndpointURL())
 	if err != nil {
-		logger.Printf("Error creating output consumer: %v", err)
-		return err
+		logger.Printf("Error creating gRPC connection: %v", err)
+		return
 	}
-	defer errorConsumer.Close()
+	defer conn.Close()
 
-	done := make(chan struct{})
+	client := mqpb.NewConsumerServicerClient(conn)
+	stream, err := client.StartConsumer(ctx, params.Request(uri, exchange, ep.Name))
+	if err != nil {
+		logger.Printf("Error starting consumer: %v", err)
+		return
+	}
+	logger.Printf("Consumer started for endpoint: %s", ep.Name)
 
-	var cmdResWg sync.WaitGroup
-	cmdResWg.Add(len(te.Cmds))
-	go func() {
-		// wait until all commands outputs/errors finish reading from queue
-		cmdResWg.Wait()
-		close(done)
-	}()
-	var errorsRes, outputsRes []string = []string{}, []string{}
-	var errorsErr, outputsError error = nil, nil
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			logger.Printf("Error receiving message: %v", err)
+			return
+		}
+		handle(ep.Name, msg)
+	}
+}
 
-	go errorConsumer.ConsumeQueueContents(&cmdResWg, done, mq.QueueNameError, &errorsRes, &errorsErr)
-	go outputConsumer.ConsumeQueueContents(&cmdResWg, done, mq.QueueNameOutput, &outputsRes, &outputsError)
+// consumerStopped tells the tracker a consumer stopped, unless the task stopped it.
+func consumerStopped(ctx context.Context, tracker *commandTracker, consumer string) {
+	if ctx.Err() == nil {
+		tracker.consumerDead(consumer)
+	}
+}
 
-	cmdResWg.Wait()
+// commandMessage returns the envelope of a command of the task, with a new command ID.
+func (te *TaskExecutor) commandMessage(cmd string) *mqpb.CommandMessage {
+	return &mqpb.CommandMessage{
+		RunId:     te.RunID.String(),
+		TaskName:  te.Name,
+		CommandId: uuid.NewString(),
+		Command:   cmd,
+		Env:       te.Env,
+		TimeoutMs: te.CommandTimeout.Milliseconds(),
+	}
+}
 
-	if errorsErr != nil {
-		logger.Printf("Error consuming error queue contents: %v", err)
-		return err
+// setResults sets the results of the task's commands, the outputs and errors of the task
+// and its final state. the error of a failed command is followed by its output.
+func (te *TaskExecutor) setResults(results []CommandResult) {
+	te.Results = results
+	te.Outputs, te.Errors = []string{}, []string{}
+	timedOut := false
+	for _, res := range results {
+		if res.Error != "" {
+			te.Errors = append(te.Errors, commandError(res))
+		} else {
+			te.Outputs = append(te.Outputs, res.Output)
+		}
+		timedOut = timedOut || res.TimedOut
 	}
-	if outputsError != nil {
-		logger.Printf("Error consuming output queue contents: %v", err)
-		return err
+	if timedOut {
+		te.State = TimedOutTask
+	} else if len(te.Errors) > 0 {
+		te.State = CompleteTaskWithErrors
+	} else {
+		te.State = CompletedTask
 	}
+}
 
-	if len(errorsRes) > 0 {
-		te.State = CompleteTaskWithErrors
-		logger.Printf("%s: with id: %s", te.State.String(), te.TaskID)
+// commandError returns the error of a failed command, with the output it wrote before failing.
+func commandError(res CommandResult) string {
+	if res.Output == "" || res.Output == res.Error {
+		return res.Error
+	}
+	return res.Error + "\n" + res.Output
+}
+
+func commandResultFromProto(res *mqpb.CommandResult) CommandResult {
+	return CommandResult{
+		ID:         res.CommandId,
+		Command:    res.Command,
+		Host:       res.Host,
+		ExitCode:   int(res.ExitCode),
+		StartedAt:  res.StartedAt.AsTime(),
+		FinishedAt: res.FinishedAt.AsTime(),
+		Output:     res.Output,
+		TimedOut:   res.TimedOut,
+		Error:      res.Error,
+	}
+}
+
+// consumeOverSSH consumes commands on behalf of an endpoint using the ssh executor, which doesn't run a
+// worker to consume them. the commands are run in ssh sessions on the endpoint, and every message
+// a worker would stream is passed to handle. the tracker is told once the consumer stopped.
+func (te *TaskExecutor) consumeOverSSH(ctx context.Context, ep config.EndpointInfo, uri, exchange string,
+	params mq.ConsumerParams, tracker *commandTracker, handle func(string, *mqpb.ConsumerCommandResponse)) {
+	defer consumerStopped(ctx, tracker, ep.Name)
+	h, err := dialSSHHost(ep)
+	if err != nil {
+		logger.Printf("Error connecting to %s over ssh: %v", ep.Name, err)
+		return
+	}
+	defer h.Close()
+
+	consumer, err := mq.NewConsumer(uri, exchange, params, ep.Name)
+	if err != nil {
+		logger.Printf("Error creating consumer: %v", err)
+		return
+	}
+	defer consumer.Close()
+
+	logger.Printf("Consumer ready for ssh endpoint: %s", ep.Name)
+	err = consumer.ConsumeCommandWith(ctx, params, h.runCommand, func(msg *mqpb.ConsumerCommandResponse) error {
+		handle(ep.Name, msg)
+		return nil
+	})
+	if err != nil {
+		logger.Printf("Error consuming commands for %s: %v", ep.Name, err)
 	}
+}
 
-	te.Outputs = outputsRes
-	te.Errors = errorsRes
-	return err
+// cancelled marks the task as cancelled and returns the cancellation cause,
+// the task timed out if ctx exceeded its deadline.
+func (te *TaskExecutor) cancelled(ctx context.Context) error {
+	te.State = CancelledTask
+	if ctx.Err() == context.DeadlineExceeded {
+		te.State = TimedOutTask
+	}
+	logger.Printf("%s: with id: %s", te.State.String(), te.TaskID)
+	return ctx.Err()
 }
@@ -73,12 +73,12 @@ func TestRunTaskOnAllMachines(t *testing.T) {
 		RunsOn:   []string{"test-1"},
 	}
 
-	te, err := NewTaskExecutor(valCfg, taskConsumer, "")
+	te, err := NewTaskExecutor(context.Background(), valCfg, taskConsumer, "")
 	if err != nil {
 		t.Errorf("Failed to create task executor: %v", err)
 	}
 	fmt.Println("Running all tasks...")
-	err = te.RunTaskOnAllMachines()
+	err = te.RunTaskOnAllMachines(context.Background())
 	for _, cmdOutput := range te.Outputs {
 		fmt.Printf("Command executed successfully, output: %s\n", cmdOutput)
 	}
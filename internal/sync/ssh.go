@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	syncPB "github.com/ImTheCurse/ConflowCI/internal/sync/pb"
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
+	cssh "github.com/ImTheCurse/ConflowCI/pkg/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHost is a connection to a host using the ssh executor, builds and commands run in ssh sessions
+// on the host instead of by a worker.
+type sshHost struct {
+	conn      *ssh.Client
+	home      string
+	buildPath string // the host's build directory, the equivalent of BuildPath on a worker
+}
+
+func dialSSHHost(ep config.EndpointInfo) (*sshHost, error) {
+	conn, err := cssh.DialEndpoint(ep)
+	if err != nil {
+		return nil, err
+	}
+	home, err := cssh.Home(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sshHost{conn: conn, home: home, buildPath: path.Join(home, SSHBuildDir)}, nil
+}
+
+func (h *sshHost) Close() error {
+	return h.conn.Close()
+}
+
+// remotePath maps paths under BuildPath in s to the host's build directory, since files and commands
+// are resolved by the orchestrator with the build directory of the workers.
+func (h *sshHost) remotePath(s string) string {
+	return strings.ReplaceAll(s, BuildPath, h.buildPath)
+}
+
+// localPath maps a path under the host's build directory to BuildPath.
+func (h *sshHost) localPath(s string) string {
+	if rel, ok := strings.CutPrefix(s, h.buildPath); ok {
+		return BuildPath + rel
+	}
+	return s
+}
+
+// runCommand runs a task command on the host, it implements mq.CommandRunner.
+func (h *sshHost) runCommand(ctx context.Context, cmd []byte, env map[string]string, onLine process.LineFunc) (
+	string, error) {
+	script := exportEnv(env) + h.remotePath(string(cmd)) + "\n"
+	out, err := cssh.RunScript(ctx, h.conn, script, onLine)
+	logger.Printf("Executed command over ssh: %s. got output: %s", string(cmd), out)
+	return out, err
+}
+
+// buildOverSSH syncs the repository, creates a work tree of the built branch and runs the build steps
+// on the host, like a worker does when building the repository.
+func (wb *WorkersBuilder) buildOverSSH(ctx context.Context, ep config.EndpointInfo) *syncPB.WorkerBuildOutput {
+	h, err := dialSSHHost(ep)
+	if err != nil {
+		e := GetProtoWorkerError("Error connecting over ssh", err, nil)
+		return &syncPB.WorkerBuildOutput{WorkerName: ep.Name, Error: &syncPB.WorkerBuildError{Error: e}}
+	}
+	defer h.Close()
+
+	out, err := cssh.RunScript(ctx, h.conn, wb.sshBuildScript(h.buildPath), func(line string) {
+		if wb.OnLine != nil {
+			wb.OnLine(ep.Name, line)
+		}
+	})
+	if err != nil {
+		e := GetProtoWorkerError("Error running commands", err, nil)
+		return &syncPB.WorkerBuildOutput{WorkerName: ep.Name, Output: out, Error: &syncPB.WorkerBuildError{Error: e}}
+	}
+	return &syncPB.WorkerBuildOutput{WorkerName: ep.Name, Output: out}
+}
+
+// sshBuildScript returns the script that builds the repository under buildPath.
+// the repository is cloned on the first build and fetched on later ones, the token is passed to git
+// through the environment of the script, which is written to the shell's stdin.
+// concurrent builds of the repository sync it one at a time, holding a lock next to it.
+func (wb *WorkersBuilder) sshBuildScript(buildPath string) string {
+	dir := path.Join(buildPath, wb.Name)
+	worktree := path.Join(buildPath, wb.Workspace())
+
+	var b strings.Builder
+	b.WriteString("set -e\nexport GIT_TERMINAL_PROMPT=0\n")
+	b.WriteString(sshLockRepository(buildPath, wb.Name))
+	if wb.Token != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + wb.Token))
+		fmt.Fprintf(&b, "export GIT_CONFIG_COUNT=1 GIT_CONFIG_KEY_0=http.extraHeader GIT_CONFIG_VALUE_0=%s\n",
+			cssh.Quote("Authorization: Basic "+auth))
+	}
+	fmt.Fprintf(&b, "if [ ! -d %[1]s ]; then git clone --depth 1 --single-branch --branch %[2]s %[3]s %[4]s; fi\n",
+		cssh.Quote(path.Join(dir, ".git")), cssh.Quote(wb.BranchName), cssh.Quote(wb.CloneURL), cssh.Quote(dir))
+	fmt.Fprintf(&b, "cd %s\n", cssh.Quote(dir))
+	fmt.Fprintf(&b, "git fetch %s %s\n", cssh.Quote(wb.Remote), cssh.Quote("+"+wb.fetchRef()))
+	fmt.Fprintf(&b, "git worktree add %s %s\n", cssh.Quote(worktree), cssh.Quote(wb.Workspace()))
+	b.WriteString("exec 9>&-\n")
+	b.WriteString("unset GIT_CONFIG_COUNT GIT_CONFIG_KEY_0 GIT_CONFIG_VALUE_0\n")
+	b.WriteString(exportEnv(wb.Env))
+	b.WriteString(buildCommand(cssh.Quote(worktree), wb.Steps) + "\n")
+	return b.String()
+}
+
+// sshLockRepository returns shell lines that wait for the lock of the repository name under buildPath,
+// the lock is held on file descriptor 9 until it's closed. hosts without flock aren't locked.
+func sshLockRepository(buildPath, name string) string {
+	lock := cssh.Quote(path.Join(buildPath, name+".lock"))
+	return fmt.Sprintf("mkdir -p %s\nexec 9>%s\nif command -v flock >/dev/null; then flock 9; fi\n",
+		cssh.Quote(buildPath), lock)
+}
+
+// removeWorkspaceOverSSH removes the work tree of the build and its local branch from the host.
+func (wb *WorkersBuilder) removeWorkspaceOverSSH(ep config.EndpointInfo) error {
+	h, err := dialSSHHost(ep)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	dir := path.Join(h.buildPath, wb.Name)
+	worktree := path.Join(h.buildPath, wb.Workspace())
+	script := sshLockRepository(h.buildPath, wb.Name) + fmt.Sprintf("cd %s && git worktree remove %s && git branch -D %s",
+		cssh.Quote(dir), cssh.Quote(worktree), cssh.Quote(wb.Workspace()))
+	var out bytes.Buffer
+	if err := cssh.Run(h.conn, script, &out); err != nil {
+		return fmt.Errorf("Failed to remove worktree: %v | output: %s", err, out.String())
+	}
+	return nil
+}
+
+// installOverSSH runs the install steps on the host once per fingerprint, like InstallHost does on a worker.
+func installOverSSH(ctx context.Context, ep config.EndpointInfo, req *syncPB.InstallRequest) (
+	*syncPB.InstallOutput, error) {
+	h, err := dialSSHHost(ep)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	res := &syncPB.InstallOutput{WorkerName: req.WorkerName, Fingerprint: req.Fingerprint}
+
+	fingerprint := cssh.Quote(path.Join(h.home, SSHInstallFingerprintFile))
+	var saved bytes.Buffer
+	cssh.Run(h.conn, "cat "+fingerprint+" 2>/dev/null", &saved)
+	if strings.TrimSpace(saved.String()) == req.Fingerprint {
+		logger.Printf("Install steps of %s with fingerprint %s already completed, skipping.", ep.Name, req.Fingerprint)
+		res.Skipped = true
+		return res, nil
+	}
+
+	script := exportEnv(req.Env) + strings.Join(req.Steps, " && ") + "\n"
+	out, err := cssh.RunScript(ctx, h.conn, script, nil)
+	res.Output = out
+	if err != nil {
+		res.Error = fmt.Sprintf("Error running install steps: %v", err)
+		return res, nil
+	}
+	var saveOut bytes.Buffer
+	save := fmt.Sprintf(`mkdir -p "$(dirname %[1]s)" && printf '%%s\n' %[2]s > %[1]s`, fingerprint, cssh.Quote(req.Fingerprint))
+	if err := cssh.Run(h.conn, save, &saveOut); err != nil {
+		res.Error = fmt.Sprintf("Error saving install fingerprint: %v: %s", err, saveOut.String())
+	}
+	return res, nil
+}
+
+// findFilesOverSSH returns the files of the work tree wsName in the host's build directory matching pattern,
+// the paths are mapped to BuildPath.
+func findFilesOverSSH(ep config.EndpointInfo, pattern, wsName string) ([]string, error) {
+	h, err := dialSSHHost(ep)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	var out bytes.Buffer
+	cmd := fmt.Sprintf("find %s -regextype posix-extended -regex %s", cssh.Quote(path.Join(h.buildPath, wsName)),
+		cssh.Quote(pattern))
+	if err := cssh.Run(h.conn, cmd, &out); err != nil {
+		logger.Printf("Error finding files over ssh, output: %s", out.String())
+		return nil, err
+	}
+	files := []string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, h.localPath(line))
+		}
+	}
+	return files, nil
+}
+
+// exportEnv returns shell lines exporting env, sorted by name.
+func exportEnv(env map[string]string) string {
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		fmt.Fprintf(&b, "export %s=%s\n", key, cssh.Quote(env[key]))
+	}
+	return b.String()
+}
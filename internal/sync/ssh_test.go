@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestSSHBuildScript runs the build script of the ssh executor on the local machine, with a local repository.
+func TestSSHBuildScript(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = origin
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=ci", "GIT_AUTHOR_EMAIL=ci@example.com",
+			"GIT_COMMITTER_NAME=ci", "GIT_COMMITTER_EMAIL=ci@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(origin, "hello.txt"), []byte("hello from main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "hello.txt")
+	git("commit", "-m", "initial")
+
+	buildPath := filepath.Join(t.TempDir(), "build dir")
+	wb := &WorkersBuilder{
+		Name:       "demo",
+		CloneURL:   "file://" + origin,
+		Remote:     "origin",
+		BuildID:    uuid.New(),
+		BranchName: "main",
+		BranchRef:  "main:ci-main",
+		Steps:      []string{"cat hello.txt", `echo "$GREETING"`},
+		Env:        map[string]string{"GREETING": "it's built"},
+	}
+	out, err := exec.Command("bash", "-c", wb.sshBuildScript(buildPath)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Build script failed: %v: %s", err, out)
+	}
+	if !strings.HasSuffix(string(out), "hello from main\nit's built\n") {
+		t.Errorf("Unexpected build output: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(buildPath, wb.Workspace(), "hello.txt")); err != nil {
+		t.Errorf("Expected a work tree of the built branch: %v", err)
+	}
+
+	// another build of the same branch gets its own work tree.
+	other := *wb
+	other.BuildID = uuid.New()
+	if out, err := exec.Command("bash", "-c", other.sshBuildScript(buildPath)).CombinedOutput(); err != nil {
+		t.Fatalf("Second build script failed: %v: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(buildPath, other.Workspace(), "hello.txt")); err != nil {
+		t.Errorf("Expected a work tree of the second build: %v", err)
+	}
+}
+
+func TestSSHHostPaths(t *testing.T) {
+	h := &sshHost{buildPath: "/home/ci/conflowci/build"}
+	cmd := "go test " + filepath.Join(BuildPath, "demo", "pkg_test.go")
+	if got := h.remotePath(cmd); got != "go test /home/ci/conflowci/build/demo/pkg_test.go" {
+		t.Errorf("Unexpected remote command: %s", got)
+	}
+	if got := h.localPath("/home/ci/conflowci/build/demo/pkg_test.go"); got != filepath.Join(BuildPath, "demo", "pkg_test.go") {
+		t.Errorf("Unexpected local path: %s", got)
+	}
+	if got := exportEnv(map[string]string{"B": "it's", "A": "1"}); got != "export A='1'\nexport B='it'\\''s'\n" {
+		t.Errorf("Unexpected env exports: %q", got)
+	}
+}
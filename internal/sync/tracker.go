@@ -0,0 +1,338 @@
+package sync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mqpb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
+)
+
+// CommandResultGrace is how long the orchestrator waits for the result of a command after its consumer
+// reported it finished before requeueing it, or after the command exceeded its timeout before failing it.
+const CommandResultGrace = 30 * time.Second
+
+// MaxCommandAttempts is the number of times a command is delivered before it fails for good,
+// when its consumers die or its results are lost.
+const MaxCommandAttempts = 3
+
+// commandTrackerInterval is how often the deadlines of the tracked commands are checked.
+const commandTrackerInterval = time.Second
+
+// commandStatus is the state of a published command of a task, as tracked by the orchestrator.
+type commandStatus uint
+
+const (
+	commandQueued   commandStatus = iota // published, no consumer acknowledged it yet
+	commandRunning                       // acknowledged by a consumer, which runs it
+	commandFinished                      // its consumer finished it, but its result wasn't received yet
+	commandDone                          // its result was received, or it failed for good
+)
+
+func (s commandStatus) String() string {
+	switch s {
+	case commandQueued:
+		return "queued"
+	case commandRunning:
+		return "running"
+	case commandFinished:
+		return "finished"
+	case commandDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+type trackedCommand struct {
+	msg      *mqpb.CommandMessage
+	index    int // index of the command in the task's commands
+	status   commandStatus
+	host     string    // consumer that acknowledged the command
+	attempts int       // number of times the command was delivered
+	deadline time.Time // the command is requeued if it isn't done by then, zero for no deadline
+}
+
+// commandTracker tracks every command of a task from publishing to its result, so the task always
+// finishes: commands of consumers that died are redelivered by the broker, since their consumer never
+// acknowledged them, and commands that were acknowledged without a result arriving in time are requeued.
+// a running command whose result doesn't arrive in time fails, since its consumer still holds it.
+// once no consumer is left, or a command was delivered MaxCommandAttempts times, the command fails.
+// commands waiting in the queue have no deadline, since a busy consumer may take them at any time.
+type commandTracker struct {
+	mu        sync.Mutex
+	cmds      map[string]*trackedCommand // by command ID
+	results   []CommandResult
+	remaining int
+	timeout   time.Duration   // timeout of every command, 0 for no limit
+	consumers map[string]bool // consumers by name, false once the consumer died
+	alive     int
+	requeued  []*mqpb.CommandMessage
+	now       func() time.Time
+
+	wake    chan struct{} // signalled when commands were requeued
+	changed chan struct{} // signalled when the progress of a command changed
+	done    chan struct{} // closed once every command is done
+}
+
+// newCommandTracker creates a tracker of the commands of msgs, which are consumed by the named consumers.
+// the commands are expected to be published once, by the caller.
+func newCommandTracker(msgs []*mqpb.CommandMessage, consumers []string, timeout time.Duration) *commandTracker {
+	t := &commandTracker{
+		cmds:      make(map[string]*trackedCommand, len(msgs)),
+		results:   make([]CommandResult, len(msgs)),
+		remaining: len(msgs),
+		timeout:   timeout,
+		consumers: make(map[string]bool, len(consumers)),
+		now:       time.Now,
+		wake:      make(chan struct{}, 1),
+		changed:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for i, msg := range msgs {
+		t.cmds[msg.CommandId] = &trackedCommand{msg: msg, index: i, attempts: 1}
+	}
+	for _, name := range consumers {
+		if !t.consumers[name] {
+			t.consumers[name] = true
+			t.alive++
+		}
+	}
+	if t.remaining == 0 {
+		close(t.done)
+	} else if t.alive == 0 {
+		t.failRemaining("no consumer to run the command")
+	}
+	return t
+}
+
+// started records that the consumer acknowledged the command and started running it.
+func (t *commandTracker) started(consumer, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.cmds[id]
+	if !ok || c.status == commandDone {
+		return
+	}
+	c.status = commandRunning
+	c.host = consumer
+	c.deadline = time.Time{}
+	if t.timeout > 0 {
+		c.deadline = t.now().Add(t.timeout + CommandResultGrace)
+	}
+	signal(t.changed)
+}
+
+// finished records that the consumer finished running the command, its result is expected shortly.
+func (t *commandTracker) finished(consumer, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.cmds[id]
+	if !ok || c.status == commandDone {
+		return
+	}
+	c.status = commandFinished
+	c.host = consumer
+	c.deadline = t.now().Add(CommandResultGrace)
+	signal(t.changed)
+}
+
+// result records the result of a command, it returns false for results of unknown commands and
+// for duplicate results, e.g. of a command that was requeued.
+func (t *commandTracker) result(res *mqpb.CommandResult) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.cmds[res.CommandId]
+	if !ok || c.status == commandDone {
+		return false
+	}
+	t.complete(c, commandResultFromProto(res))
+	return true
+}
+
+// failed fails the command for good, e.g. since it couldn't be published.
+func (t *commandTracker) failed(id, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.cmds[id]; ok && c.status != commandDone {
+		t.fail(c, reason)
+	}
+}
+
+// consumerDead records that the consumer stopped consuming, the commands it was running are redelivered
+// by the broker to the other consumers. commands it finished keep waiting for their result, and are requeued
+// if it doesn't arrive in time. once no consumer is left, the remaining commands fail.
+func (t *commandTracker) consumerDead(consumer string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.consumers[consumer] || t.remaining == 0 {
+		return
+	}
+	t.consumers[consumer] = false
+	t.alive--
+	logger.Printf("Consumer %s stopped, waiting for its unfinished commands to be redelivered", consumer)
+	if t.alive == 0 {
+		t.failRemaining("no consumer left to run the command")
+		return
+	}
+	for _, c := range t.cmds {
+		if c.host == consumer && c.status == commandRunning {
+			t.redeliver(c, fmt.Sprintf("consumer %s stopped", consumer))
+		}
+	}
+}
+
+// expire handles the commands that exceeded their deadline at now, finished commands are requeued
+// and running commands fail.
+func (t *commandTracker) expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.cmds {
+		if c.status == commandDone || c.deadline.IsZero() || !now.After(c.deadline) {
+			continue
+		}
+		if c.status == commandFinished {
+			t.requeue(c, fmt.Sprintf("no result received from %s", c.host))
+		} else {
+			t.fail(c, fmt.Sprintf("no result received from %s after the command timeout", c.host))
+		}
+	}
+}
+
+// takeRequeued returns the commands that have to be published again.
+func (t *commandTracker) takeRequeued() []*mqpb.CommandMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := t.requeued
+	t.requeued = nil
+	return msgs
+}
+
+// progress returns the progress of every command, in the order of the task's commands.
+func (t *commandTracker) progress() []CommandProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	progress := make([]CommandProgress, len(t.results))
+	for _, c := range t.cmds {
+		p := CommandProgress{
+			ID:       c.msg.CommandId,
+			Command:  c.msg.Command,
+			Status:   c.status.String(),
+			Host:     c.host,
+			Attempts: c.attempts,
+		}
+		if c.status == commandDone {
+			res := t.results[c.index]
+			p.Result = &res
+		}
+		progress[c.index] = p
+	}
+	return progress
+}
+
+// restore restores the progress of the commands of a resumed task, after the orchestrator restarted.
+// queued commands are still queued and finished commands published their result, so both are waited for.
+// commands that were running were killed once their consumers lost the orchestrator, and are redelivered.
+func (t *commandTracker) restore(progress []CommandProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range progress {
+		c, ok := t.cmds[p.ID]
+		if !ok || c.status == commandDone {
+			continue
+		}
+		c.host = p.Host
+		c.attempts = max(p.Attempts, 1)
+		switch {
+		case p.Result != nil:
+			t.complete(c, *p.Result)
+		case p.Status == commandFinished.String():
+			c.status = commandFinished
+			c.deadline = t.now().Add(CommandResultGrace)
+		case p.Status == commandRunning.String():
+			t.redeliver(c, fmt.Sprintf("orchestrator restarted while %s ran the command", p.Host))
+		}
+	}
+}
+
+// commandResults returns the result of every command, in the order of the task's commands.
+func (t *commandTracker) commandResults() []CommandResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]CommandResult{}, t.results...)
+}
+
+// requeue publishes a command again, its consumer acknowledged it without its result arriving.
+func (t *commandTracker) requeue(c *trackedCommand, reason string) {
+	if !t.retry(c, reason) {
+		return
+	}
+	logger.Printf("Requeueing command %s: %s", c.msg.Command, reason)
+	t.requeued = append(t.requeued, c.msg)
+	signal(t.wake)
+}
+
+// redeliver waits for a command to be delivered again, its consumer stopped without acknowledging it
+// so the broker redelivers it. publishing it again would run it twice.
+func (t *commandTracker) redeliver(c *trackedCommand, reason string) {
+	if t.retry(c, reason) {
+		logger.Printf("Waiting for command %s to be redelivered: %s", c.msg.Command, reason)
+	}
+}
+
+// retry marks a command as queued again, it returns false if the command failed since it was
+// already delivered MaxCommandAttempts times.
+func (t *commandTracker) retry(c *trackedCommand, reason string) bool {
+	if c.attempts >= MaxCommandAttempts {
+		t.fail(c, fmt.Sprintf("%s, command was delivered %d times without a result", reason, c.attempts))
+		return false
+	}
+	c.attempts++
+	c.status = commandQueued
+	c.host = ""
+	c.deadline = time.Time{}
+	signal(t.changed)
+	return true
+}
+
+func (t *commandTracker) failRemaining(reason string) {
+	for _, c := range t.cmds {
+		if c.status != commandDone {
+			t.fail(c, reason)
+		}
+	}
+}
+
+func (t *commandTracker) fail(c *trackedCommand, reason string) {
+	logger.Printf("Command %s failed: %s", c.msg.Command, reason)
+	now := t.now()
+	t.complete(c, CommandResult{
+		ID:         c.msg.CommandId,
+		Command:    c.msg.Command,
+		Host:       c.host,
+		ExitCode:   -1,
+		StartedAt:  now,
+		FinishedAt: now,
+		Output:     reason,
+		Error:      reason,
+	})
+}
+
+func (t *commandTracker) complete(c *trackedCommand, res CommandResult) {
+	c.status = commandDone
+	c.deadline = time.Time{}
+	t.results[c.index] = res
+	t.remaining--
+	signal(t.changed)
+	if t.remaining == 0 {
+		close(t.done)
+	}
+}
+
+// signal signals ch without blocking, signals that weren't received yet are merged.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,207 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	mqpb "github.com/ImTheCurse/ConflowCI/internal/mq/pb"
+)
+
+func TestCommandTracker(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// events are applied in order to a tracker of the commands "a" and "b", consumed by "w1" and "w2".
+	type event func(tr *commandTracker)
+	started := func(consumer, id string) event {
+		return func(tr *commandTracker) { tr.started(consumer, id) }
+	}
+	finished := func(consumer, id string) event {
+		return func(tr *commandTracker) { tr.finished(consumer, id) }
+	}
+	result := func(id string) event {
+		return func(tr *commandTracker) { tr.result(&mqpb.CommandResult{CommandId: id, Command: id, Output: id}) }
+	}
+	dead := func(consumer string) event {
+		return func(tr *commandTracker) { tr.consumerDead(consumer) }
+	}
+	expire := func(after time.Duration) event {
+		return func(tr *commandTracker) { tr.expire(start.Add(after)) }
+	}
+	tests := []struct {
+		name             string
+		consumers        []string
+		timeout          time.Duration
+		events           []event
+		expectedDone     bool
+		expectedRequeued []string
+		expectedErrors   []bool // whether the result of every command is an error, once done
+	}{
+		{
+			name:           "all results received",
+			consumers:      []string{"w1", "w2"},
+			events:         []event{started("w1", "a"), started("w2", "b"), result("b"), result("a")},
+			expectedDone:   true,
+			expectedErrors: []bool{false, false},
+		},
+		{
+			name:             "running commands of a dead consumer are redelivered by the broker",
+			consumers:        []string{"w1", "w2"},
+			events:           []event{started("w1", "a"), started("w2", "b"), dead("w1"), expire(time.Hour)},
+			expectedRequeued: []string{},
+		},
+		{
+			name:      "finished commands of a dead consumer wait for their result",
+			consumers: []string{"w1", "w2"},
+			events: []event{started("w1", "a"), finished("w1", "a"), started("w2", "b"), dead("w1"),
+				expire(CommandResultGrace + time.Second)},
+			expectedRequeued: []string{"a"},
+		},
+		{
+			name:           "requeued command completes on another consumer",
+			consumers:      []string{"w1", "w2"},
+			events:         []event{started("w1", "a"), dead("w1"), started("w2", "a"), result("a"), result("b")},
+			expectedDone:   true,
+			expectedErrors: []bool{false, false},
+		},
+		{
+			name:           "commands fail once no consumer is left",
+			consumers:      []string{"w1", "w2"},
+			events:         []event{result("a"), dead("w1"), dead("w2")},
+			expectedDone:   true,
+			expectedErrors: []bool{false, true},
+		},
+		{
+			name:           "commands fail without consumers",
+			expectedDone:   true,
+			expectedErrors: []bool{true, true},
+		},
+		{
+			name:      "queued commands have no deadline",
+			consumers: []string{"w1"},
+			timeout:   time.Second,
+			events:    []event{expire(time.Hour)},
+		},
+		{
+			name:      "running command exceeding its deadline fails",
+			consumers: []string{"w1"},
+			timeout:   time.Second,
+			events: []event{started("w1", "a"), expire(time.Second), expire(CommandResultGrace + 2*time.Second),
+				result("b")},
+			expectedDone:     true,
+			expectedRequeued: []string{},
+			expectedErrors:   []bool{true, false},
+		},
+		{
+			name:             "running command without a timeout has no deadline",
+			consumers:        []string{"w1"},
+			events:           []event{started("w1", "a"), expire(time.Hour)},
+			expectedRequeued: []string{},
+		},
+		{
+			name:             "lost result is requeued",
+			consumers:        []string{"w1"},
+			events:           []event{started("w1", "a"), finished("w1", "a"), expire(CommandResultGrace + time.Second)},
+			expectedRequeued: []string{"a"},
+		},
+		{
+			name:      "command fails after the maximum attempts",
+			consumers: []string{"w1"},
+			events: []event{
+				finished("w1", "a"), expire(CommandResultGrace + time.Second),
+				finished("w1", "a"), expire(CommandResultGrace + time.Second),
+				finished("w1", "a"), expire(CommandResultGrace + time.Second),
+				result("b"),
+			},
+			expectedDone:   true,
+			expectedErrors: []bool{true, false},
+		},
+		{
+			name:           "duplicate results are dropped",
+			consumers:      []string{"w1"},
+			events:         []event{result("a"), result("a"), result("unknown"), result("b")},
+			expectedDone:   true,
+			expectedErrors: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := []*mqpb.CommandMessage{{CommandId: "a", Command: "a"}, {CommandId: "b", Command: "b"}}
+			tr := newCommandTracker(msgs, tt.consumers, tt.timeout)
+			tr.now = func() time.Time { return start }
+			for _, e := range tt.events {
+				e(tr)
+			}
+
+			requeued := []string{}
+			for _, msg := range tr.takeRequeued() {
+				requeued = append(requeued, msg.CommandId)
+			}
+			slices.Sort(requeued)
+			if tt.expectedRequeued != nil && !slices.Equal(requeued, tt.expectedRequeued) {
+				t.Errorf("Expected requeued commands %v, got %v", tt.expectedRequeued, requeued)
+			}
+
+			select {
+			case <-tr.done:
+				if !tt.expectedDone {
+					t.Fatalf("Expected the tracker to wait for results")
+				}
+			default:
+				if tt.expectedDone {
+					t.Fatalf("Expected every command to be done")
+				}
+				return
+			}
+			for i, res := range tr.commandResults() {
+				if res.ID != msgs[i].CommandId {
+					t.Errorf("Expected result %d to be of command %s, got %s", i, msgs[i].CommandId, res.ID)
+				}
+				if (res.Error != "") != tt.expectedErrors[i] {
+					t.Errorf("Expected error of command %s to be %v, got %q", res.ID, tt.expectedErrors[i], res.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandTrackerRestore(t *testing.T) {
+	msgs := []*mqpb.CommandMessage{
+		{CommandId: "queued", Command: "a"},
+		{CommandId: "running", Command: "b"},
+		{CommandId: "finished", Command: "c"},
+		{CommandId: "done", Command: "d"},
+	}
+	tr := newCommandTracker(msgs, []string{"w1"}, 0)
+	tr.restore([]CommandProgress{
+		{ID: "queued", Command: "a", Status: "queued", Attempts: 1},
+		{ID: "running", Command: "b", Status: "running", Host: "w1", Attempts: 1},
+		{ID: "finished", Command: "c", Status: "finished", Host: "w1", Attempts: 2},
+		{ID: "done", Command: "d", Status: "done", Host: "w1", Attempts: 1, Result: &CommandResult{ID: "done", Output: "d"}},
+	})
+
+	// the command that was running when the orchestrator stopped is redelivered by the broker, not published again.
+	if requeued := tr.takeRequeued(); len(requeued) != 0 {
+		t.Errorf("Expected no command to be requeued, got %v", requeued)
+	}
+	expected := []string{"queued", "queued", "finished", "done"}
+	for i, p := range tr.progress() {
+		if p.ID != msgs[i].CommandId || p.Status != expected[i] {
+			t.Errorf("Expected command %s to be %s, got %s", msgs[i].CommandId, expected[i], p.Status)
+		}
+	}
+	if p := tr.progress()[2]; p.Attempts != 2 {
+		t.Errorf("Expected the attempts of the finished command to be restored, got %d", p.Attempts)
+	}
+
+	for _, id := range []string{"queued", "running", "finished"} {
+		tr.result(&mqpb.CommandResult{CommandId: id})
+	}
+	select {
+	case <-tr.done:
+	default:
+		t.Fatalf("Expected every command to be done")
+	}
+	if res := tr.commandResults()[3]; res.Output != "d" {
+		t.Errorf("Expected the restored result to be kept, got %+v", res)
+	}
+}
@@ -3,6 +3,8 @@ package sync
 import (
 	"log"
 	"os"
+	"sync"
+	"time"
 
 	providerPB "github.com/ImTheCurse/ConflowCI/internal/provider/pb"
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
@@ -20,6 +22,9 @@ const (
 	CompletedTask
 	ErrorInTask
 	CompleteTaskWithErrors
+	SkippedTask   // not executed, since a task it depends on did not complete successfully
+	CancelledTask // the run the task belongs to was cancelled
+	TimedOutTask  // the task or one of its commands exceeded its timeout and was killed
 )
 
 func (s TaskState) String() string {
@@ -34,11 +39,37 @@ func (s TaskState) String() string {
 		return "Error executing task"
 	case CompleteTaskWithErrors:
 		return "Completed with errors"
+	case SkippedTask:
+		return "Skipped task"
+	case CancelledTask:
+		return "Cancelled task"
+	case TimedOutTask:
+		return "Timed out task"
 	default:
 		return "Unkown task state"
 	}
 }
 
+// ParseTaskState returns the task state whose String is s.
+func ParseTaskState(s string) (TaskState, bool) {
+	for state := StartingTask; state <= TimedOutTask; state++ {
+		if state.String() == s {
+			return state, true
+		}
+	}
+	return 0, false
+}
+
+// Failed returns if the task finished without completing successfully, because of a failed command,
+// an error executing it or a timeout.
+func (s TaskState) Failed() bool {
+	switch s {
+	case ErrorInTask, CompleteTaskWithErrors, TimedOutTask:
+		return true
+	}
+	return false
+}
+
 // Current state of a task.
 type BuildState uint
 
@@ -69,26 +100,77 @@ func (s BuildState) String() string {
 
 var BuildPath string = os.ExpandEnv("$HOME/conflowci/build")
 
+// InstallFingerprintPath is the file a worker saves the fingerprint of the last successful install steps in.
+var InstallFingerprintPath string = os.ExpandEnv("$HOME/conflowci/install.fingerprint")
+
+// SSHBuildDir is the build directory of hosts using the ssh executor, relative to the ssh user's home.
+const SSHBuildDir = "conflowci/build"
+
+// SSHInstallFingerprintFile is the install fingerprint file of hosts using the ssh executor,
+// relative to the ssh user's home.
+const SSHInstallFingerprintFile = "conflowci/install.fingerprint"
+
 const metadataFileName string = ".conflowci.toml"
 
+// LogFunc is called with every line of output a worker streams, while a build or command runs.
+type LogFunc func(worker, line string)
+
 // TaskExecutor represents a task syncing for remote machines
 // it tracks each state of the task, and is responsible for dispatching tasks
 // to remote machines.
 // It does the dispatching after the project is already built
 type TaskExecutor struct {
 	TaskID  uuid.UUID
+	RunID   uuid.UUID // run the task belongs to, sent with every command
+	Name    string    // name of the task, sent with every command
 	State   TaskState
 	RunsOn  []config.EndpointInfo
 	Files   []string
 	Cmds    []string
 	Outputs []string
 	Errors  []string
+	Env     map[string]string // environment variables of the commands
+	OnLine  LogFunc           // can be nil
+	// CommandTimeout is the maximum duration of each command, commands exceeding it are killed and
+	// the task times out. 0 for no limit.
+	CommandTimeout time.Duration
+	Results        []CommandResult // result of every command, in the order of Cmds
+	// Durable sends the commands through the durable message queue, so the task can be resumed.
+	Durable bool
+	// OnProgress is called with the progress of every command whenever it changes, while the commands
+	// run on the endpoints. can be nil.
+	OnProgress func([]CommandProgress)
+}
+
+// CommandProgress is the progress of a published command of a task, the task can be resumed
+// from it with ResumeTaskOnAllMachines.
+type CommandProgress struct {
+	ID       string
+	Command  string
+	Status   string         // queued, running, finished or done
+	Host     string         // consumer that acknowledged the command
+	Attempts int            // number of times the command was published
+	Result   *CommandResult // set once the command is done
+}
+
+// CommandResult is the result of a single command of a task, on the host that ran it.
+type CommandResult struct {
+	ID         string
+	Command    string
+	Host       string
+	ExitCode   int // -1 if the command didn't exit normally, e.g. it was killed
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Output     string
+	TimedOut   bool
+	Error      string // why the command failed, empty if it succeeded
 }
 
 type TaskExecutorServer struct{}
 
 type WorkerBuilderServer struct {
-	provider providerPB.RepositoryProviderClient
+	provider  providerPB.RepositoryProviderClient
+	repoLocks sync.Map // repository directory to the *sync.Mutex serializing its syncs
 }
 type WorkersBuilder struct {
 	Name       string
@@ -101,6 +183,9 @@ type WorkersBuilder struct {
 	BranchName string
 	Token      string
 	BranchRef  string
+	Env        map[string]string // environment variables of the build steps
+	OnLine     LogFunc           // can be nil
+	Timeout    time.Duration     // maximum duration of the build on each endpoint, 0 for no limit
 }
 
 type BuildMetadata struct {
@@ -0,0 +1,71 @@
+// Package api contains the types of the orchestrator's REST API, shared by the orchestrator
+// and its clients.
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunRequest starts a pipeline run manually. the commit that is built is selected by SHA,
+// then by Ref, and then by the head of Branch. Branch defaults to the branch configured in the provider.
+type RunRequest struct {
+	Branch string   `json:"branch,omitempty"`
+	Ref    string   `json:"ref,omitempty"` // branch or tag ref, e.g. refs/heads/main or refs/tags/v1.0.0
+	SHA    string   `json:"sha,omitempty"` // full 40 character commit SHA
+	Tasks  []string `json:"tasks,omitempty"`
+}
+
+// RunResponse is returned when a run is accepted.
+type RunResponse struct {
+	RunID uuid.UUID `json:"run_id"`
+}
+
+// LogLine is a single line of output of a run, sent as a "log" event by the run's log stream.
+type LogLine struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"` // "build", or the name of the task
+	Worker string    `json:"worker"`
+	Line   string    `json:"line"`
+}
+
+// LogEnd is sent as the "end" event of a run's log stream, once the run finished.
+type LogEnd struct {
+	State string `json:"state"`
+}
+
+// Worker is a host the pipeline runs on.
+type Worker struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Online  bool   `json:"online"`
+	Error   string `json:"error,omitempty"` // why the worker isn't reachable
+}
+
+// Plan is what a run would execute, the files of every task are resolved and its commands
+// are expanded, but nothing is executed.
+type Plan struct {
+	Build PlanBuild  `json:"build"`
+	Tasks []PlanTask `json:"tasks"` // in the order they are defined in the pipeline
+}
+
+// PlanBuild is the build a run would execute on every host.
+type PlanBuild struct {
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts"`
+	Steps []string `json:"steps"`
+}
+
+// PlanTask is a task a run would execute, its commands are distributed across its hosts
+// as they become free, so the host a single command runs on isn't known in advance.
+type PlanTask struct {
+	Name      string   `json:"name"`
+	Run       bool     `json:"run"`
+	Reason    string   `json:"reason,omitempty"` // why the task would be skipped
+	DependsOn []string `json:"depends_on,omitempty"`
+	Hosts     []string `json:"hosts"`
+	Files     []string `json:"files,omitempty"`
+	Commands  []string `json:"commands,omitempty"`
+	Error     string   `json:"error,omitempty"` // why the files of the task couldn't be resolved
+}
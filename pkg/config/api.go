@@ -0,0 +1,21 @@
+package config
+
+import "strings"
+
+// GetAPIToken returns the token clients of the orchestrator's API authenticate with,
+// an empty string means no token was configured.
+func (cfg *Config) GetAPIToken() string {
+	if cfg.API == nil {
+		return ""
+	}
+	return cfg.API.Token
+}
+
+// GetPublicURL returns the url the orchestrator's API is reachable at, without a trailing slash.
+// an empty string means no url was configured.
+func (cfg *Config) GetPublicURL() string {
+	if cfg.API == nil {
+		return ""
+	}
+	return strings.TrimSuffix(cfg.API.PublicURL, "/")
+}
@@ -24,7 +24,19 @@ func GetConfig(filename string) (*ValidatedConfig, error) {
 // NewConfig creates a new validated Config instance from a YAML file,
 // the function also expands environment variables for the Enviornmet
 // field and the auth field in the github provider.
+// if the config is invalid, a ValidationReport with every problem found is returned.
 func NewConfig(filename string) (*ValidatedConfig, error) {
+	return newConfig(filename, false)
+}
+
+// NewLocalConfig creates a new validated Config instance from a YAML file for a local run, like NewConfig.
+// the secrets only the orchestrator's server uses, the webhook secret and the API token, are dropped
+// without being expanded, so a local run dosen't need their environment variables.
+func NewLocalConfig(filename string) (*ValidatedConfig, error) {
+	return newConfig(filename, true)
+}
+
+func newConfig(filename string, local bool) (*ValidatedConfig, error) {
 	cfg := &Config{}
 
 	b, err := os.ReadFile(filename)
@@ -36,6 +48,15 @@ func NewConfig(filename string) (*ValidatedConfig, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't parse config file, make sure the config file has valid yaml format and required fields exist.")
 	}
+	// the yaml node tree is used to report the line and column of invalid fields.
+	var root yaml.Node
+	err = yaml.Unmarshal(b, &root)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't parse config file, make sure the config file has valid yaml format and required fields exist.")
+	}
+	if local {
+		cfg.dropServerSecrets()
+	}
 	logger.Println("Config file parsed successfully, expanding env...")
 	err = cfg.expandEnv()
 	if err != nil {
@@ -43,8 +64,10 @@ func NewConfig(filename string) (*ValidatedConfig, error) {
 	}
 
 	logger.Println("Expanded env, validating config fields...")
-	cfg.ValidatePipeline()
-	cfg.ValidateProvider()
+	err = cfg.Validate(&root)
+	if err != nil {
+		return nil, err
+	}
 	eps, err := cfg.ValidateParseHosts()
 	if err != nil {
 		return nil, err
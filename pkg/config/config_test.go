@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -39,3 +43,59 @@ func TestNewConfig(t *testing.T) {
 		t.Errorf("Expected 2 hosts, got %d", len(config.Hosts))
 	}
 }
+
+func TestNewLocalConfig(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test-token-123")
+	t.Setenv("CONFLOW_TEST_DIR", "testdata")
+	b, err := os.ReadFile(filepath.Join("testdata", "test-config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server's secrets reference environment variables that aren't set.
+	yml := strings.Replace(string(b), "  github:\n", "  github:\n    webhook_secret: ${CONFLOW_UNSET_WEBHOOK_SECRET}\n", 1)
+	yml += "\napi:\n  token: ${CONFLOW_UNSET_API_TOKEN}\n"
+	yamlPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(yamlPath, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewConfig(yamlPath); err == nil {
+		t.Errorf("Expected the server config to require its secrets")
+	}
+	cfg, err := NewLocalConfig(yamlPath)
+	if err != nil {
+		t.Fatalf("Failed to load local config: %v", err)
+	}
+	if cfg.GetWebhookSecret() != "" || cfg.GetAPIToken() != "" {
+		t.Errorf("Expected the server secrets to be dropped, got %q and %q", cfg.GetWebhookSecret(), cfg.GetAPIToken())
+	}
+	if cfg.Provider.Github.Auth.Token != "test-token-123" {
+		t.Errorf("Expected token 'test-token-123', got '%s'", cfg.Provider.Github.Auth.Token)
+	}
+}
+
+func TestTriggerParsing(t *testing.T) {
+	// "on" is a boolean in yaml 1.1, make sure it's parsed as the trigger key.
+	b := []byte(`
+on:
+  events: [pull_request, push]
+  branches: ["main", "release/*"]
+tasks:
+  - name: release
+    on:
+      tags: ["v*"]
+      paths: ["cmd/**"]
+`)
+	var pipeline Pipeline
+	if err := yaml.Unmarshal(b, &pipeline); err != nil {
+		t.Fatalf("Failed to parse pipeline: %v", err)
+	}
+	expected := &Trigger{Events: []string{"pull_request", "push"}, Branches: []string{"main", "release/*"}}
+	if !reflect.DeepEqual(pipeline.On, expected) {
+		t.Errorf("Expected pipeline trigger: %v, got: %v", expected, pipeline.On)
+	}
+	expected = &Trigger{Tags: []string{"v*"}, Paths: []string{"cmd/**"}}
+	if len(pipeline.Tasks) != 1 || !reflect.DeepEqual(pipeline.Tasks[0].On, expected) {
+		t.Errorf("Expected task trigger: %v, got: %v", expected, pipeline.Tasks)
+	}
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
 )
 
 // ExpandEnv expands environment variables in the config.
@@ -18,6 +21,29 @@ func (cfg *Config) expandEnv() error {
 		}
 		cfg.Provider.Github.Auth.Token = expandedVar
 	}
+	if cfg.Provider.Github.WebhookSecret != "" {
+		expandedVar := os.ExpandEnv(cfg.Provider.Github.WebhookSecret)
+		if len(expandedVar) <= 0 {
+			return fmt.Errorf("Environment variable for Github webhook secret dosen't exist")
+		}
+		cfg.Provider.Github.WebhookSecret = expandedVar
+	}
+	if cfg.API != nil && cfg.API.Token != "" {
+		expandedVar := os.ExpandEnv(cfg.API.Token)
+		if len(expandedVar) <= 0 {
+			return fmt.Errorf("Environment variable for API token dosen't exist")
+		}
+		cfg.API.Token = expandedVar
+	}
+
+	if err := expandEnvMap(cfg.Pipeline.Build.Env, "build"); err != nil {
+		return err
+	}
+	for _, task := range cfg.Pipeline.Tasks {
+		if err := expandEnvMap(task.Env, "task "+task.Name); err != nil {
+			return err
+		}
+	}
 
 	if cfg.Env == nil {
 		return nil
@@ -46,3 +72,85 @@ func (cfg *Config) expandEnv() error {
 	}
 	return nil
 }
+
+// dropServerSecrets removes the secrets only the orchestrator's server uses, since local runs
+// don't receive webhooks or serve the API.
+func (cfg *Config) dropServerSecrets() {
+	cfg.Provider.Github.WebhookSecret = ""
+	if cfg.API != nil {
+		cfg.API.Token = ""
+	}
+}
+
+// expandEnvMap expands the environment variables in the values of env, owner is used in the error.
+func expandEnvMap(env map[string]string, owner string) error {
+	for key, val := range env {
+		expandedVar := os.ExpandEnv(val)
+		if len(expandedVar) <= 0 {
+			return fmt.Errorf("%s environment variable for key %v dosen't exist", owner, key)
+		}
+		env[key] = expandedVar
+	}
+	return nil
+}
+
+// GetGlobalEnv returns the global environment variables, shared across hosts.
+func (cfg *Config) GetGlobalEnv() map[string]string {
+	return cfg.mergeGlobalEnv(nil)
+}
+
+// BuildEnv returns the environment variables of the build steps, the global variables
+// overridden by the build's env.
+func (cfg *Config) BuildEnv() map[string]string {
+	return cfg.mergeGlobalEnv(cfg.Pipeline.Build.Env)
+}
+
+// TaskEnv returns the environment variables of the task's commands, the global variables
+// overridden by the task's env.
+func (cfg *Config) TaskEnv(task TaskConsumerJobs) map[string]string {
+	return cfg.mergeGlobalEnv(task.Env)
+}
+
+func (cfg *Config) mergeGlobalEnv(overrides map[string]string) map[string]string {
+	env := map[string]string{}
+	if cfg.Env != nil {
+		maps.Copy(env, cfg.Env.GlobalEnv)
+	}
+	maps.Copy(env, overrides)
+	return env
+}
+
+// ApplyLocalEnv sets the local environment variables in the environment of the current process,
+// so they apply to everything the orchestrator runs.
+func (cfg *Config) ApplyLocalEnv() error {
+	if cfg.Env == nil {
+		return nil
+	}
+	for key, val := range cfg.Env.LocalEnv {
+		if err := os.Setenv(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var envNamePattern = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// validateEnv validates the names of the environment variables.
+func (cfg *Config) validateEnv(v *validator) {
+	validateEnvNames := func(env map[string]string, path ...any) {
+		for _, key := range slices.Sorted(maps.Keys(env)) {
+			if !envNamePattern.MatchString(key) {
+				v.add(ErrInvalidEnvName{Name: key}, append(path, key)...)
+			}
+		}
+	}
+	if cfg.Env != nil {
+		validateEnvNames(cfg.Env.GlobalEnv, "environment", "global")
+		validateEnvNames(cfg.Env.LocalEnv, "environment", "local")
+	}
+	validateEnvNames(cfg.Pipeline.Build.Env, "pipeline", "build", "env")
+	for i, task := range cfg.Pipeline.Tasks {
+		validateEnvNames(task.Env, "pipeline", "tasks", i, "env")
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"maps"
 	"os"
 	"testing"
 )
@@ -31,3 +32,69 @@ func TestExpandEnv(t *testing.T) {
 	}
 
 }
+
+func TestExpandTaskEnv(t *testing.T) {
+	t.Setenv("CONFLOW_TEST_FLAGS", "-race")
+	cfg := &Config{Pipeline: Pipeline{
+		Build: BuildTaskProducer{Env: map[string]string{"CGO_ENABLED": "1"}},
+		Tasks: []TaskConsumerJobs{{Name: "test", Env: map[string]string{"FLAGS": "${CONFLOW_TEST_FLAGS}"}}},
+	}}
+	if err := cfg.expandEnv(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Pipeline.Tasks[0].Env["FLAGS"] != "-race" {
+		t.Errorf("expected FLAGS to be -race, got %s", cfg.Pipeline.Tasks[0].Env["FLAGS"])
+	}
+
+	cfg.Pipeline.Tasks[0].Env["MISSING"] = "${CONFLOW_TEST_MISSING}"
+	if err := cfg.expandEnv(); err == nil {
+		t.Errorf("expected an error for a missing environment variable")
+	}
+}
+
+func TestMergedEnv(t *testing.T) {
+	cfg := &Config{
+		Env: &Environment{GlobalEnv: map[string]string{"A": "global", "B": "global"}},
+		Pipeline: Pipeline{
+			Build: BuildTaskProducer{Env: map[string]string{"B": "build"}},
+		},
+	}
+	task := TaskConsumerJobs{Env: map[string]string{"A": "task", "C": "task"}}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected map[string]string
+	}{
+		{name: "build", env: cfg.BuildEnv(), expected: map[string]string{"A": "global", "B": "build"}},
+		{name: "task", env: cfg.TaskEnv(task), expected: map[string]string{"A": "task", "B": "global", "C": "task"}},
+		{name: "no global env", env: (&Config{}).TaskEnv(task), expected: map[string]string{"A": "task", "C": "task"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !maps.Equal(tt.env, tt.expected) {
+				t.Errorf("expected env %v, got %v", tt.expected, tt.env)
+			}
+		})
+	}
+	if len(cfg.Env.GlobalEnv) != 2 || cfg.Env.GlobalEnv["A"] != "global" {
+		t.Errorf("expected the global env to not be modified, got %v", cfg.Env.GlobalEnv)
+	}
+}
+
+func TestValidateEnvNames(t *testing.T) {
+	cfg := &Config{
+		Env: &Environment{GlobalEnv: map[string]string{"GOOD_NAME": "x", "1BAD": "x"}},
+		Pipeline: Pipeline{
+			Tasks: []TaskConsumerJobs{{Name: "test", Env: map[string]string{"BAD-NAME": "x"}}},
+		},
+	}
+	v := newValidator(nil)
+	cfg.validateEnv(v)
+	if len(v.errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", v.errs)
+	}
+	if v.errs[0].Field != "environment.global.1BAD" || v.errs[1].Err != (ErrInvalidEnvName{Name: "BAD-NAME"}) {
+		t.Errorf("unexpected errors: %v", v.errs)
+	}
+}
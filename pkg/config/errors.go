@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type InvalidAddressFormat struct {
@@ -49,3 +50,130 @@ type ErrFileStrategyConflict struct {
 func (e ErrFileStrategyConflict) Error() string {
 	return fmt.Sprintf("File strategy conflict in task %s. either explictly specify files or a pattern.", e.TaskName)
 }
+
+type ErrUnknownTriggerEvent struct {
+	Event string
+}
+
+func (e ErrUnknownTriggerEvent) Error() string {
+	return fmt.Sprintf("Unknown trigger event %s, supported events are: %s", e.Event, strings.Join(TriggerEvents, ", "))
+}
+
+type ErrDuplicateHostName struct {
+	Name string
+}
+
+func (e ErrDuplicateHostName) Error() string {
+	return fmt.Sprintf("Host name %s is used by more than one host", e.Name)
+}
+
+type ErrUnknownExecutor struct {
+	Host     string
+	Executor string
+}
+
+func (e ErrUnknownExecutor) Error() string {
+	return fmt.Sprintf("Unknown executor %s of host %s, supported executors are: %s, %s", e.Executor, e.Host,
+		WorkerExecutor, SSHExecutor)
+}
+
+type ErrNoSSHAccess struct {
+	Host string
+}
+
+func (e ErrNoSSHAccess) Error() string {
+	return fmt.Sprintf("Host %s uses the ssh executor, but has no ssh access configured", e.Host)
+}
+
+type ErrDuplicateTaskName struct {
+	Name string
+}
+
+func (e ErrDuplicateTaskName) Error() string {
+	return fmt.Sprintf("Task name %s is used by more than one task", e.Name)
+}
+
+type ErrUnknownHost struct {
+	TaskName string
+	Host     string
+}
+
+func (e ErrUnknownHost) Error() string {
+	return fmt.Sprintf("Task %s runs on host %s, which is not defined in hosts", e.TaskName, e.Host)
+}
+
+type ErrUnknownDependency struct {
+	TaskName   string
+	Dependency string
+}
+
+func (e ErrUnknownDependency) Error() string {
+	return fmt.Sprintf("Task %s depends on task %s, which is not defined in the pipeline", e.TaskName, e.Dependency)
+}
+
+type ErrDependencyCycle struct {
+	Cycle []string // task names, the first task is repeated at the end
+}
+
+func (e ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("Dependency cycle between tasks: %s", strings.Join(e.Cycle, " -> "))
+}
+
+type ErrInvalidEnvName struct {
+	Name string
+}
+
+func (e ErrInvalidEnvName) Error() string {
+	return fmt.Sprintf("Invalid environment variable name %s, names can only contain letters, digits and '_' and can't start with a digit", e.Name)
+}
+
+type ErrInvalidTimeout struct {
+	Timeout string
+}
+
+func (e ErrInvalidTimeout) Error() string {
+	return fmt.Sprintf("Invalid timeout %s, expected a positive duration like 30s, 10m or 1h30m", e.Timeout)
+}
+
+// ValidationError is a single problem found while validating the config, positioned
+// at the yaml node of the field that caused it.
+type ValidationError struct {
+	Field  string // path to the field, e.g. pipeline.tasks[0].runs_on[1]
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e ValidationError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.Field, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %v", e.Line, e.Column, e.Field, e.Err)
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationReport holds every problem found while validating the config,
+// so all of them can be fixed at once instead of one at a time.
+type ValidationReport struct {
+	Errors []ValidationError
+}
+
+func (r ValidationReport) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Invalid config, found %d problem(s):", len(r.Errors))
+	for _, e := range r.Errors {
+		fmt.Fprintf(&b, "\n  - %s", e.Error())
+	}
+	return b.String()
+}
+
+func (r ValidationReport) Unwrap() []error {
+	errs := make([]error, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, e)
+	}
+	return errs
+}
@@ -1,11 +1,24 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 )
 
+// DefaultSSHPort is the ssh port of hosts that don't set one.
+const DefaultSSHPort = 22
+
+const (
+	// WorkerExecutor hosts run a worker daemon, builds and tasks are executed through its gRPC services.
+	WorkerExecutor = "worker"
+	// SSHExecutor hosts are agentless, builds and task commands are executed over ssh sessions.
+	SSHExecutor = "ssh"
+)
+
 func (cfg *Config) ValidateParseHosts() ([]EndpointInfo, error) {
 	endpoints := []EndpointInfo{}
 	for _, host := range cfg.Hosts {
@@ -14,11 +27,19 @@ func (cfg *Config) ValidateParseHosts() ([]EndpointInfo, error) {
 		if err != nil {
 			return []EndpointInfo{}, err
 		}
+		ep.setSSH(host.SSH)
+		ep.Executor = host.GetExecutor()
 
 		err = ValidateEndpoint(ep)
 		if err != nil {
 			return []EndpointInfo{}, err
 		}
+		if _, err := validateSSH(host.SSH); err != nil {
+			return []EndpointInfo{}, err
+		}
+		if err := validateExecutor(host); err != nil {
+			return []EndpointInfo{}, err
+		}
 		endpoints = append(endpoints, ep)
 	}
 	return endpoints, nil
@@ -44,6 +65,66 @@ func parseHost(host string) (EndpointInfo, error) {
 	return ep, nil
 }
 
+// setSSH sets the ssh access of the endpoint, the ssh port defaults to DefaultSSHPort.
+func (ep *EndpointInfo) setSSH(s *SSHHost) {
+	if s == nil {
+		return
+	}
+	ep.User = s.User
+	ep.PrivateKeyPath = os.ExpandEnv(s.PrivateKey)
+	ep.SSHPort = s.Port
+	if ep.SSHPort == 0 {
+		ep.SSHPort = DefaultSSHPort
+	}
+}
+
+// validateSSH validates the ssh access of a host, if it has one.
+func validateSSH(s *SSHHost) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	if s.User == "" {
+		return "user", ErrInvalidUser
+	}
+	if s.PrivateKey == "" {
+		return "private_key", ErrInvalidPrivateKeyPath
+	}
+	return "", nil
+}
+
+// GetExecutor returns the executor of the host, WorkerExecutor if it isn't set.
+func (h Host) GetExecutor() string {
+	if h.Executor == "" {
+		return WorkerExecutor
+	}
+	return h.Executor
+}
+
+// validateExecutor validates the executor of the host is known, ssh hosts need ssh access.
+func validateExecutor(h Host) error {
+	switch h.GetExecutor() {
+	case WorkerExecutor:
+		return nil
+	case SSHExecutor:
+		if h.SSH == nil {
+			return ErrNoSSHAccess{Host: h.Name}
+		}
+		return nil
+	default:
+		return ErrUnknownExecutor{Host: h.Name, Executor: h.Executor}
+	}
+}
+
+// IsSSH reports if builds and tasks are executed on the endpoint over ssh, instead of by a worker.
+func (ep EndpointInfo) IsSSH() bool {
+	return ep.Executor == SSHExecutor
+}
+
+// GetSSHURL returns the address of the endpoint's ssh server.
+func (ep EndpointInfo) GetSSHURL() string {
+	return fmt.Sprintf("%s:%d", ep.Host, ep.SSHPort)
+}
+
 // Validates the configuration for the endpoint.
 func ValidateEndpoint(ep EndpointInfo) error {
 	if ep.Host == "" {
@@ -61,3 +142,17 @@ func (ep EndpointInfo) GetEndpointURL() string {
 	}
 	return fmt.Sprintf("%s:%d", ep.Host, ep.Port)
 }
+
+// GetInstallSteps returns the install steps of the host, nil if the host has none.
+func (h Host) GetInstallSteps() []string {
+	if h.InstallSteps == nil {
+		return nil
+	}
+	return *h.InstallSteps
+}
+
+// InstallFingerprint identifies the install steps of the host, it changes when the steps change.
+func (h Host) InstallFingerprint() string {
+	sum := sha256.Sum256([]byte(strings.Join(h.GetInstallSteps(), "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -90,3 +90,50 @@ func TestParseHost(t *testing.T) {
 		})
 	}
 }
+
+func TestHostSSH(t *testing.T) {
+	tests := []struct {
+		name     string
+		ssh      *SSHHost
+		executor string
+		expected EndpointInfo
+		wantErr  error
+	}{
+		{
+			name:     "without ssh",
+			expected: EndpointInfo{Name: "node", Host: "host", Port: 8918, Executor: WorkerExecutor},
+		},
+		{
+			name:     "default ssh port",
+			ssh:      &SSHHost{User: "ci", PrivateKey: "/keys/id_rsa"},
+			expected: EndpointInfo{Name: "node", Host: "host", Port: 8918, User: "ci", PrivateKeyPath: "/keys/id_rsa", SSHPort: 22, Executor: WorkerExecutor},
+		},
+		{
+			name:     "ssh port",
+			ssh:      &SSHHost{User: "ci", Port: 2222, PrivateKey: "/keys/id_rsa"},
+			expected: EndpointInfo{Name: "node", Host: "host", Port: 8918, User: "ci", PrivateKeyPath: "/keys/id_rsa", SSHPort: 2222, Executor: WorkerExecutor},
+		},
+		{
+			name:     "ssh executor",
+			ssh:      &SSHHost{User: "ci", PrivateKey: "/keys/id_rsa"},
+			executor: SSHExecutor,
+			expected: EndpointInfo{Name: "node", Host: "host", Port: 8918, User: "ci", PrivateKeyPath: "/keys/id_rsa", SSHPort: 22, Executor: SSHExecutor},
+		},
+		{name: "ssh executor without ssh", executor: SSHExecutor, wantErr: ErrNoSSHAccess{Host: "node"}},
+		{name: "unknown executor", executor: "docker", wantErr: ErrUnknownExecutor{Host: "node", Executor: "docker"}},
+		{name: "missing user", ssh: &SSHHost{PrivateKey: "/keys/id_rsa"}, wantErr: ErrInvalidUser},
+		{name: "missing private key", ssh: &SSHHost{User: "ci"}, wantErr: ErrInvalidPrivateKeyPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Hosts: []Host{{Name: "node", Address: "host:8918", SSH: tt.ssh, Executor: tt.executor}}}
+			eps, err := cfg.ValidateParseHosts()
+			if err != tt.wantErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(eps[0], tt.expected) {
+				t.Errorf("Expected endpoint: %v, got: %v", tt.expected, eps[0])
+			}
+		})
+	}
+}
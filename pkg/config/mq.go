@@ -0,0 +1,6 @@
+package config
+
+// DurableQueues returns if the message queue is durable, see MessageQueue.
+func (cfg *Config) DurableQueues() bool {
+	return cfg.MessageQueue != nil && cfg.MessageQueue.Durable
+}
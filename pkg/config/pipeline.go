@@ -1,6 +1,19 @@
 package config
 
+import "slices"
+
+// TriggerEvents are the github events a pipeline can be triggered by.
+var TriggerEvents = []string{"pull_request", "push"}
+
+// ValidatePipeline validates the pipeline fields and returns the first error found.
+// use Validate to get all of the errors in the config.
 func (cfg *Config) ValidatePipeline() error {
+	v := newValidator(nil)
+	cfg.validatePipeline(v)
+	return v.first()
+}
+
+func (cfg *Config) validatePipeline(v *validator) {
 	for i, task := range cfg.Pipeline.Tasks {
 		// set RunInParallel to true by default
 		if task.RunsInParallel == nil {
@@ -10,40 +23,53 @@ func (cfg *Config) ValidatePipeline() error {
 	}
 
 	pipeline := cfg.Pipeline
+	validateTrigger(v, pipeline.On, "pipeline", "on")
 	if pipeline.Build.Name == "" {
-		return ErrEmptyBuildName
+		v.add(ErrEmptyBuildName, "pipeline", "build", "name")
 	}
 	// Test build exist, as we use it for running
 	if len(pipeline.Build.BuildSteps) == 0 {
-		return ErrEmptyBuildSteps
+		v.add(ErrEmptyBuildSteps, "pipeline", "build", "steps")
 	}
 
 	tasks := pipeline.Tasks
 	if len(tasks) == 0 {
-		return ErrNoTasksSpecified
+		v.add(ErrNoTasksSpecified, "pipeline", "tasks")
+		return
 	}
 
-	for _, task := range tasks {
+	for i, task := range tasks {
 		if task.Name == "" {
-			return ErrNoTaskNameSpecified
+			v.add(ErrNoTaskNameSpecified, "pipeline", "tasks", i, "name")
 		}
 		if len(task.RunsOn) == 0 {
-			return ErrNoTaskRunsOnSpecified{TaskName: task.Name}
+			v.add(ErrNoTaskRunsOnSpecified{TaskName: task.Name}, "pipeline", "tasks", i, "runs_on")
 		}
 
 		if len(task.Commands) == 0 {
-			return ErrNoCmdsSpecified{TaskName: task.Name}
+			v.add(ErrNoCmdsSpecified{TaskName: task.Name}, "pipeline", "tasks", i, "cmd")
 		}
 
 		pattern := task.Pattern
 		files := task.File
 
 		if pattern == "" && len(files) == 0 {
-			return ErrNoFileStrategySpecified
+			v.add(ErrNoFileStrategySpecified, "pipeline", "tasks", i)
 		}
 		if pattern != "" && len(files) > 0 {
-			return ErrFileStrategyConflict{TaskName: task.Name}
+			v.add(ErrFileStrategyConflict{TaskName: task.Name}, "pipeline", "tasks", i, "pattern")
+		}
+		validateTrigger(v, task.On, "pipeline", "tasks", i, "on")
+	}
+}
+
+func validateTrigger(v *validator, trigger *Trigger, path ...any) {
+	if trigger == nil {
+		return
+	}
+	for i, event := range trigger.Events {
+		if !slices.Contains(TriggerEvents, event) {
+			v.add(ErrUnknownTriggerEvent{Event: event}, append(path, "events", i)...)
 		}
 	}
-	return nil
 }
@@ -159,6 +159,27 @@ func TestValidatePipeline(t *testing.T) {
 			},
 			wantErr: ErrNoTasksSpecified,
 		},
+		{
+			name: "invalid-pipeline-unknown-trigger-event",
+			cfg: Config{
+				Pipeline: Pipeline{
+					On: &Trigger{Events: []string{"push", "issues"}},
+					Build: BuildTaskProducer{
+						Name:       "build-task",
+						BuildSteps: []string{"step1", "step2"},
+					},
+					Tasks: []TaskConsumerJobs{
+						{
+							Name:     "task1",
+							RunsOn:   []string{"host1", "host2"},
+							Commands: []string{"cmd1", "cmd2", "cmd3 -v"},
+							Pattern:  "pattern",
+						},
+					},
+				},
+			},
+			wantErr: ErrUnknownTriggerEvent{Event: "issues"},
+		},
 		{
 			name: "invalid-pipeline-no-build-name",
 			cfg: Config{
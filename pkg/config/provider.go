@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var ErrInvalidPersonalAccessToken = errors.New("Empty Personal Access Token")
@@ -25,18 +26,39 @@ func (cfg *Config) GetToken() string {
 	return cfg.Provider.Github.Auth.Token
 }
 
-// Validates the configuration for the provider.
+const DefaultGithubAPIURL = "https://api.github.com"
+
+// GetAPIURL returns the base url of the github REST API.
+func (cfg *Config) GetAPIURL() string {
+	if cfg.Provider.Github.APIURL == "" {
+		return DefaultGithubAPIURL
+	}
+	return strings.TrimSuffix(cfg.Provider.Github.APIURL, "/")
+}
+
+// GetWebhookSecret returns the secret github uses to sign webhook deliveries,
+// an empty string means no secret was configured.
+func (cfg *Config) GetWebhookSecret() string {
+	return cfg.Provider.Github.WebhookSecret
+}
+
+// Validates the configuration for the provider, returns the first error found.
 func (cfg *Config) ValidateProvider() error {
+	v := newValidator(nil)
+	cfg.validateProvider(v)
+	return v.first()
+}
+
+func (cfg *Config) validateProvider(v *validator) {
 	if len(cfg.Provider.Github.Repository) <= 0 {
-		return ErrInvalidRepoName
+		v.add(ErrInvalidRepoName, "provider", "github", "repository")
 	}
 	if len(cfg.Provider.Github.Branch) <= 0 {
-		return ErrInvalidBranchName
+		v.add(ErrInvalidBranchName, "provider", "github", "branch")
 	}
 	if cfg.Provider.Github.Auth != nil {
 		if len(cfg.Provider.Github.Auth.Token) <= 0 {
-			return ErrInvalidPersonalAccessToken
+			v.add(ErrInvalidPersonalAccessToken, "provider", "github", "auth", "token")
 		}
 	}
-	return nil
 }
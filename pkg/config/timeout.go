@@ -0,0 +1,58 @@
+package config
+
+import "time"
+
+// parseTimeout parses a timeout in the format of time.ParseDuration, e.g. 30s or 1h30m.
+// an empty timeout means no limit and is parsed as 0.
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidTimeout{Timeout: s}
+	}
+	return d, nil
+}
+
+// GetTimeout returns the maximum duration of a run, 0 if there is no limit.
+func (p Pipeline) GetTimeout() time.Duration {
+	d, _ := parseTimeout(p.Timeout)
+	return d
+}
+
+// GetTimeout returns the maximum duration of the build steps on a host, 0 if there is no limit.
+func (b BuildTaskProducer) GetTimeout() time.Duration {
+	d, _ := parseTimeout(b.Timeout)
+	return d
+}
+
+// GetTimeout returns the maximum duration of the task, 0 if there is no limit.
+func (t TaskConsumerJobs) GetTimeout() time.Duration {
+	d, _ := parseTimeout(t.Timeout)
+	return d
+}
+
+// GetCommandTimeout returns the maximum duration of each command of the task, 0 if there is no limit.
+func (t TaskConsumerJobs) GetCommandTimeout() time.Duration {
+	d, _ := parseTimeout(t.CommandTimeout)
+	return d
+}
+
+// validateTimeouts validates the timeouts of the pipeline, the build and the tasks are positive durations.
+func (cfg *Config) validateTimeouts(v *validator) {
+	if _, err := parseTimeout(cfg.Pipeline.Timeout); err != nil {
+		v.add(err, "pipeline", "timeout")
+	}
+	if _, err := parseTimeout(cfg.Pipeline.Build.Timeout); err != nil {
+		v.add(err, "pipeline", "build", "timeout")
+	}
+	for i, task := range cfg.Pipeline.Tasks {
+		if _, err := parseTimeout(task.Timeout); err != nil {
+			v.add(err, "pipeline", "tasks", i, "timeout")
+		}
+		if _, err := parseTimeout(task.CommandTimeout); err != nil {
+			v.add(err, "pipeline", "tasks", i, "command_timeout")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		timeout  string
+		expected time.Duration
+		err      error
+	}{
+		{timeout: "", expected: 0},
+		{timeout: "30s", expected: 30 * time.Second},
+		{timeout: "1h30m", expected: 90 * time.Minute},
+		{timeout: "0s", err: ErrInvalidTimeout{Timeout: "0s"}},
+		{timeout: "-1m", err: ErrInvalidTimeout{Timeout: "-1m"}},
+		{timeout: "10", err: ErrInvalidTimeout{Timeout: "10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timeout, func(t *testing.T) {
+			d, err := parseTimeout(tt.timeout)
+			if err != tt.err || d != tt.expected {
+				t.Errorf("expected %s and error %v, got %s and %v", tt.expected, tt.err, d, err)
+			}
+		})
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+	cfg := &Config{
+		Pipeline: Pipeline{
+			Timeout: "1h",
+			Build:   BuildTaskProducer{Timeout: "soon"},
+			Tasks: []TaskConsumerJobs{
+				{Name: "test", Timeout: "10m", CommandTimeout: "0s"},
+			},
+		},
+	}
+	v := newValidator(nil)
+	cfg.validateTimeouts(v)
+	if len(v.errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", v.errs)
+	}
+	if v.errs[0].Field != "pipeline.build.timeout" || v.errs[1].Field != "pipeline.tasks[0].command_timeout" {
+		t.Errorf("unexpected errors: %v", v.errs)
+	}
+	if cfg.Pipeline.GetTimeout() != time.Hour || cfg.Pipeline.Tasks[0].GetTimeout() != 10*time.Minute {
+		t.Errorf("unexpected timeouts %s and %s", cfg.Pipeline.GetTimeout(), cfg.Pipeline.Tasks[0].GetTimeout())
+	}
+}
@@ -14,6 +14,24 @@ type Config struct {
 	Env      *Environment `yaml:"environment,omitempty"` // enviorment variables shared across hosts
 	Hosts    []Host       `yaml:"hosts"`                 // pool of available machines/servers
 	Pipeline Pipeline     `yaml:"pipeline"`              // task pipeline
+
+	MessageQueue *MessageQueue `yaml:"message_queue,omitempty"` // how commands and results are queued
+	API          *API          `yaml:"api,omitempty"`           // orchestrator REST API
+}
+
+// API configures the orchestrator's REST API.
+type API struct {
+	// token clients send as a bearer token, the runs API rejects every request without it.
+	Token string `yaml:"token"`
+	// url the API is reachable at, commit statuses link to the logs of their run under it. optional.
+	PublicURL string `yaml:"public_url,omitempty"`
+}
+
+// MessageQueue configures the message queue commands and their results are sent through.
+type MessageQueue struct {
+	// the exchange and queues survive restarts of the broker and messages are persistent,
+	// unfinished runs are resumed once the orchestrator restarts. false by default.
+	Durable bool `yaml:"durable,omitempty"`
 }
 
 type Provider struct {
@@ -21,9 +39,11 @@ type Provider struct {
 }
 
 type Github struct {
-	Repository string `yaml:"repository"`     // repository name in the format: user/repo
-	Branch     string `yaml:"branch"`         // on what branch to build on
-	Auth       *Auth  `yaml:"auth,omitempty"` // PAT Token
+	Repository    string `yaml:"repository"`               // repository name in the format: user/repo
+	Branch        string `yaml:"branch"`                   // on what branch to build on
+	Auth          *Auth  `yaml:"auth,omitempty"`           // PAT Token
+	WebhookSecret string `yaml:"webhook_secret,omitempty"` // secret used by github to sign webhook deliveries
+	APIURL        string `yaml:"api_url,omitempty"`        // github REST API base url, defaults to https://api.github.com
 }
 
 type Auth struct {
@@ -36,28 +56,54 @@ type Environment struct {
 }
 
 type Host struct {
-	Name         string    `yaml:"name"`              // host human readable name
-	Address      string    `yaml:"address"`           // local/public accesible address
-	InstallSteps *[]string `yaml:"install,omitempty"` // Bootstraping host machine
+	Name         string    `yaml:"name"`               // host human readable name
+	Address      string    `yaml:"address"`            // local/public accesible address
+	InstallSteps *[]string `yaml:"install,omitempty"`  // Bootstraping host machine
+	SSH          *SSHHost  `yaml:"ssh,omitempty"`      // ssh access to the host, used to provision the worker and by the ssh executor
+	Executor     string    `yaml:"executor,omitempty"` // how builds and tasks run on the host: worker (default) or ssh
+}
+
+type SSHHost struct {
+	User       string `yaml:"user"`           // user to ssh as
+	Port       uint16 `yaml:"port,omitempty"` // ssh port, 22 by default
+	PrivateKey string `yaml:"private_key"`    // path to the private key the host authenticates against
 }
 
 type Pipeline struct {
-	Build BuildTaskProducer  `yaml:"build"` // build instructions after cloning the repository
-	Tasks []TaskConsumerJobs `yaml:"tasks"` // jobs for the TaskConsumer to execute, runs in parallel by default.
+	On      *Trigger           `yaml:"on,omitempty"`      // events the pipeline runs on, by default all pull requests and pushes to the provider branch.
+	Timeout string             `yaml:"timeout,omitempty"` // maximum duration of a run, e.g. 1h. no limit by default
+	Build   BuildTaskProducer  `yaml:"build"`             // build instructions after cloning the repository
+	Tasks   []TaskConsumerJobs `yaml:"tasks"`             // jobs for the TaskConsumer to execute, runs in parallel by default.
+}
+
+// Trigger filters the events a pipeline or a task runs on, every filter that is set has to match.
+// branch, tag and path filters are glob patterns, '*' matches any characters except '/'
+// and '**' matches any characters including '/'.
+type Trigger struct {
+	Events   []string `yaml:"events,omitempty"`   // github events, pull_request and push are supported
+	Branches []string `yaml:"branches,omitempty"` // pushed branch, or the target branch of a pull request
+	Tags     []string `yaml:"tags,omitempty"`     // pushed tag
+	Paths    []string `yaml:"paths,omitempty"`    // atleast one changed file has to match
 }
 type BuildTaskProducer struct {
-	Name       string   `yaml:"name"`  // name given for the build task
-	BuildSteps []string `yaml:"steps"` // commands to run, sequentially
+	Name       string            `yaml:"name"`              // name given for the build task
+	BuildSteps []string          `yaml:"steps"`             // commands to run, sequentially
+	Env        map[string]string `yaml:"env,omitempty"`     // environment variables of the steps, overrides environment.global
+	Timeout    string            `yaml:"timeout,omitempty"` // maximum duration of the build steps on a host, no limit by default
 }
 type TaskConsumerJobs struct {
-	Name   string   `yaml:"name"`    // name given to each job
-	RunsOn []string `yaml:"runs_on"` // array of machines the job will run on
+	Name   string   `yaml:"name"`         // name given to each job
+	RunsOn []string `yaml:"runs_on"`      // array of machines the job will run on
+	On     *Trigger `yaml:"on,omitempty"` // events the job runs on, by default every event the pipeline runs on
 
 	// if the job runs in parallel to other tasks, set by default to true. if false, the consumer waits for dependents task to finish.
 	// we use a pointer since we want to default it to true and we need to know if the field was set.
-	RunsInParallel *bool    `yaml:"parallel,omitempty"`
-	Commands       []string `yaml:"cmd"`                  // commands to run
-	DependsOn      []string `yaml:"depends_on,omitempty"` // on what tasks does this job depends on
+	RunsInParallel *bool             `yaml:"parallel,omitempty"`
+	Commands       []string          `yaml:"cmd"`                       // commands to run
+	DependsOn      []string          `yaml:"depends_on,omitempty"`      // on what tasks does this job depends on
+	Env            map[string]string `yaml:"env,omitempty"`             // environment variables of the commands, overrides environment.global
+	Timeout        string            `yaml:"timeout,omitempty"`         // maximum duration of the task, no limit by default
+	CommandTimeout string            `yaml:"command_timeout,omitempty"` // maximum duration of each command, enforced on the hosts
 
 	//Option A: build by regex pattern
 	Pattern string `yaml:"pattern,omitempty"`
@@ -79,4 +125,6 @@ type EndpointInfo struct {
 	// path to private key for SSH authentication, currently only used if nessacery to ssh into
 	// a machine.
 	PrivateKeyPath string
+	SSHPort        uint16 // port of the ssh server, the host's Port is the worker's gRPC port.
+	Executor       string // WorkerExecutor or SSHExecutor
 }
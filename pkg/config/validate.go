@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validator collects validation errors, positioning each one using the yaml node of the
+// field that caused it.
+type validator struct {
+	root *yaml.Node // parsed yaml document, nil if the config wasn't parsed from yaml.
+	errs []ValidationError
+}
+
+func newValidator(root *yaml.Node) *validator {
+	return &validator{root: root}
+}
+
+// add records an error for the field at path, path elements are mapping keys (string)
+// or sequence indexes (int).
+func (v *validator) add(err error, path ...any) {
+	e := ValidationError{Field: formatPath(path), Err: err}
+	if node := lookupNode(v.root, path); node != nil {
+		e.Line = node.Line
+		e.Column = node.Column
+	}
+	v.errs = append(v.errs, e)
+}
+
+// first returns the first error found, or nil if the config is valid.
+func (v *validator) first() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs[0].Err
+}
+
+// report returns a ValidationReport of every error found, or nil if the config is valid.
+func (v *validator) report() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return ValidationReport{Errors: v.errs}
+}
+
+// lookupNode returns the node at path, if part of the path dosen't exist (e.g. a missing field)
+// the deepest node found is returned.
+func lookupNode(root *yaml.Node, path []any) *yaml.Node {
+	if root == nil {
+		return nil
+	}
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, p := range path {
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return node
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+				next = node.Content[key]
+			}
+		}
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+func formatPath(path []any) string {
+	var b strings.Builder
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(key)
+		case int:
+			fmt.Fprintf(&b, "[%d]", key)
+		}
+	}
+	return b.String()
+}
+
+// Validate validates every field of the config and the references between them - hosts used by
+// tasks, dependencies between tasks and unique names, and returns a ValidationReport with
+// all of the problems found.
+// root is the parsed yaml document of the config and is used to position errors, it can be nil.
+func (cfg *Config) Validate(root *yaml.Node) error {
+	v := newValidator(root)
+	cfg.validateProvider(v)
+	cfg.validateHosts(v)
+	cfg.validatePipeline(v)
+	cfg.validateEnv(v)
+	cfg.validateTimeouts(v)
+	cfg.validateReferences(v)
+	return v.report()
+}
+
+func (cfg *Config) validateHosts(v *validator) {
+	seen := map[string]bool{}
+	for i, host := range cfg.Hosts {
+		ep, err := parseHost(host.Address)
+		if err != nil {
+			v.add(err, "hosts", i, "address")
+			continue
+		}
+		ep.Name = host.Name
+		if err := ValidateEndpoint(ep); err != nil {
+			field := "address"
+			if err == ErrInvalidHostName {
+				field = "name"
+			}
+			v.add(err, "hosts", i, field)
+		}
+		if field, err := validateSSH(host.SSH); err != nil {
+			v.add(err, "hosts", i, "ssh", field)
+		}
+		if err := validateExecutor(host); err != nil {
+			v.add(err, "hosts", i, "executor")
+		}
+		if host.Name == "" {
+			continue
+		}
+		if seen[host.Name] {
+			v.add(ErrDuplicateHostName{Name: host.Name}, "hosts", i, "name")
+		}
+		seen[host.Name] = true
+	}
+}
+
+// validateReferences validates the tasks reference hosts and tasks that exist,
+// and that there are no dependency cycles between tasks.
+func (cfg *Config) validateReferences(v *validator) {
+	hosts := map[string]bool{}
+	for _, host := range cfg.Hosts {
+		hosts[host.Name] = true
+	}
+	tasks := map[string]int{}
+	for i, task := range cfg.Pipeline.Tasks {
+		if task.Name == "" {
+			continue
+		}
+		if _, ok := tasks[task.Name]; ok {
+			v.add(ErrDuplicateTaskName{Name: task.Name}, "pipeline", "tasks", i, "name")
+			continue
+		}
+		tasks[task.Name] = i
+	}
+
+	for i, task := range cfg.Pipeline.Tasks {
+		for j, host := range task.RunsOn {
+			if !hosts[host] {
+				v.add(ErrUnknownHost{TaskName: task.Name, Host: host}, "pipeline", "tasks", i, "runs_on", j)
+			}
+		}
+		for j, dep := range task.DependsOn {
+			if _, ok := tasks[dep]; !ok {
+				v.add(ErrUnknownDependency{TaskName: task.Name, Dependency: dep}, "pipeline", "tasks", i, "depends_on", j)
+			}
+		}
+	}
+
+	for _, cycle := range cfg.findDependencyCycles(tasks) {
+		v.add(ErrDependencyCycle{Cycle: cycle}, "pipeline", "tasks", tasks[cycle[0]], "depends_on")
+	}
+}
+
+// findDependencyCycles finds the cycles in the task dependency graph using a depth first search,
+// tasks maps the task name to its index in the pipeline.
+func (cfg *Config) findDependencyCycles(tasks map[string]int) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+	cycles := [][]string{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range cfg.Pipeline.Tasks[tasks[name]].DependsOn {
+			if _, ok := tasks[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				// dep is in the current path, the path from it back to dep is a cycle.
+				start := slices.Index(stack, dep)
+				cycle := slices.Clone(stack[start:])
+				cycles = append(cycles, append(cycle, dep))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+	}
+
+	for _, task := range cfg.Pipeline.Tasks {
+		if _, ok := tasks[task.Name]; ok && state[task.Name] == unvisited {
+			visit(task.Name)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewConfigValidationReport(t *testing.T) {
+	_, err := NewConfig(filepath.Join("testdata", "invalid-config.yaml"))
+	if err == nil {
+		t.Fatalf("Expected validation report, got no error")
+	}
+	var report ValidationReport
+	if !errors.As(err, &report) {
+		t.Fatalf("Expected ValidationReport, got %T: %v", err, err)
+	}
+
+	expected := []struct {
+		field  string
+		line   int
+		column int
+		err    error
+	}{
+		{field: "provider.github.branch", line: 4, column: 13, err: ErrInvalidBranchName},
+		{field: "hosts[1].name", line: 9, column: 11, err: ErrDuplicateHostName{Name: "test-node-1"}},
+		{field: "hosts[2].address", line: 12, column: 14},
+		{field: "pipeline.tasks[2]", line: 32, column: 7, err: ErrNoFileStrategySpecified},
+		{
+			field: "pipeline.tasks[0].runs_on[1]", line: 21, column: 32,
+			err: ErrUnknownHost{TaskName: "unit", Host: "test-node-3"},
+		},
+		{
+			field: "pipeline.tasks[1].depends_on[1]", line: 28, column: 26,
+			err: ErrUnknownDependency{TaskName: "integration", Dependency: "lint"},
+		},
+		{
+			field: "pipeline.tasks[0].depends_on", line: 22, column: 19,
+			err: ErrDependencyCycle{Cycle: []string{"unit", "integration", "unit"}},
+		},
+	}
+
+	if len(report.Errors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(report.Errors), report)
+	}
+	for i, want := range expected {
+		got := report.Errors[i]
+		if got.Field != want.field {
+			t.Errorf("Expected error %d on field %s, got %s", i, want.field, got.Field)
+		}
+		if got.Line != want.line || got.Column != want.column {
+			t.Errorf("Expected error on %s at %d:%d, got %d:%d", want.field, want.line, want.column, got.Line, got.Column)
+		}
+		if want.err != nil && !reflect.DeepEqual(got.Err, want.err) {
+			t.Errorf("Expected error on %s: %v, got: %v", want.field, want.err, got.Err)
+		}
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	hosts := []Host{
+		{Name: "host1", Address: "host1.example.com:8871"},
+		{Name: "host2", Address: "host2.example.com:8871"},
+	}
+	task := func(name string, deps ...string) TaskConsumerJobs {
+		return TaskConsumerJobs{
+			Name:      name,
+			RunsOn:    []string{"host1"},
+			Commands:  []string{"go test {file}"},
+			Pattern:   ".+_test.go",
+			DependsOn: deps,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		hosts   []Host
+		tasks   []TaskConsumerJobs
+		wantErr []error
+	}{
+		{
+			name:    "valid-dependencies",
+			hosts:   hosts,
+			tasks:   []TaskConsumerJobs{task("a"), task("b", "a"), task("c", "a", "b")},
+			wantErr: nil,
+		},
+		{
+			name:    "self-dependency",
+			hosts:   hosts,
+			tasks:   []TaskConsumerJobs{task("a", "a")},
+			wantErr: []error{ErrDependencyCycle{Cycle: []string{"a", "a"}}},
+		},
+		{
+			name:  "long-cycle",
+			hosts: hosts,
+			tasks: []TaskConsumerJobs{task("a", "c"), task("b", "a"), task("c", "b"), task("d", "a")},
+			wantErr: []error{
+				ErrDependencyCycle{Cycle: []string{"a", "c", "b", "a"}},
+			},
+		},
+		{
+			name:    "duplicate-task-names",
+			hosts:   hosts,
+			tasks:   []TaskConsumerJobs{task("a"), task("a")},
+			wantErr: []error{ErrDuplicateTaskName{Name: "a"}},
+		},
+		{
+			name:  "duplicate-host-names",
+			hosts: append(hosts, Host{Name: "host2", Address: "another.example.com"}),
+			tasks: []TaskConsumerJobs{task("a")},
+			wantErr: []error{
+				ErrDuplicateHostName{Name: "host2"},
+			},
+		},
+		{
+			name:  "unknown-host",
+			hosts: hosts[1:],
+			tasks: []TaskConsumerJobs{task("a"), task("b")},
+			wantErr: []error{
+				ErrUnknownHost{TaskName: "a", Host: "host1"},
+				ErrUnknownHost{TaskName: "b", Host: "host1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Provider: Provider{Github: Github{Repository: "test/repo", Branch: "main"}},
+				Hosts:    tt.hosts,
+				Pipeline: Pipeline{
+					Build: BuildTaskProducer{Name: "build", BuildSteps: []string{"go build ./..."}},
+					Tasks: tt.tasks,
+				},
+			}
+			err := cfg.Validate(nil)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			var report ValidationReport
+			if !errors.As(err, &report) {
+				t.Fatalf("Expected ValidationReport, got %T: %v", err, err)
+			}
+			got := []error{}
+			for _, e := range report.Errors {
+				got = append(got, e.Err)
+			}
+			if !reflect.DeepEqual(got, tt.wantErr) {
+				t.Errorf("Expected errors: %v, got: %v", tt.wantErr, got)
+			}
+		})
+	}
+}
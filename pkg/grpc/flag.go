@@ -11,5 +11,10 @@ var once sync.Once
 func DefineFlags() {
 	once.Do(func() {
 		TlsFlag = flag.Bool("tls", true, "enable TLS")
+		flag.StringVar(&CAPath, "ca", CAPath, "path to the root CA certificate")
+		flag.StringVar(&ServerCertificatePath, "server-cert", ServerCertificatePath, "path to the server certificate")
+		flag.StringVar(&ServerKeyPath, "server-key", ServerKeyPath, "path to the server private key")
+		flag.StringVar(&ClientCertificatePath, "client-cert", ClientCertificatePath, "path to the client certificate")
+		flag.StringVar(&ClientKeyPath, "client-key", ClientKeyPath, "path to the client private key")
 	})
 }
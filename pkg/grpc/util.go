@@ -1,12 +1,15 @@
 package grpc
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"log"
 	"os"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -32,6 +35,27 @@ func CreateNewClientConnection(addr string) (conn *grpc.ClientConn, err error) {
 	conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
 	return
 }
+
+// Ping connects to addr and waits until the connection is ready, an error is returned if
+// the connection isn't ready once ctx is done.
+func Ping(ctx context.Context, addr string) error {
+	conn, err := CreateNewClientConnection(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection state %s: %w", state, ctx.Err())
+		}
+	}
+}
+
 func loadCA(path string) (*x509.CertPool, error) {
 	ca, err := os.ReadFile(os.ExpandEnv(path))
 	if err != nil {
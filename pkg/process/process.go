@@ -0,0 +1,110 @@
+package process
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LineFunc is called with every line a command outputs, without the trailing newline.
+type LineFunc func(line string)
+
+// Command returns a command that runs in its own process group, when ctx is done the whole group is
+// killed so commands it started, e.g. the commands of a shell script, don't outlive it.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
+
+// WithTimeout returns a context that is done after timeout, or when ctx is done if timeout isn't positive.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Run starts cmd and waits for it to exit, calling onLine with every line it writes to stdout
+// or stderr as soon as it is written. it returns the combined output of the command, like
+// exec.Cmd.CombinedOutput. onLine can be nil.
+func Run(cmd *exec.Cmd, onLine LineFunc) (string, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		return "", err
+	}
+	// the command has its own copy of the write end, the read end gets EOF once the command
+	// and its children close it.
+	pw.Close()
+
+	var out strings.Builder
+	r := bufio.NewReader(pr)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			out.WriteString(line)
+			if onLine != nil {
+				onLine(strings.TrimRight(line, "\r\n"))
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				// keep draining the pipe so the command isn't blocked writing to it.
+				io.Copy(io.Discard, pr)
+			}
+			break
+		}
+	}
+	pr.Close()
+	return out.String(), cmd.Wait()
+}
+
+// ExitCode returns the exit code of a command from the error it returned, 0 if err is nil
+// and -1 if the command didn't exit normally, e.g. it was killed or couldn't start.
+// errors of commands run over ssh are supported as well.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var sshErr interface{ ExitStatus() int }
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	return -1
+}
+
+// Environ returns the environment of the current process with env added to it, variables in env
+// override the process' variables. it returns nil if env is empty, so a command inherits the
+// environment of the current process.
+func Environ(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	environ := os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		environ = append(environ, key+"="+env[key])
+	}
+	return environ
+}
@@ -0,0 +1,146 @@
+package process
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		cmd           string
+		expectedLines []string
+		expectedOut   string
+		expectErr     bool
+	}{
+		{
+			name:          "stdout and stderr",
+			cmd:           `echo first; echo second >&2; printf "no newline"`,
+			expectedLines: []string{"first", "second", "no newline"},
+			expectedOut:   "first\nsecond\nno newline",
+		},
+		{
+			name:          "failing command",
+			cmd:           `echo failed; exit 3`,
+			expectedLines: []string{"failed"},
+			expectedOut:   "failed\n",
+			expectErr:     true,
+		},
+		{
+			name:          "no output",
+			cmd:           `true`,
+			expectedLines: nil,
+			expectedOut:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			out, err := Run(exec.Command("/bin/sh", "-c", tt.cmd), func(line string) {
+				lines = append(lines, line)
+			})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tt.expectErr, err)
+			}
+			if out != tt.expectedOut {
+				t.Errorf("Expected output %q, got %q", tt.expectedOut, out)
+			}
+			if !slices.Equal(lines, tt.expectedLines) {
+				t.Errorf("Expected lines %q, got %q", tt.expectedLines, lines)
+			}
+		})
+	}
+}
+
+func TestRunStreamsLines(t *testing.T) {
+	lines := make(chan string, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(exec.Command("/bin/sh", "-c", "echo first; sleep 1; echo second"), func(line string) {
+			lines <- line
+		})
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "first" {
+			t.Errorf("Expected line %q, got %q", "first", line)
+		}
+	case <-done:
+		t.Fatalf("Expected the first line before the command exited")
+	case <-time.After(900 * time.Millisecond):
+		t.Fatalf("Expected the first line to be streamed before the command exited")
+	}
+	<-done
+}
+
+func TestRunKilledByContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Run(exec.CommandContext(ctx, "/bin/sh", "-c", "exec sleep 10"), nil)
+	if err == nil {
+		t.Errorf("Expected killed command to return an error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected command to be killed when the context is done")
+	}
+}
+
+func TestCommandKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// sleep isn't exec'd, it's a child of the shell that holds the output pipe open.
+	// if only the shell was killed, Run would wait for sleep to exit.
+	_, err := Run(Command(ctx, "/bin/sh", "-c", "sleep 10; echo done"), nil)
+	if err == nil {
+		t.Errorf("Expected killed command to return an error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the children of the command to be killed when the context is done")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "success", err: nil, expected: 0},
+		{name: "exit status", err: exec.Command("/bin/sh", "-c", "exit 3").Run(), expected: 3},
+		{name: "killed", err: Command(ctx, "/bin/sh", "-c", "sleep 10").Run(), expected: -1},
+		{name: "not started", err: exec.Command("/does/not/exist").Run(), expected: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.err); code != tt.expected {
+				t.Errorf("Expected exit code %d, got %d for %v", tt.expected, code, tt.err)
+			}
+		})
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("CONFLOW_TEST_INHERITED", "inherited")
+	if env := Environ(nil); env != nil {
+		t.Errorf("Expected a nil environment without variables, got %d variables", len(env))
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", "echo $CONFLOW_TEST_INHERITED $CONFLOW_TEST_ADDED")
+	cmd.Env = Environ(map[string]string{"CONFLOW_TEST_ADDED": "added"})
+	out, err := Run(cmd, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out != "inherited added\n" {
+		t.Errorf("Expected the added variables on top of the process environment, got %q", out)
+	}
+}
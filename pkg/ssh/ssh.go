@@ -1,10 +1,20 @@
 package ssh
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/ImTheCurse/ConflowCI/pkg/config"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
@@ -57,3 +67,157 @@ func NewSSHConn(ep config.EndpointInfo, cfg *ssh.ClientConfig) (*ssh.Client, err
 	}
 	return conn, nil
 }
+
+// DialEndpoint connects to the ssh server of ep, authenticating as ep.User with ep.PrivateKeyPath.
+func DialEndpoint(ep config.EndpointInfo) (*ssh.Client, error) {
+	return DialEndpointContext(context.Background(), ep)
+}
+
+// DialEndpointContext is like DialEndpoint, connecting is abandoned once ctx is done.
+func DialEndpointContext(ctx context.Context, ep config.EndpointInfo) (*ssh.Client, error) {
+	cfg, err := SSHConnConfig{Username: ep.User, PrivateKeyPath: ep.PrivateKeyPath}.BuildConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timeout = dialTimeout
+	addr := fmt.Sprintf("%s:%d", ep.Host, ep.SSHPort)
+	logger.Println("Starting SSH connection")
+	d := net.Dialer{Timeout: dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh to %s: %w", ep.GetSSHURL(), err)
+	}
+	// the handshake is interrupted by expiring the connection's deadline once ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh to %s: %w", ep.GetSSHURL(), err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Home returns the home directory of the user conn is authenticated as.
+func Home(conn *ssh.Client) (string, error) {
+	var home bytes.Buffer
+	if err := Run(conn, `printf %s "$HOME"`, &home); err != nil {
+		return "", fmt.Errorf("resolving home directory: %w: %s", err, home.String())
+	}
+	return home.String(), nil
+}
+
+// RunScript runs script with bash on the host of conn and waits for it to exit, calling onLine with
+// every line it writes to stdout or stderr. it returns the combined output of the script.
+// the script is written to the shell's stdin, so secrets it exports don't show in the host's process list.
+// the script runs in its own process group, started with setsid. when ctx is done the process group is
+// killed over a second session, so everything the script started is killed, and the session is closed.
+// on hosts without setsid only the script's shell is sent SIGKILL, which sshd may not forward.
+func RunScript(ctx context.Context, conn *ssh.Client, script string, onLine process.LineFunc) (string, error) {
+	sess, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer sess.Close()
+	pr, pw := io.Pipe()
+	// the shell writes its pid first, which is the ID of its process group.
+	sess.Stdin = strings.NewReader("echo \"$$\"\n" + script)
+	sess.Stdout = pw
+	sess.Stderr = pw
+	if err := sess.Start(scriptShell); err != nil {
+		return "", err
+	}
+
+	var pgid atomic.Int64
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if id := pgid.Load(); id > 0 {
+				killProcessGroup(conn, id)
+			}
+			sess.Signal(ssh.SIGKILL)
+			sess.Close()
+		case <-done:
+		}
+	}()
+	waitErr := make(chan error, 1)
+	go func() {
+		err := sess.Wait()
+		pw.Close()
+		waitErr <- err
+	}()
+
+	var out strings.Builder
+	r := bufio.NewReader(pr)
+	first := true
+	for {
+		line, err := r.ReadString('\n')
+		if first {
+			first = false
+			if id, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64); err == nil && id > 0 {
+				pgid.Store(id)
+				continue
+			}
+		}
+		if line != "" {
+			out.WriteString(line)
+			if onLine != nil {
+				onLine(strings.TrimRight(line, "\r\n"))
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	err = <-waitErr
+	if ctx.Err() != nil {
+		return out.String(), ctx.Err()
+	}
+	return out.String(), err
+}
+
+// killProcessGroup sends SIGKILL to the process group pgid on the host of conn, over a new session.
+func killProcessGroup(conn *ssh.Client, pgid int64) {
+	var out bytes.Buffer
+	if err := Run(conn, fmt.Sprintf("kill -KILL -%d", pgid), &out); err != nil {
+		logger.Printf("Failed to kill process group %d: %v: %s", pgid, err, out.String())
+	}
+}
+
+// Run runs cmd in a new session of conn, the combined output of cmd is written to out.
+func Run(conn *ssh.Client, cmd string, out io.Writer) error {
+	sess, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	sess.Stdout = out
+	sess.Stderr = out
+	return sess.Run(cmd)
+}
+
+// Upload writes the content of r to path on the host of conn with the given permissions,
+// parent directories are created if they don't exist.
+func Upload(conn *ssh.Client, r io.Reader, path string, mode os.FileMode) error {
+	sess, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	sess.Stdin = r
+	out, err := sess.CombinedOutput(fmt.Sprintf(`mkdir -p "$(dirname %[1]s)" && cat > %[1]s && chmod %[2]o %[1]s`,
+		Quote(path), mode.Perm()))
+	if err != nil {
+		return fmt.Errorf("upload to %s: %w: %s", path, err, out)
+	}
+	return nil
+}
+
+// Quote quotes s for a posix shell, so it is passed to a remote command as a single argument.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
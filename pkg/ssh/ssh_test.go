@@ -3,12 +3,24 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/ImTheCurse/ConflowCI/pkg/config"
 	"github.com/ImTheCurse/ConflowCI/pkg/crypto"
+	"github.com/ImTheCurse/ConflowCI/pkg/process"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestNewSSHConn(t *testing.T) {
@@ -56,3 +68,166 @@ func TestNewSSHConn(t *testing.T) {
 		t.Errorf("Expected 'Hello, World!' got: %s", buf.String())
 	}
 }
+
+func TestDialEndpointContext(t *testing.T) {
+	if _, _, err := crypto.GenerateKeys(); err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+	defer os.RemoveAll("keys")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server accepts connections, but never answers the handshake.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ep := config.EndpointInfo{
+		Host:           "127.0.0.1",
+		SSHPort:        uint16(lis.Addr().(*net.TCPAddr).Port),
+		User:           "user",
+		PrivateKeyPath: "keys/id_rsa",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = DialEndpointContext(ctx, ep)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the dial to exceed the context's deadline, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= dialTimeout {
+		t.Errorf("Expected the dial to stop with the context, it took %s", elapsed)
+	}
+}
+
+// startExecServer starts a ssh server that runs exec requests with sh on the local machine, each
+// in its own session like sshd does. signal requests are refused, like sshd refuses them for
+// processes the command started.
+func startExecServer(t *testing.T) *ssh.Client {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(signer)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveExec(conn, cfg)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", lis.Addr().String(), &ssh.ClientConfig{
+		User:            "user",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to the ssh server: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func serveExec(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			req, ok := <-reqs
+			if !ok {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			var payload struct{ Command string }
+			if req.Type != "exec" || ssh.Unmarshal(req.Payload, &payload) != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			cmd := exec.Command("sh", "-c", payload.Command)
+			cmd.Stdin = ch
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+			status := uint32(process.ExitCode(cmd.Run()))
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		}()
+	}
+}
+
+// processExited returns if the process pid exited, or only its zombie is left.
+func processExited(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	// the state follows the command name, which is in parentheses.
+	_, state, _ := strings.Cut(string(stat), ") ")
+	return strings.HasPrefix(state, "Z")
+}
+
+func TestRunScriptKillsProcessGroup(t *testing.T) {
+	client := startExecServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	child := 0
+	script := "sleep 30 &\necho \"child $!\"\nwait\n"
+	out, err := RunScript(ctx, client, script, func(line string) {
+		if pid, ok := strings.CutPrefix(line, "child "); ok {
+			child, _ = strconv.Atoi(pid)
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("Expected the script to be cancelled, got: %v", err)
+	}
+	if !strings.HasPrefix(out, "child ") || child == 0 {
+		t.Fatalf("Expected the output of the script only, got: %q", out)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !processExited(child) {
+		if time.Now().After(deadline) {
+			syscall.Kill(child, syscall.SIGKILL)
+			t.Fatalf("Expected the background command of the script to be killed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
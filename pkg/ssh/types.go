@@ -3,8 +3,16 @@ package ssh
 import (
 	"log"
 	"os"
+	"time"
 )
 
+// dialTimeout is how long connecting to a ssh server can take.
+const dialTimeout = 10 * time.Second
+
+// scriptShell runs the script written to its stdin in a new session and process group, if setsid is installed.
+// setsid -w waits for the script and exits with its status.
+const scriptShell = "if command -v setsid >/dev/null 2>&1; then exec setsid -w bash -s; else exec bash -s; fi"
+
 var logger = log.New(os.Stdout, "[SSH]: ", log.Lshortfile|log.LstdFlags)
 
 // Configuration for creating an ssh connection.